@@ -25,88 +25,171 @@
 package cli
 
 import (
+	"fmt"
 	"github.com/mdhender/wow/pkg/board"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 // cmdCreateMap creates a map
 var cmdCreateMap = &cobra.Command{
 	Use:   "create",
 	Short: "create a new map",
+	Long: `Create the standard map, once for each theme.
+
+The formats are svg, html, png and pdf. PNG files are rendered at --dpi.
+PDF files are printed at actual size and tiled across as many --paper
+pages (letter or a4) as are needed.
+
+The built-in themes are ` + strings.Join(board.ThemeNames(), ", ") + `.
+A JSON theme file (--theme-file) holds render options and a theme;
+anything it leaves out comes from the defaults. The other flags
+override both the theme and the theme file.
+
+A mask file (--mask) draws the hexes that are part of the board in
+ASCII, one line per row: "." is a hex on the board, and a space, "-"
+or "x" is not. Hexes outside the mask are left off the map.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		type hex struct {
-			name      string
-			col, row  int
-			econValue int // non-zero only if hasStar
-			warps     []string
+		gb := board.NewStandardBoard()
+		if argsCreateMap.mask != "" {
+			var err error
+			gb, err = maskBoard(gb, argsCreateMap.mask)
+			cobra.CheckErr(err)
 		}
 
-		// hexes is an untested guess as to the layout of the original map
-		hexes := []hex{
-			{"Adab", 6, 6, 0, []string{"Erech", "Khafa", "Byblos"}},
-			{"Akkad", 7, 16, 3, []string{"Kish"}},
-			{"Assur", 12, 10, 2, []string{"Nippur", "Lagash"}},
-			{"Babylon", 6, 18, 4, []string{"Sumer"}},
-			{"Byblos", 2, 6, 3, []string{"Adab"}},
-			{"Calah", 8, 4, 1, []string{"Nippur"}},
-			{"Elam", 7, 12, 5, []string{"Lagash"}},
-			{"Erech", 4, 4, 3, []string{"Ur", "Adab"}},
-			{"Eridu", 12, 16, 1, []string{"Kish", "Ugarit"}},
-			{"Girsu", 8, 13, 1, []string{"Umma"}},
-			{"Jarmo", 11, 12, 3, []string{"Kish"}},
-			{"Isin", 1, 15, 1, []string{"Nineveh"}},
-			{"Khafa", 7, 9, 2, []string{"Adab"}},
-			{"Kish", 10, 15, 0, []string{"Jarmo", "Eridu"}},
-			{"Lagash", 9, 11, 1, []string{"Assur"}},
-			{"Larsu", 11, 2, 2, []string{"Susa"}},
-			{"Mari", 6, 10, 1, []string{"Ubaid", "Umma"}},
-			{"Mosul", 3, 1, 2, []string{"Sippur"}},
-			{"Nineveh", 3, 19, 2, []string{"Isin"}},
-			{"Nippur", 10, 7, 1, []string{"Calah", "Susa", "Assur", "Lagash"}},
-			{"Sippur", 2, 4, 1, []string{"Mosul"}},
-			{"Sumarra", 2, 12, 2, []string{"Ubaid", "Umma"}},
-			{"Sumer", 4, 16, 0, []string{"Umma", "Babylon"}},
-			{"Susa", 12, 5, 0, []string{"Larsu", "Nippur"}},
-			{"Ubaid", 3, 8, 5, []string{"Mari", "Sumarra"}},
-			{"Ugarit", 11, 20, 2, []string{"Eridu"}},
-			{"Umma", 5, 14, 2, []string{"Sumarra", "Mari", "Girsu", "Sumer"}},
-			{"Ur", 7, 2, 4, []string{"Erech"}},
+		// collect the render options for each theme
+		type output struct {
+			name string // base name of the files
+			opts board.RenderOptions
 		}
-
-		// max row and col determine the size of the board
-		maxRow, maxCol := 0, 0
-		for _, h := range hexes {
-			if h.row > maxRow {
-				maxRow = h.row
+		var outputs []output
+		for _, name := range strings.Split(argsCreateMap.theme, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
 			}
-			if h.col > maxCol {
-				maxCol = h.col
+			theme, err := board.LookupTheme(name)
+			cobra.CheckErr(err)
+			opts := board.DefaultRenderOptions(false)
+			opts.Theme = theme
+			outputs = append(outputs, output{name: themeFileName(theme.Name), opts: opts})
+		}
+		if argsCreateMap.themeFile != "" {
+			data, err := os.ReadFile(argsCreateMap.themeFile)
+			cobra.CheckErr(err)
+			opts, err := board.ReadRenderOptions(data)
+			cobra.CheckErr(err)
+			name := opts.Theme.Name
+			if name == "" {
+				name = "custom"
 			}
+			outputs = append(outputs, output{name: themeFileName(name), opts: opts})
 		}
-
-		// create the board, add all the stars, then add the wormholes
-		gb := board.NewBoard(maxRow, maxCol)
-		for _, h := range hexes {
-			gb.AddStar(h.name, h.row, h.col, h.econValue)
+		for i := range outputs {
+			o := &outputs[i].opts
+			if argsCreateMap.hexSize != 0 {
+				o.HexSize = argsCreateMap.hexSize
+			}
+			if argsCreateMap.orientation != "" {
+				o.Orientation = argsCreateMap.orientation
+			}
+			if argsCreateMap.offset != "" {
+				o.Offset = argsCreateMap.offset
+			}
+			if argsCreateMap.fontFamily != "" {
+				o.FontFamily = argsCreateMap.fontFamily
+			}
+			if argsCreateMap.fontSize != 0 {
+				o.FontSize = argsCreateMap.fontSize
+			}
+			if argsCreateMap.strokeWidth != 0 {
+				o.StrokeWidth = argsCreateMap.strokeWidth
+			}
+			if argsCreateMap.margin != 0 {
+				o.Margin = argsCreateMap.margin
+			}
 		}
-		for _, h := range hexes {
-			for _, target := range h.warps {
-				gb.AddWormHole(h.name, target)
+
+		for _, format := range strings.Split(argsCreateMap.format, ",") {
+			format = strings.ToLower(strings.TrimSpace(format))
+			for _, out := range outputs {
+				var data []byte
+				var err error
+				switch format {
+				case "svg":
+					data, err = gb.RenderSVG(out.opts)
+				case "html":
+					data, err = gb.RenderHTML(out.opts)
+				case "png":
+					data, err = gb.RenderPNG(out.opts, argsCreateMap.dpi)
+				case "pdf":
+					data, err = gb.RenderPDF(out.opts, argsCreateMap.paper)
+				default:
+					err = fmt.Errorf("unknown format %q", format)
+				}
+				cobra.CheckErr(err)
+				cobra.CheckErr(os.WriteFile(out.name+"."+format, data, 0644))
 			}
 		}
+	},
+}
 
-		mono := true
-		// save the board as an SVG file
-		cobra.CheckErr(os.WriteFile("svg-mono-test.svg", gb.AsSVG(mono), 0644))
-		cobra.CheckErr(os.WriteFile("svg-test.svg", gb.AsSVG(!mono), 0644))
+// maskBoard returns a copy of the board with the mask read from a file.
+// Every star must be inside the mask.
+func maskBoard(b *board.Board, name string) (*board.Board, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mask, err := board.ParseMask(f)
+	if err != nil {
+		return nil, err
+	}
+	return board.FromNodesMasked(b.Nodes(), mask)
+}
 
-		// save the board as an HTML file
-		cobra.CheckErr(os.WriteFile("svg-mono-test.html", gb.AsHTML(mono), 0644))
-		cobra.CheckErr(os.WriteFile("svg-test.html", gb.AsHTML(!mono), 0644))
-	},
+// themeFileName returns the base name of the files for a theme.
+// The color and mono themes keep the names they have always had.
+func themeFileName(theme string) string {
+	switch theme {
+	case "color":
+		return "svg-test"
+	case "mono":
+		return "svg-mono-test"
+	}
+	return "svg-" + theme + "-test"
+}
+
+var argsCreateMap struct {
+	format      string  // comma separated list of formats to create
+	theme       string  // comma separated list of themes to create
+	themeFile   string  // json file with render options
+	mask        string  // ascii file with the hexes on the board
+	dpi         float64 // resolution of png files
+	paper       string  // paper size for pdf files
+	hexSize     float64
+	orientation string
+	offset      string
+	fontFamily  string
+	fontSize    float64
+	strokeWidth float64
+	margin      float64
 }
 
 func init() {
 	cmdBase.AddCommand(cmdCreateMap)
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.format, "format", "svg,html", "formats to create (svg, html, png, pdf)")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.theme, "theme", "color,mono", "themes to create")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.themeFile, "theme-file", "", "json file with a theme and render options")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.mask, "mask", "", "ascii file with the hexes that are part of the board")
+	cmdCreateMap.Flags().Float64Var(&argsCreateMap.dpi, "dpi", 96, "resolution of png files")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.paper, "paper", "letter", "paper size of pdf files (letter or a4)")
+	cmdCreateMap.Flags().Float64Var(&argsCreateMap.hexSize, "hex-size", 0, "distance from the center of a hex to a corner (default 55)")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.orientation, "orientation", "", "flat or pointy hexes (default flat)")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.offset, "offset", "", "even or odd offset coordinates (default even)")
+	cmdCreateMap.Flags().StringVar(&argsCreateMap.fontFamily, "font-family", "", "font for svg labels")
+	cmdCreateMap.Flags().Float64Var(&argsCreateMap.fontSize, "font-size", 0, "size of labels (default scales with the hexes)")
+	cmdCreateMap.Flags().Float64Var(&argsCreateMap.strokeWidth, "stroke-width", 0, "width of the outlines of hexes and stars (default 2)")
+	cmdCreateMap.Flags().Float64Var(&argsCreateMap.margin, "margin", 0, "space around the board (default 40)")
 }
@@ -0,0 +1,122 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/galaxy"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cmdGalaxy draws the maps of a multi-sector galaxy
+var cmdGalaxy = &cobra.Command{
+	Use:   "galaxy FILE",
+	Short: "draw the maps of a galaxy",
+	Long: `Draw the maps of a galaxy of linked sectors, saved as JSON.
+
+The overview of the galaxy is always drawn as svg, in NAME.svg. Each
+sector is drawn in NAME-SECTOR with every format in --format, with stubs
+for the warp lines that lead to other sectors. The formats are the same
+as for import. NAME is the name of the galaxy file unless --out gives
+another base name.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		theme, err := board.LookupTheme(argsGalaxy.theme)
+		cobra.CheckErr(err)
+		opts := board.DefaultRenderOptions(false)
+		opts.Theme = theme
+
+		f, err := os.Open(args[0])
+		cobra.CheckErr(err)
+		g, err := galaxy.Read(f, opts.Geometry())
+		_ = f.Close()
+		cobra.CheckErr(err)
+
+		name := argsGalaxy.out
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		}
+		data, err := g.RenderOverview(opts)
+		cobra.CheckErr(err)
+		cobra.CheckErr(os.WriteFile(name+".svg", data, 0644))
+
+		for _, s := range g.Sectors {
+			b, err := g.SectorBoard(s.Name)
+			cobra.CheckErr(err)
+			for _, format := range strings.Split(argsGalaxy.format, ",") {
+				format = strings.ToLower(strings.TrimSpace(format))
+				var data []byte
+				var buf bytes.Buffer
+				switch format {
+				case "json":
+					err = b.WriteJSON(&buf)
+				case "csv":
+					err = b.WriteCSV(&buf)
+				case "dot":
+					err = b.WriteDOT(&buf)
+				case "graphml":
+					err = b.WriteGraphML(&buf)
+				case "svg":
+					data, err = b.RenderSVG(opts)
+				case "html":
+					data, err = b.RenderViewer(opts, s.Name)
+				case "png":
+					data, err = b.RenderPNG(opts, argsGalaxy.dpi)
+				case "pdf":
+					data, err = b.RenderPDF(opts, argsGalaxy.paper)
+				default:
+					err = fmt.Errorf("unknown format %q", format)
+				}
+				cobra.CheckErr(err)
+				if data == nil {
+					data = buf.Bytes()
+				}
+				cobra.CheckErr(os.WriteFile(name+"-"+s.Name+"."+format, data, 0644))
+			}
+		}
+	},
+}
+
+var argsGalaxy struct {
+	format string  // comma separated list of formats to create for each sector
+	out    string  // base name of the files
+	theme  string  // theme of the images
+	dpi    float64 // resolution of png files
+	paper  string  // paper size for pdf files
+}
+
+func init() {
+	cmdBase.AddCommand(cmdGalaxy)
+	cmdGalaxy.Flags().StringVar(&argsGalaxy.format, "format", "svg", "formats to create for each sector (json, csv, dot, graphml, svg, html, png, pdf)")
+	cmdGalaxy.Flags().StringVar(&argsGalaxy.out, "out", "", "base name of the files (default the name of the galaxy file)")
+	cmdGalaxy.Flags().StringVar(&argsGalaxy.theme, "theme", "color", "theme of the images")
+	cmdGalaxy.Flags().Float64Var(&argsGalaxy.dpi, "dpi", 96, "resolution of png files")
+	cmdGalaxy.Flags().StringVar(&argsGalaxy.paper, "paper", "letter", "paper size of pdf files (letter or a4)")
+}
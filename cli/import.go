@@ -0,0 +1,126 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/tiled"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cmdImportMap imports a map from Tiled
+var cmdImportMap = &cobra.Command{
+	Use:   "import FILE",
+	Short: "import a map from Tiled",
+	Long: `Import a hexagonal map made with Tiled, saved as TMX or JSON.
+
+The map data formats are json, csv, dot and graphml; the image formats
+are svg, html (the interactive viewer), png and pdf. Images are drawn
+with the orientation and offset of the Tiled map. The files are named
+after the map unless --out gives another base name. A mask file (--mask)
+leaves the hexes outside it off the map, as it does for create.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := tiled.ReadFile(args[0])
+		cobra.CheckErr(err)
+		diagnostics := board.ValidateNodes(m.Nodes)
+		if !board.HasErrors(diagnostics) {
+			diagnostics = append(diagnostics, board.ValidateCrossings(m.Nodes, nil, board.WrapNone, m.RenderOptions(false).Geometry())...)
+		}
+		for _, d := range diagnostics {
+			fmt.Fprintf(os.Stderr, "%s: %s: %v\n", args[0], d.Severity, d)
+		}
+		b, err := m.Board()
+		cobra.CheckErr(err)
+		if argsImportMap.mask != "" {
+			b, err = maskBoard(b, argsImportMap.mask)
+			cobra.CheckErr(err)
+		}
+
+		name := argsImportMap.out
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		}
+		theme, err := board.LookupTheme(argsImportMap.theme)
+		cobra.CheckErr(err)
+		opts := m.RenderOptions(false)
+		opts.Theme = theme
+
+		for _, format := range strings.Split(argsImportMap.format, ",") {
+			format = strings.ToLower(strings.TrimSpace(format))
+			var data []byte
+			var buf bytes.Buffer
+			switch format {
+			case "json":
+				err = b.WriteJSON(&buf)
+			case "csv":
+				err = b.WriteCSV(&buf)
+			case "dot":
+				err = b.WriteDOT(&buf)
+			case "graphml":
+				err = b.WriteGraphML(&buf)
+			case "svg":
+				data, err = b.RenderSVG(opts)
+			case "html":
+				data, err = b.RenderViewer(opts, name)
+			case "png":
+				data, err = b.RenderPNG(opts, argsImportMap.dpi)
+			case "pdf":
+				data, err = b.RenderPDF(opts, argsImportMap.paper)
+			default:
+				err = fmt.Errorf("unknown format %q", format)
+			}
+			cobra.CheckErr(err)
+			if data == nil {
+				data = buf.Bytes()
+			}
+			cobra.CheckErr(os.WriteFile(name+"."+format, data, 0644))
+		}
+	},
+}
+
+var argsImportMap struct {
+	format string  // comma separated list of formats to create
+	out    string  // base name of the files
+	mask   string  // ascii file with the hexes on the board
+	theme  string  // theme of the images
+	dpi    float64 // resolution of png files
+	paper  string  // paper size for pdf files
+}
+
+func init() {
+	cmdBase.AddCommand(cmdImportMap)
+	cmdImportMap.Flags().StringVar(&argsImportMap.format, "format", "json,svg", "formats to create (json, csv, dot, graphml, svg, html, png, pdf)")
+	cmdImportMap.Flags().StringVar(&argsImportMap.out, "out", "", "base name of the files (default the name of the map file)")
+	cmdImportMap.Flags().StringVar(&argsImportMap.mask, "mask", "", "ascii file with the hexes that are part of the board")
+	cmdImportMap.Flags().StringVar(&argsImportMap.theme, "theme", "color", "theme of the images")
+	cmdImportMap.Flags().Float64Var(&argsImportMap.dpi, "dpi", 96, "resolution of png files")
+	cmdImportMap.Flags().StringVar(&argsImportMap.paper, "paper", "letter", "paper size of pdf files (letter or a4)")
+}
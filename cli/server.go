@@ -28,11 +28,15 @@ import (
 	"context"
 	"errors"
 	"github.com/mdhender/wow/internal/june"
+	"github.com/mdhender/wow/pkg/game"
+	"github.com/mdhender/wow/pkg/pbem"
 	"github.com/mdhender/wow/pkg/server"
+	"github.com/mdhender/wow/pkg/store"
 	"github.com/spf13/cobra"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"time"
 )
@@ -42,10 +46,59 @@ var cmdServer = &cobra.Command{
 	Use:   "server",
 	Short: "serve map api",
 	Run: func(cmd *cobra.Command, args []string) {
-		s, err := server.New()
+		// time.NewTicker panics on an interval that isn't positive.
+		if argsServer.schedulePoll <= 0 {
+			log.Fatal(errors.New("--schedule-poll must be greater than zero"))
+		} else if argsServer.mail.poll <= 0 {
+			log.Fatal(errors.New("--mail-poll must be greater than zero"))
+		}
+
+		// open the store and apply any pending migrations before accepting requests.
+		st, err := store.Open(argsServer.store, argsServer.data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := st.Close(); err != nil {
+				log.Printf("[server] store: %v\n", err)
+			}
+		}()
+		if err := st.Migrate(); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("[server] store   %s %q\n", argsServer.store, argsServer.data)
+
+		// the admin token may be set in the environment to keep it off the command line.
+		if argsServer.adminToken == "" {
+			argsServer.adminToken = os.Getenv("WOW_ADMIN_TOKEN")
+		}
+		if argsServer.adminToken == "" {
+			log.Printf("[server] no admin token, so maps and games can't be created\n")
+		}
+
+		engine := game.New(st)
+		engine.SetSightRadius(argsServer.sight)
+		options := []server.Option{server.WithStore(st), server.WithEngine(engine), server.WithAdminToken(argsServer.adminToken)}
+
+		// the mail gateway is optional. it needs a maildir to read orders from
+		// and either an smtp server or a sink to send replies and reports to.
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if argsServer.mail.maildir != "" {
+			gw, err := newMailGateway(st, engine)
+			if err != nil {
+				log.Fatal(err)
+			}
+			options = append(options, server.WithMailGateway(gw))
+			go gw.Run(ctx, argsServer.mail.poll)
+			log.Printf("[server] mail    %q every %v\n", argsServer.mail.maildir, argsServer.mail.poll)
+		}
+
+		s, err := server.New(options...)
 		if err != nil {
 			log.Fatal(err)
 		}
+		go s.RunScheduler(ctx, argsServer.schedulePoll, argsServer.remind)
 
 		// server assumes that it is exposed to the internet.
 		// it sets timeouts to avoid simple DOS attacks.
@@ -86,9 +139,54 @@ var cmdServer = &cobra.Command{
 }
 
 var argsServer struct {
-	host   string
-	port   string
-	public string
+	host       string
+	port       string
+	public     string
+	store      string
+	data       string
+	adminToken string
+	// schedulePoll is how often the scheduler checks for turns that are due.
+	schedulePoll time.Duration
+	// remind is how long before a deadline players are reminded to send orders.
+	remind time.Duration
+	// sight is how many hexes players can see from their stars.
+	sight int
+	mail  struct {
+		maildir  string
+		poll     time.Duration
+		from     string
+		smtpAddr string
+		smtpUser string
+		sink     string
+	}
+}
+
+// newMailGateway returns a gateway configured from the command line.
+func newMailGateway(st store.Store, engine *game.Engine) (*pbem.Gateway, error) {
+	inbox, err := pbem.NewMaildir(argsServer.mail.maildir)
+	if err != nil {
+		return nil, err
+	}
+	var sender pbem.Sender
+	if argsServer.mail.sink != "" {
+		sink, err := pbem.NewMaildir(argsServer.mail.sink)
+		if err != nil {
+			return nil, err
+		}
+		sender = &pbem.MaildirSender{Maildir: sink}
+	} else if argsServer.mail.smtpAddr != "" {
+		sender = &pbem.SMTPSender{
+			Addr:     argsServer.mail.smtpAddr,
+			Username: argsServer.mail.smtpUser,
+			Password: os.Getenv("WOW_SMTP_PASSWORD"),
+		}
+	} else {
+		return nil, errors.New("mail: need --smtp-addr or --mail-sink")
+	}
+	if argsServer.mail.from == "" {
+		return nil, errors.New("mail: need --mail-from")
+	}
+	return pbem.New(st, engine, inbox, sender, argsServer.mail.from), nil
 }
 
 func init() {
@@ -96,4 +194,16 @@ func init() {
 	cmdServer.Flags().StringVar(&argsServer.host, "host", "", "host interface to bind to")
 	cmdServer.Flags().StringVar(&argsServer.port, "port", "8080", "port to serve on")
 	cmdServer.Flags().StringVar(&argsServer.public, "public", ".", "path to serve files from")
+	cmdServer.Flags().StringVar(&argsServer.store, "store", "file", "storage backend (file or sqlite)")
+	cmdServer.Flags().StringVar(&argsServer.data, "data", "data", "path to the data directory (file) or database (sqlite)")
+	cmdServer.Flags().StringVar(&argsServer.adminToken, "admin-token", "", "token for admin requests (default $WOW_ADMIN_TOKEN)")
+	cmdServer.Flags().DurationVar(&argsServer.schedulePoll, "schedule-poll", time.Minute, "how often to check for turns that are due")
+	cmdServer.Flags().DurationVar(&argsServer.remind, "remind", 24*time.Hour, "how long before a deadline to remind players without orders")
+	cmdServer.Flags().IntVar(&argsServer.sight, "sight", game.DefaultSightRadius, "how many hexes players can see from their stars")
+	cmdServer.Flags().StringVar(&argsServer.mail.maildir, "maildir", "", "maildir to read emailed orders from (enables the mail gateway)")
+	cmdServer.Flags().DurationVar(&argsServer.mail.poll, "mail-poll", time.Minute, "how often to check the maildir")
+	cmdServer.Flags().StringVar(&argsServer.mail.from, "mail-from", "", "address to send replies and reports from")
+	cmdServer.Flags().StringVar(&argsServer.mail.smtpAddr, "smtp-addr", "", "host:port of the smtp server (password from $WOW_SMTP_PASSWORD)")
+	cmdServer.Flags().StringVar(&argsServer.mail.smtpUser, "smtp-user", "", "user name for the smtp server")
+	cmdServer.Flags().StringVar(&argsServer.mail.sink, "mail-sink", "", "maildir to deliver outgoing mail to instead of smtp (for testing)")
 }
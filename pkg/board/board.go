@@ -30,7 +30,9 @@ package board
 import (
 	"bytes"
 	"fmt"
+	"github.com/mdhender/wow/pkg/coords"
 	"github.com/mdhender/wow/pkg/hexes"
+	"io"
 	"math"
 )
 
@@ -53,36 +55,53 @@ func NewBoard(rows, cols int) *Board {
 	return b
 }
 
+// NewMaskedBoard creates a board that is just large enough to hold the mask.
+func NewMaskedBoard(m Mask) *Board {
+	cols, rows := m.Bounds()
+	b := NewBoard(rows, cols)
+	b.Mask = m
+	return b
+}
+
+// OnBoard reports whether a hex is on the board and inside its mask.
+// The border is left off the edges that wrap.
+func (b *Board) OnBoard(c Coords) bool {
+	if b.Wrap&WrapEastWest != 0 && (c.Col < 1 || c.Col > b.Cols-2) {
+		return false
+	} else if b.Wrap&WrapNorthSouth != 0 && (c.Row < 1 || c.Row > b.Rows-2) {
+		return false
+	}
+	return 0 <= c.Row && c.Row < b.Rows && 0 <= c.Col && c.Col < b.Cols && b.Mask.Contains(c)
+}
+
 func (b *Board) AddStar(name string, row, col int, econValue int) {
 	hex := &Hex{
 		Coords:    Coords{Row: row, Col: col},
 		Name:      name,
 		HasStar:   true,
 		EconValue: econValue,
+		Features:  b.Hexes[row][col].Features,
 	}
 	b.Hexes[row][col] = hex
 	b.Stars[name] = hex
 }
 
+// AddWormHole adds a warp that goes both ways between two stars.
 func (b *Board) AddWormHole(sourceStar, targetStar string) error {
-	// lookup both ends of the wormhole
-	from, ok := b.Stars[sourceStar]
-	if !ok {
-		return fmt.Errorf("board: invalid source star: %q", sourceStar)
-	}
-	to, ok := b.Stars[targetStar]
-	if !ok {
-		return fmt.Errorf("board: invalid target star: %q", targetStar)
-	}
-
-	// add to exits if not there already
-	from.AddWormHole(to)
-	to.AddWormHole(from)
-
-	return nil
+	return b.AddWarp(Warp{From: sourceStar, To: targetStar})
 }
 
 func (b *Board) AsHTML(mono bool) []byte {
+	data, _ := b.RenderHTML(DefaultRenderOptions(mono))
+	return data
+}
+
+// RenderHTML returns the board as an SVG wrapped in an HTML page.
+func (b *Board) RenderHTML(o RenderOptions) ([]byte, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
 	// create the svg for the board
 	buf := &bytes.Buffer{}
 	_, _ = fmt.Fprintln(buf, `<!doctype html>`)
@@ -98,61 +117,104 @@ func (b *Board) AsHTML(mono bool) []byte {
 	_, _ = fmt.Fprintln(buf, `</head>`)
 	_, _ = fmt.Fprintln(buf, `<body>`)
 	//_, _ = fmt.Fprintln(b, `<div class="scroll">`)
-	_, _ = fmt.Fprintln(buf, b.asSVG(mono).String())
+	_, _ = b.asSVG(o).WriteTo(buf)
+	_, _ = fmt.Fprintln(buf)
 	//_, _ = fmt.Fprintln(b, `</div>`)
 	_, _ = fmt.Fprintln(buf, "</body>")
 	_, _ = fmt.Fprintln(buf, "</html>")
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 func (b *Board) AsSVG(mono bool) []byte {
-	return []byte(b.asSVG(mono).String())
+	data, _ := b.RenderSVG(DefaultRenderOptions(mono))
+	return data
 }
 
-func (b *Board) asSVG(mono bool) *svg {
-	size := 55.0
-	width, height := 2*size, math.Sqrt(3)*size
-	layout := hexes.NewFlatLayout(hexes.NewPoint(size, size), hexes.NewPoint(height, width))
+// RenderSVG returns the board as an SVG drawn with the given options.
+func (b *Board) RenderSVG(o RenderOptions) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := b.WriteSVG(buf, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	// "hsl(39, 100%, 50%)" // "LightBlue" // "hsl(197, 78%, 85%)"
-	var hexFill, starFill string
-	if mono {
-		hexFill, starFill = "none", "White"
-	} else {
-		hexFill, starFill = "hsl(197, 78%, 85%)", "hsl(53, 100%, 94%)"
+// WriteSVG streams the board as an SVG drawn with the given options.
+func (b *Board) WriteSVG(w io.Writer, o RenderOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
 	}
+	_, err := b.asSVG(o).WriteTo(w)
+	return err
+}
 
-	// svg has 0,0 in the upper left.
-	s := &svg{id: "s"}
+// layout returns the hex layout of the drawn board, and the function that
+// converts the offset coordinates of the board to cube coordinates.
+// The options must have their defaults filled in.
+func (b *Board) layout(o RenderOptions) (hexes.Layout, func(col, row int) hexes.Hex) {
+	radius := math.Sqrt(3) / 2 * o.HexSize
 
-	// create the hexes
+	// lay the board out around 0,0, then move it so that the top left corner sits on the margin.
+	layout, toCube := o.layout(hexes.NewPoint(0, 0))
+	minX, minY := math.Inf(1), math.Inf(1)
 	for row := 0; row < b.Rows; row++ {
 		for col := 0; col < b.Cols; col++ {
-			// assumes flat with even-q layout
-			h := hexes.QOffsetToCube(col, row, hexes.EVEN)
-
-			cx, cy := layout.CenterPoint(h).Coords()
-			poly := &polygon{col: col, row: row, cx: cx, cy: cy, radius: height / 2.0}
-
-			poly.style.stroke = "Grey"
-			poly.style.fill = hexFill
-			if poly.style.fill == poly.style.stroke {
-				poly.style.stroke = "Black"
+			if !b.OnBoard(Coords{Col: col, Row: row}) {
+				continue
 			}
-			poly.style.strokeWidth = "2px"
-
-			for _, p := range layout.PolygonCorners(h) {
+			for _, p := range layout.PolygonCorners(toCube(col, row)) {
 				px, py := p.Coords()
-				if width := int(px); width > s.viewBox.width {
-					s.viewBox.width = width
-				}
-				if height := int(py); height > s.viewBox.height {
-					s.viewBox.height = height
-				}
-				poly.points = append(poly.points, point{x: px, y: py})
+				minX, minY = math.Min(minX, px), math.Min(minY, py)
 			}
+		}
+	}
+	for _, st := range b.stubs(layout, toCube, radius, o.FontSize) {
+		x, y, _, _ := st.bounds(o.FontSize)
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+	}
+	return o.layout(hexes.NewPoint(o.Margin-minX, o.Margin-minY))
+}
+
+func (b *Board) asSVG(o RenderOptions) *svg {
+	o = o.WithDefaults()
+	radius := math.Sqrt(3) / 2 * o.HexSize // of the circle that fits inside the hex
+	layout, toCube := b.layout(o)
+
+	// svg has 0,0 in the upper left.
+	s := &svg{
+		id:         "s",
+		background: o.Theme.Background,
+		fontFamily: o.FontFamily,
+		fontSize:   o.FontSize,
+		labelColor: o.Theme.LabelColor,
+		textColor:  o.Theme.TextColor,
+		warpColor:  o.Theme.WarpColor,
+		warpWidth:  o.WarpWidth,
+	}
+	var maxX, maxY float64
+	corners := func(poly *polygon, h hexes.Hex) {
+		for _, p := range layout.PolygonCorners(h) {
+			px, py := p.Coords()
+			maxX, maxY = math.Max(maxX, px), math.Max(maxY, py)
+			poly.points = append(poly.points, point{x: px, y: py})
+		}
+	}
 
+	// create the hexes, leaving out the ones outside the mask
+	for row := 0; row < b.Rows; row++ {
+		for col := 0; col < b.Cols; col++ {
+			if !b.OnBoard(Coords{Col: col, Row: row}) {
+				continue
+			}
+			h := toCube(col, row)
+			cx, cy := layout.CenterPoint(h).Coords()
+			poly := &polygon{col: col, row: row, cx: cx, cy: cy, radius: radius}
+			poly.style.stroke = o.Theme.HexStroke
+			poly.style.fill = o.Theme.HexFill
+			poly.style.strokeWidth = fmt.Sprintf("%gpx", o.StrokeWidth)
+			poly.features = b.Hexes[row][col].Features
+			corners(poly, h)
 			s.hexes = append(s.hexes, poly)
 		}
 	}
@@ -165,43 +227,43 @@ func (b *Board) asSVG(mono bool) *svg {
 				continue // not a star
 			}
 
-			// assumes flat with even-q layout
-			h := hexes.QOffsetToCube(col, row, hexes.EVEN)
-			// qq, rr, ss := h.Coords()
-
+			h := toCube(col, row)
 			cx, cy := layout.CenterPoint(h).Coords()
-			poly := &polygon{cx: cx, cy: cy, radius: height / 2.0}
-
+			poly := &polygon{cx: cx, cy: cy, radius: radius}
 			poly.text = []string{hex.Name, fmt.Sprintf("( %d )", hex.EconValue)}
 			poly.addCircle = true
-
-			poly.style.stroke = "Grey"
-			poly.style.fill = starFill
-			if poly.style.fill == poly.style.stroke {
-				poly.style.stroke = "Black"
-			}
-			poly.style.strokeWidth = "2px"
-
-			for _, p := range layout.PolygonCorners(h) {
-				px, py := p.Coords()
-				if width := int(px); width > s.viewBox.width {
-					s.viewBox.width = width
-				}
-				if height := int(py); height > s.viewBox.height {
-					s.viewBox.height = height
-				}
-				poly.points = append(poly.points, point{x: px, y: py})
-			}
-
+			poly.style.stroke = o.Theme.StarStroke
+			poly.style.fill = o.Theme.StarFill
+			poly.style.strokeWidth = fmt.Sprintf("%gpx", o.StrokeWidth)
+			corners(poly, h)
 			s.polygons = append(s.polygons, poly)
+		}
+	}
 
-			for _, star := range hex.WormHoleExits {
-				sx, sy := layout.CenterPoint(hexes.QOffsetToCube(star.Coords.Col, star.Coords.Row, hexes.EVEN)).Coords()
-				s.lines = append(s.lines, [4]float64{cx, cy, sx, sy})
+	// warp lines, curved around the stars in their way, and the names of
+	// the stars, moved off the lines
+	s.lines = b.warpLines(layout, toCube, radius*0.88, radius*0.88+o.WarpWidth)
+	for i, l := range s.lines {
+		if l.curved {
+			for _, p := range l.points() {
+				maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
 			}
 		}
+		s.lines[i].head = l.arrow(radius*0.88, math.Max(10, 5*o.WarpWidth))
+	}
+	x, y := placeLabels(s.polygons, s.lines, radius*0.88, o.FontSize, o.WarpWidth)
+	maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	x, y = placeWarpLabels(s.lines, s.polygons, radius*0.88, o.FontSize)
+	maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+
+	// warp lines that leave the board
+	s.stubs = b.stubs(layout, toCube, radius, o.FontSize)
+	for _, st := range s.stubs {
+		_, _, x, y := st.bounds(o.FontSize)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
 	}
 
+	s.viewBox.width, s.viewBox.height = int(math.Ceil(maxX+o.Margin)), int(math.Ceil(maxY+o.Margin))
 	return s
 }
 
@@ -209,6 +271,10 @@ type Board struct {
 	Rows, Cols int
 	Hexes      [][]*Hex
 	Stars      map[string]*Hex
+	Mask       Mask   // hexes that are part of the board; nil for all of them
+	Wrap       Wrap   // edges that wrap around to the opposite edge
+	Stubs      []Stub // warp lines that leave the board
+	warps      map[warpKey]Warp
 }
 
 // AddWormHole adds a new exit to the hex.
@@ -230,15 +296,5 @@ func (h *Hex) Fill() string {
 	return "hsl(53, 100%, 94%)"
 }
 
-type Coords struct {
-	Col, Row int
-}
-
-func (c Coords) Less(d Coords) bool {
-	if c.Row < d.Row {
-		return true
-	} else if c.Row > d.Row {
-		return false
-	}
-	return c.Col < d.Col
-}
+// Coords are the column and row of a hex.
+type Coords = coords.Coords
@@ -0,0 +1,302 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// canvas is implemented by the renderers that don't produce SVG.
+// Coordinates are in SVG user units, with 0,0 in the upper left.
+type canvas interface {
+	fill(p path, c color.RGBA)
+	stroke(p path, c color.RGBA, width float64)
+}
+
+// pathOp is a single step in a path.
+// Op is 'M' (move to), 'L' (line to), 'Q' (quadratic curve to),
+// 'C' (cubic curve to) or 'Z' (close the current sub-path).
+type pathOp struct {
+	op  byte
+	pts []point
+}
+
+// path is a sequence of sub-paths, like the "d" attribute of an SVG path.
+type path []pathOp
+
+func polygonPath(points []point) path {
+	var p path
+	for i, pt := range points {
+		if i == 0 {
+			p = append(p, pathOp{op: 'M', pts: []point{pt}})
+		} else {
+			p = append(p, pathOp{op: 'L', pts: []point{pt}})
+		}
+	}
+	return append(p, pathOp{op: 'Z'})
+}
+
+func linePath(x1, y1, x2, y2 float64) path {
+	return path{{op: 'M', pts: []point{{x1, y1}}}, {op: 'L', pts: []point{{x2, y2}}}}
+}
+
+// dashedPath strokes a line through the points as dashes of the given
+// length with gaps between them, like an SVG stroke-dasharray.
+func dashedPath(points []point, dash, gap float64) path {
+	var p path
+	on, left := true, dash
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		length := math.Hypot(b.x-a.x, b.y-a.y)
+		for done := 0.0; done < length; {
+			step := math.Min(left, length-done)
+			from := point{a.x + (b.x-a.x)*done/length, a.y + (b.y-a.y)*done/length}
+			done += step
+			to := point{a.x + (b.x-a.x)*done/length, a.y + (b.y-a.y)*done/length}
+			if on {
+				p = append(p, pathOp{op: 'M', pts: []point{from}}, pathOp{op: 'L', pts: []point{to}})
+			}
+			if left -= step; left <= 0 {
+				on = !on
+				if left = gap; on {
+					left = dash
+				}
+			}
+		}
+	}
+	return p
+}
+
+// circlePath approximates a circle with four cubic curves.
+func circlePath(cx, cy, r float64) path {
+	k := 0.5522847498 * r
+	return path{
+		{op: 'M', pts: []point{{cx + r, cy}}},
+		{op: 'C', pts: []point{{cx + r, cy + k}, {cx + k, cy + r}, {cx, cy + r}}},
+		{op: 'C', pts: []point{{cx - k, cy + r}, {cx - r, cy + k}, {cx - r, cy}}},
+		{op: 'C', pts: []point{{cx - r, cy - k}, {cx - k, cy - r}, {cx, cy - r}}},
+		{op: 'C', pts: []point{{cx + k, cy - r}, {cx + r, cy - k}, {cx + r, cy}}},
+		{op: 'Z'},
+	}
+}
+
+var boldFont struct {
+	once sync.Once
+	font *sfnt.Font
+	err  error
+}
+
+// textPath returns the outline of the text in the bold Go font.
+// Like an SVG text element, y is the baseline; if middle is set
+// the text is centered on x, otherwise it starts at x.
+func textPath(text string, x, y, size float64, middle bool) (path, error) {
+	boldFont.once.Do(func() {
+		boldFont.font, boldFont.err = sfnt.Parse(gobold.TTF)
+	})
+	if boldFont.err != nil {
+		return nil, boldFont.err
+	}
+	f, buf := boldFont.font, &sfnt.Buffer{}
+
+	// load the glyphs at one pixel per font unit and scale them ourselves
+	ppem := fixed.I(int(f.UnitsPerEm()))
+	scale := size / float64(f.UnitsPerEm()) / 64
+
+	var p path
+	var advance fixed.Int26_6
+	var prev sfnt.GlyphIndex
+	for i, r := range text {
+		idx, err := f.GlyphIndex(buf, r)
+		if err != nil || idx == 0 {
+			if idx, err = f.GlyphIndex(buf, '?'); err != nil {
+				return nil, err
+			}
+		}
+		if i > 0 {
+			if kern, err := f.Kern(buf, prev, idx, ppem, font.HintingNone); err == nil {
+				advance += kern
+			}
+		}
+		segments, err := f.LoadGlyph(buf, idx, ppem, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, seg := range segments {
+			op := pathOp{}
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				op.op, op.pts = 'M', make([]point, 1)
+			case sfnt.SegmentOpLineTo:
+				op.op, op.pts = 'L', make([]point, 1)
+			case sfnt.SegmentOpQuadTo:
+				op.op, op.pts = 'Q', make([]point, 2)
+			case sfnt.SegmentOpCubeTo:
+				op.op, op.pts = 'C', make([]point, 3)
+			}
+			for j := range op.pts {
+				op.pts[j] = point{x: float64(advance+seg.Args[j].X) * scale, y: float64(seg.Args[j].Y) * scale}
+			}
+			p = append(p, op)
+		}
+		adv, err := f.GlyphAdvance(buf, idx, ppem, font.HintingNone)
+		if err != nil {
+			return nil, err
+		}
+		advance, prev = advance+adv, idx
+	}
+
+	dx := x
+	if middle {
+		dx -= float64(advance) * scale / 2
+	}
+	for _, op := range p {
+		for j := range op.pts {
+			op.pts[j].x, op.pts[j].y = op.pts[j].x+dx, op.pts[j].y+y
+		}
+	}
+	return p, nil
+}
+
+// strokeWidth converts an SVG stroke width like "2px" to user units.
+func strokeWidth(s string) float64 {
+	w, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil {
+		return 1
+	}
+	return w
+}
+
+// draw renders the board onto a canvas.
+// It must draw the same picture as WriteTo.
+func (s svg) draw(c canvas) error {
+	fontSize := s.fontSize
+
+	shape := func(p path, fill, stroke, width string) error {
+		if col, ok, err := parseColor(fill); err != nil {
+			return err
+		} else if ok {
+			c.fill(p, col)
+		}
+		if col, ok, err := parseColor(stroke); err != nil {
+			return err
+		} else if ok {
+			c.stroke(p, col, strokeWidth(width))
+		}
+		return nil
+	}
+	text := func(s string, x, y, size float64, fill string) error {
+		p, err := textPath(s, x, y, size, true)
+		if err != nil {
+			return err
+		}
+		return shape(p, fill, "none", "")
+	}
+
+	vb := s.viewBox
+	background := polygonPath([]point{
+		{float64(vb.minX), float64(vb.minY)}, {float64(vb.minX + vb.width), float64(vb.minY)},
+		{float64(vb.minX + vb.width), float64(vb.minY + vb.height)}, {float64(vb.minX), float64(vb.minY + vb.height)},
+	})
+	if err := shape(background, s.background, "none", ""); err != nil {
+		return err
+	}
+	for _, h := range s.hexes {
+		if len(h.points) == 0 {
+			continue
+		}
+		if err := shape(polygonPath(h.points), h.style.fill, h.style.stroke, h.style.strokeWidth); err != nil {
+			return err
+		}
+		// patterns are svg only, so features are filled with a flat color
+		for _, r := range FeatureRules {
+			if h.features&r.Feature == 0 {
+				continue
+			} else if err := shape(polygonPath(h.points), r.fill, h.style.stroke, h.style.strokeWidth); err != nil {
+				return err
+			}
+		}
+		if err := text(Coords{Col: h.col, Row: h.row}.String(), h.cx, h.cy, fontSize, s.labelColor); err != nil {
+			return err
+		}
+	}
+	for _, l := range s.lines {
+		p := linePath(l.x1, l.y1, l.x2, l.y2)
+		if l.dashed {
+			p = dashedPath(l.points(), s.warpWidth*4, s.warpWidth*2)
+		} else if l.curved {
+			p[1] = pathOp{op: 'Q', pts: []point{{l.qx, l.qy}, {l.x2, l.y2}}}
+		}
+		if err := shape(p, "none", s.warpColor, fmt.Sprint(s.warpWidth)); err != nil {
+			return err
+		}
+		if len(l.head) != 0 {
+			if err := shape(polygonPath(l.head), s.warpColor, "none", ""); err != nil {
+				return err
+			}
+		}
+	}
+	for _, st := range s.stubs {
+		if err := shape(linePath(st.x1, st.y1, st.x2, st.y2), "none", s.warpColor, fmt.Sprint(s.warpWidth)); err != nil {
+			return err
+		} else if err := text(st.label, st.lx, st.ly, fontSize, s.warpColor); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.polygons {
+		if err := shape(circlePath(p.cx, p.cy, p.radius*0.88), p.style.fill, p.style.stroke, p.style.strokeWidth); err != nil {
+			return err
+		}
+		yOffset := fontSize * 0.6
+		for i, t := range p.text {
+			var err error
+			if i == 0 {
+				err = text(t, p.lx, p.ly, fontSize, s.textColor)
+			} else {
+				err = text(t, p.cx, p.cy+yOffset*3, fontSize+2, s.textColor)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, l := range s.lines {
+		if l.label == "" {
+			continue
+		}
+		if err := text(l.label, l.lx, l.ly, fontSize, s.warpColor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
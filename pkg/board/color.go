@@ -0,0 +1,108 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// namedColors are the SVG color names used by the map renderers.
+var namedColors = map[string]color.RGBA{
+	"black":     {0x00, 0x00, 0x00, 0xff},
+	"grey":      {0x80, 0x80, 0x80, 0xff},
+	"gray":      {0x80, 0x80, 0x80, 0xff},
+	"lightblue": {0xad, 0xd8, 0xe6, 0xff},
+	"white":     {0xff, 0xff, 0xff, 0xff},
+}
+
+// parseColor converts an SVG color to an RGBA color.
+// It accepts "none", color names, "#rgb", "#rrggbb", and "hsl(h, s%, l%)".
+// For "none", it returns ok set to false.
+func parseColor(s string) (c color.RGBA, ok bool, err error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "none" || s == "transparent" {
+		return color.RGBA{}, false, nil
+	} else if c, ok := namedColors[s]; ok {
+		return c, true, nil
+	} else if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 6 || err != nil {
+			return color.RGBA{}, false, fmt.Errorf("board: invalid color %q", s)
+		}
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true, nil
+	} else if strings.HasPrefix(s, "hsl(") && strings.HasSuffix(s, ")") {
+		args := strings.Split(s[4:len(s)-1], ",")
+		if len(args) != 3 {
+			return color.RGBA{}, false, fmt.Errorf("board: invalid color %q", s)
+		}
+		var hsl [3]float64
+		for i, arg := range args {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(arg), "%"), 64)
+			if err != nil {
+				return color.RGBA{}, false, fmt.Errorf("board: invalid color %q", s)
+			}
+			hsl[i] = v
+		}
+		return hslToRGBA(hsl[0], hsl[1]/100, hsl[2]/100), true, nil
+	}
+	return color.RGBA{}, false, fmt.Errorf("board: unknown color %q", s)
+}
+
+// hslToRGBA converts hue (degrees), saturation and lightness (0 to 1) to RGB.
+func hslToRGBA(h, s, l float64) color.RGBA {
+	h = math.Mod(math.Mod(h, 360)+360, 360) / 360
+	s, l = math.Max(0, math.Min(1, s)), math.Max(0, math.Min(1, l))
+	hue := func(p, q, t float64) float64 {
+		if t < 0 {
+			t++
+		} else if t > 1 {
+			t--
+		}
+		switch {
+		case t < 1.0/6:
+			return p + (q-p)*6*t
+		case t < 1.0/2:
+			return q
+		case t < 2.0/3:
+			return p + (q-p)*(2.0/3-t)*6
+		}
+		return p
+	}
+	q := l + s - l*s
+	if l < 0.5 {
+		q = l * (1 + s)
+	}
+	p := 2*l - q
+	r, g, b := hue(p, q, h+1.0/3), hue(p, q, h), hue(p, q, h-1.0/3)
+	return color.RGBA{R: uint8(math.Round(r * 255)), G: uint8(math.Round(g * 255)), B: uint8(math.Round(b * 255)), A: 0xff}
+}
@@ -0,0 +1,219 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Crossing is a place where the map is hard to read: a warp line that
+// crosses another warp line, or that passes through a star it doesn't
+// lead to.
+type Crossing struct {
+	From, To string    // the stars at the ends of the warp line
+	Warp     [2]string // the warp line it crosses, if it crosses one
+	Star     string    // the star it passes through, if it passes through one
+}
+
+// String describes the crossing.
+func (c Crossing) String() string {
+	if c.Star != "" {
+		return fmt.Sprintf("warp from %q to %q passes through %q", c.From, c.To, c.Star)
+	}
+	return fmt.Sprintf("warp from %q to %q crosses the warp from %q to %q", c.From, c.To, c.Warp[0], c.Warp[1])
+}
+
+// Crossings returns the warp lines that cross each other or pass through
+// stars, as RenderSVG draws them with the geometry. Lines that would pass
+// through a star are curved around it when they can be, so only the ones
+// that can't be are reported. Lines that meet at a star don't cross.
+// Each crossing is listed once, sorted by the names of the stars.
+func (b *Board) Crossings(g Geometry) []Crossing {
+	o := DefaultRenderOptions(false)
+	o.Orientation, o.Offset = g.Orientation, g.Offset
+	o = o.WithDefaults()
+	radius := math.Sqrt(3) / 2 * o.HexSize * 0.88
+	layout, toCube := b.layout(o)
+
+	// each warp is drawn from both ends, so keep one of them, unless it is
+	// drawn in two halves across an edge of the board
+	var lines []warpLine
+	var stars []starCircle
+	for _, l := range b.warpLines(layout, toCube, radius, radius+o.WarpWidth) {
+		if l.oneWay || l.wrapped || l.from < l.to {
+			lines = append(lines, l)
+		}
+	}
+	for name, hex := range b.Stars {
+		x, y := layout.CenterPoint(toCube(hex.Coords.Col, hex.Coords.Row)).Coords()
+		stars = append(stars, starCircle{name: name, c: point{x: x, y: y}})
+	}
+
+	var list []Crossing
+	for i, l := range lines {
+		pts := l.visible(radius)
+		for _, s := range stars {
+			if s.name == l.from || s.name == l.to {
+				continue
+			}
+			for k := 1; k < len(pts); k++ {
+				if distanceToSegment(s.c, pts[k-1], pts[k]) < radius {
+					list = append(list, Crossing{From: l.from, To: l.to, Star: s.name})
+					break
+				}
+			}
+		}
+		for _, m := range lines[i+1:] {
+			if l.from == m.from || l.from == m.to || l.to == m.from || l.to == m.to {
+				continue
+			}
+			if polylinesCross(pts, m.visible(radius)) {
+				c := Crossing{From: l.from, To: l.to, Warp: [2]string{m.from, m.to}}
+				if m.from+"\n"+m.to < l.from+"\n"+l.to {
+					c = Crossing{From: m.from, To: m.to, Warp: [2]string{l.from, l.to}}
+				}
+				list = append(list, c)
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.From != b.From {
+			return a.From < b.From
+		} else if a.To != b.To {
+			return a.To < b.To
+		} else if a.Star != b.Star {
+			return a.Star < b.Star
+		} else if a.Warp[0] != b.Warp[0] {
+			return a.Warp[0] < b.Warp[0]
+		}
+		return a.Warp[1] < b.Warp[1]
+	})
+	return list
+}
+
+// ValidateCrossings warns about warp lines that cross each other or pass
+// through stars when the map is drawn with the geometry. Map data that
+// can't be made into a board isn't checked; the other validators report it.
+func ValidateCrossings(nodes []Node, mask Mask, w Wrap, g Geometry) []Diagnostic {
+	b, err := FromNodesMasked(nodes, mask)
+	if err != nil {
+		return nil
+	} else if err = b.SetWrap(w, g); err != nil {
+		return nil
+	}
+	records := make(map[string]int)
+	for i := len(nodes) - 1; i >= 0; i-- {
+		records[nodes[i].Name] = i
+	}
+	var diagnostics []Diagnostic
+	for _, c := range b.Crossings(g) {
+		d := Diagnostic{Severity: SeverityWarning, Record: records[c.From], Field: "warps"}
+		if c.Star != "" {
+			d.Message = fmt.Sprintf("warp to %q passes through %q", c.To, c.Star)
+		} else {
+			d.Message = fmt.Sprintf("warp to %q crosses the warp from %q to %q", c.To, c.Warp[0], c.Warp[1])
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// distance returns the distance between two points.
+func distance(a, b point) float64 {
+	return math.Hypot(b.x-a.x, b.y-a.y)
+}
+
+// distanceToSegment returns the distance from p to the nearest point
+// on the segment from a to b.
+func distanceToSegment(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	if dx == 0 && dy == 0 {
+		return distance(p, a)
+	}
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/(dx*dx+dy*dy)))
+	return distance(p, point{x: a.x + t*dx, y: a.y + t*dy})
+}
+
+// orientation is positive if c is to the left of the line from a to b,
+// negative if it is to the right and zero if it is on the line.
+func orientation(a, b, c point) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+// segmentsCross reports whether the segments from a to b and from c to d
+// cross. Segments that only touch, or lie along the same line, don't.
+func segmentsCross(a, b, c, d point) bool {
+	d1, d2 := orientation(c, d, a), orientation(c, d, b)
+	d3, d4 := orientation(a, b, c), orientation(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// polylinesCross reports whether any pieces of two lines cross.
+func polylinesCross(a, b []point) bool {
+	for i := 1; i < len(a); i++ {
+		for j := 1; j < len(b); j++ {
+			if segmentsCross(a[i-1], a[i], b[j-1], b[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clipToBox returns the part of the segment from a to b that is inside
+// the box, using the Liang-Barsky line clipping algorithm. It returns
+// false if the segment misses the box.
+func clipToBox(a, b point, minX, minY, maxX, maxY float64) (point, point, bool) {
+	t0, t1 := 0.0, 1.0
+	dx, dy := b.x-a.x, b.y-a.y
+	for _, edge := range [4][2]float64{{-dx, a.x - minX}, {dx, maxX - a.x}, {-dy, a.y - minY}, {dy, maxY - a.y}} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false // parallel to the edge and outside it
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+	return point{x: a.x + t0*dx, y: a.y + t0*dy}, point{x: a.x + t1*dx, y: a.y + t1*dy}, true
+}
+
+// circleHitsBox reports whether a circle overlaps the box.
+func circleHitsBox(c point, r, minX, minY, maxX, maxY float64) bool {
+	x, y := math.Max(minX, math.Min(c.x, maxX)), math.Max(minY, math.Min(c.y, maxY))
+	return math.Hypot(c.x-x, c.y-y) < r
+}
@@ -0,0 +1,149 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// crossingNodes is a map where the warp from Alpha to Gamma runs through
+// Beta and across the warp from Delta to Epsilon, and the warp from Zeta
+// to Eta runs through Theta but has room to curve around it.
+func crossingNodes() []Node {
+	return []Node{
+		{Name: "Alpha", Col: 2, Row: 1, Warps: []string{"Gamma"}},
+		{Name: "Beta", Col: 2, Row: 3},
+		{Name: "Gamma", Col: 2, Row: 5},
+		{Name: "Delta", Col: 1, Row: 2, Warps: []string{"Epsilon"}},
+		{Name: "Epsilon", Col: 3, Row: 2},
+		{Name: "Zeta", Col: 5, Row: 1, Warps: []string{"Eta"}},
+		{Name: "Eta", Col: 5, Row: 6},
+		{Name: "Theta", Col: 5, Row: 3},
+	}
+}
+
+func TestCrossings(t *testing.T) {
+	b, err := FromNodes(crossingNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Crossing{
+		{From: "Alpha", To: "Gamma", Warp: [2]string{"Delta", "Epsilon"}},
+		{From: "Alpha", To: "Gamma", Star: "Beta"},
+	}
+	if got := b.Crossings(Geometry{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v\ngot  %v", want, got)
+	}
+	if got := NewStandardBoard().Crossings(Geometry{}); len(got) != 0 {
+		t.Errorf("standard: want no crossings, got %v", got)
+	}
+
+	// the line from Zeta to Eta curves around Theta, the same way from both ends
+	s := b.asSVG(DefaultRenderOptions(false))
+	var curves []warpLine
+	for _, l := range s.lines {
+		if l.curved {
+			curves = append(curves, l)
+		}
+	}
+	if len(curves) != 2 || curves[0].qx != curves[1].qx || curves[0].qy != curves[1].qy {
+		t.Fatalf("want the two lines from Zeta to Eta curved the same way, got %+v", curves)
+	}
+	if !strings.Contains(s.String(), `<path d="M `) {
+		t.Errorf("svg: want a path for the curve")
+	}
+
+	// Beta's name is in its circle, which hides the line through it
+	for _, p := range s.polygons {
+		if p.lx != p.cx || p.ly != p.cy-s.fontSize*0.6 {
+			t.Errorf("%s: want the name in the circle, got %g, %g", p.text[0], p.lx, p.ly)
+		}
+	}
+}
+
+func TestPlaceLabels(t *testing.T) {
+	const radius, fontSize = 40.0, 11.0
+	star := &polygon{cx: 100, cy: 100, radius: radius, text: []string{"Longname Star", "( 1 )"}}
+	// a line across the top half of the circle, wider than the circle, so
+	// the name can't go there or beside the circle
+	y := star.cy - fontSize
+	line := warpLine{x1: -200, y1: y, x2: 400, y2: y, from: "A", to: "B"}
+	maxX, maxY := placeLabels([]*polygon{star}, []warpLine{line}, radius, fontSize, 2)
+	if star.lx != star.cx || star.ly >= star.cy-radius {
+		t.Errorf("want the name above the circle, got %g, %g", star.lx, star.ly)
+	} else if maxX <= star.cx || maxY <= star.ly {
+		t.Errorf("want the bounds of the moved name, got %g, %g", maxX, maxY)
+	}
+
+	// a star at the top of the drawing moves below its circle instead
+	star = &polygon{cx: 100, cy: radius, radius: radius, text: []string{"Longname Star"}}
+	line.y1, line.y2 = star.cy-fontSize, star.cy-fontSize
+	placeLabels([]*polygon{star}, []warpLine{line}, radius, fontSize, 2)
+	if star.ly <= star.cy+radius {
+		t.Errorf("top: want the name below the circle, got %g, %g", star.lx, star.ly)
+	}
+}
+
+func TestValidateCrossings(t *testing.T) {
+	got := ValidateCrossings(crossingNodes(), nil, WrapNone, Geometry{})
+	want := []Diagnostic{
+		{Severity: SeverityWarning, Record: 0, Field: "warps", Message: `warp to "Gamma" crosses the warp from "Delta" to "Epsilon"`},
+		{Severity: SeverityWarning, Record: 0, Field: "warps", Message: `warp to "Gamma" passes through "Beta"`},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v\ngot  %v", want, got)
+	}
+	// map data with errors isn't checked
+	if got := ValidateCrossings([]Node{{Name: "Ur", Col: -1}}, nil, WrapNone, Geometry{}); got != nil {
+		t.Errorf("bad nodes: want nil, got %v", got)
+	}
+}
+
+func TestSegments(t *testing.T) {
+	p := func(x, y float64) point { return point{x: x, y: y} }
+	if !segmentsCross(p(0, 0), p(2, 2), p(0, 2), p(2, 0)) {
+		t.Errorf("want an X to cross")
+	}
+	if segmentsCross(p(0, 0), p(2, 2), p(2, 2), p(4, 0)) || segmentsCross(p(0, 0), p(1, 0), p(2, 0), p(3, 0)) {
+		t.Errorf("want segments that touch or lie along one line not to cross")
+	}
+	if d := distanceToSegment(p(1, 1), p(0, 0), p(2, 0)); d != 1 {
+		t.Errorf("distance: want 1, got %g", d)
+	}
+	if d := distanceToSegment(p(5, 4), p(0, 0), p(2, 0)); d != 5 {
+		t.Errorf("distance past the end: want 5, got %g", d)
+	}
+	if a, b, ok := clipToBox(p(-1, 1), p(3, 1), 0, 0, 2, 2); !ok || a != p(0, 1) || b != p(2, 1) {
+		t.Errorf("clip: got %v %v %v", a, b, ok)
+	}
+	if _, _, ok := clipToBox(p(-1, 3), p(3, 3), 0, 0, 2, 2); ok {
+		t.Errorf("clip: want a miss")
+	}
+	if !circleHitsBox(p(3, 1), 1.5, 0, 0, 2, 2) || circleHitsBox(p(3, 3), 1, 0, 0, 2, 2) {
+		t.Errorf("circle: wrong overlap")
+	}
+}
@@ -0,0 +1,202 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/hexes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Nodes returns the stars on the board in the map data format, sorted by name.
+// Warps that go both ways are listed on both of the stars they connect
+// and one-way warps on the star they start from, so
+// FromNodes(b.Nodes()) returns a copy of the board.
+func (b *Board) Nodes() []Node {
+	var nodes []Node
+	for name, hex := range b.Stars {
+		nodes = append(nodes, Node{Name: name, Col: hex.Coords.Col, Row: hex.Coords.Row, EconValue: hex.EconValue, Warps: b.targets(name)})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// centers returns the center of each star on a map drawn with the default options.
+// The y axis points down, as it does in SVG.
+func (b *Board) centers() map[string][2]float64 {
+	o := DefaultRenderOptions(false).WithDefaults()
+	layout, toCube := o.layout(hexes.NewPoint(0, 0))
+	centers := make(map[string][2]float64)
+	for name, hex := range b.Stars {
+		x, y := layout.CenterPoint(toCube(hex.Coords.Col, hex.Coords.Row)).Coords()
+		centers[name] = [2]float64{x, y}
+	}
+	return centers
+}
+
+// WriteJSON writes the board in the JSON map data format described in the README.
+func (b *Board) WriteJSON(w io.Writer) error {
+	nodes := b.Nodes()
+	for i := range nodes {
+		if nodes[i].Warps == nil {
+			nodes[i].Warps = []string{}
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Nodes    []Node    `json:"nodes"`
+		Features []Terrain `json:"features,omitempty"`
+		Mask     Mask      `json:"mask,omitempty"`
+		Wrap     Wrap      `json:"wrap,omitempty"`
+	}{Nodes: nodes, Features: b.Terrain(), Mask: b.Mask, Wrap: b.Wrap})
+}
+
+// WriteCSV writes the board in the CSV map data format described in the README.
+func (b *Board) WriteCSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	field := func(s string) string {
+		if strings.ContainsAny(s, ",\"\r\n") || strings.TrimSpace(s) != s {
+			return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+		}
+		return s
+	}
+	for _, n := range b.Nodes() {
+		_, _ = fmt.Fprintf(bw, "%s, %d, %d, %d", field(n.Name), n.Col, n.Row, n.EconValue)
+		for _, target := range n.Warps {
+			_, _ = bw.WriteString(", " + field(target))
+		}
+		_ = bw.WriteByte('\n')
+	}
+	for _, t := range b.Terrain() {
+		_, _ = fmt.Fprintf(bw, "@%s, %d, %d\n", t.Feature, t.Col, t.Row)
+	}
+	return bw.Flush()
+}
+
+// WriteGraphML writes the warp graph as GraphML, for tools like Gephi and yEd.
+// Each star is a node with its name, hex coordinates, economic value and
+// the position of its center on the map. Each warp line is an edge with
+// its cost and label; one-way warps are directed edges.
+func (b *Board) WriteGraphML(w io.Writer) error {
+	x := newXMLWriter(w)
+	x.str(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	x.str(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">` + "\n")
+	for _, key := range []struct{ id, of, name, typ string }{
+		{"label", "node", "label", "string"},
+		{"col", "node", "col", "int"},
+		{"row", "node", "row", "int"},
+		{"econ", "node", "econ-value", "int"},
+		{"x", "node", "x", "double"},
+		{"y", "node", "y", "double"},
+		{"cost", "edge", "cost", "int"},
+		{"warp", "edge", "label", "string"},
+	} {
+		x.str(`  <key id="`).str(key.id).str(`" for="`).str(key.of).str(`" attr.name="`).str(key.name).str(`" attr.type="`).str(key.typ).str("\"/>\n")
+	}
+	x.str(`  <graph id="wow" edgedefault="undirected">` + "\n")
+	centers := b.centers()
+	for _, n := range b.Nodes() {
+		x.str(`    <node id="`).escape(n.Name).str("\">\n")
+		x.str(`      <data key="label">`).escape(n.Name).str("</data>\n")
+		x.str(`      <data key="col">`).int(n.Col).str("</data>\n")
+		x.str(`      <data key="row">`).int(n.Row).str("</data>\n")
+		x.str(`      <data key="econ">`).int(n.EconValue).str("</data>\n")
+		x.str(`      <data key="x">`).general(round3(centers[n.Name][0])).str("</data>\n")
+		x.str(`      <data key="y">`).general(round3(centers[n.Name][1])).str("</data>\n")
+		x.str("    </node>\n")
+	}
+	for _, e := range b.Warps() {
+		x.str(`    <edge source="`).escape(e.From).str(`" target="`).escape(e.To)
+		if e.OneWay {
+			x.str(`" directed="true`)
+		}
+		x.str("\">\n")
+		x.str(`      <data key="cost">`).int(e.Jumps()).str("</data>\n")
+		if e.Label != "" {
+			x.str(`      <data key="warp">`).escape(e.Label).str("</data>\n")
+		}
+		x.str("    </edge>\n")
+	}
+	x.str("  </graph>\n</graphml>\n")
+	_, err := x.flush()
+	return err
+}
+
+// WriteDOT writes the warp graph in the Graphviz DOT language.
+// The stars are pinned to the centers of their hexes, so
+// "neato -n2 -Tsvg" draws them where they are on the map. One-way
+// warps have an arrow and warps that take more than one jump are dashed.
+func (b *Board) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+	_, _ = bw.WriteString("graph wow {\n")
+	_, _ = bw.WriteString("  layout=neato;\n")
+	_, _ = bw.WriteString("  node [shape=circle, fixedsize=true, width=1.2, fontsize=10];\n")
+	centers := b.centers()
+	for _, n := range b.Nodes() {
+		// dot's y axis points up
+		c := centers[n.Name]
+		_, _ = fmt.Fprintf(bw, "  %s [label=%s, col=%d, row=%d, econ=%d, pos=\"%s,%s!\"];\n",
+			quote(n.Name), quote(fmt.Sprintf("%s\n(%d)", n.Name, n.EconValue)), n.Col, n.Row, n.EconValue,
+			strconv.FormatFloat(round3(c[0]), 'f', -1, 64), strconv.FormatFloat(round3(-c[1]), 'f', -1, 64))
+	}
+	for _, e := range b.Warps() {
+		var attrs []string
+		if e.OneWay {
+			attrs = append(attrs, "dir=forward")
+		}
+		if e.Cost > 1 {
+			attrs = append(attrs, fmt.Sprintf("cost=%d, style=dashed", e.Cost))
+		}
+		if e.Label != "" {
+			attrs = append(attrs, "label="+quote(e.Label))
+		}
+		if len(attrs) == 0 {
+			_, _ = fmt.Fprintf(bw, "  %s -- %s;\n", quote(e.From), quote(e.To))
+		} else {
+			_, _ = fmt.Fprintf(bw, "  %s -- %s [%s];\n", quote(e.From), quote(e.To), strings.Join(attrs, ", "))
+		}
+	}
+	_, _ = bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// round3 rounds to three decimal places, which is plenty for positions.
+func round3(f float64) float64 {
+	f = math.Round(f*1000) / 1000
+	if f == 0 {
+		return 0 // no negative zero
+	}
+	return f
+}
@@ -0,0 +1,137 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameBoard reports whether two boards have the same stars and warps.
+func sameBoard(a, b *Board) bool {
+	return reflect.DeepEqual(a.Nodes(), b.Nodes())
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	b := NewStandardBoard()
+	b.AddStar(`Kish, "the Great"`, 20, 1, 2)
+	if err := b.AddWormHole("Ur", `Kish, "the Great"`); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := b.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var data struct {
+		Nodes []Node `json:"nodes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	} else if got, err := FromNodes(data.Nodes); err != nil {
+		t.Fatal(err)
+	} else if !sameBoard(b, got) {
+		t.Errorf("json: round trip changed the board")
+	}
+
+	buf.Reset()
+	if err := b.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Adab, 6, 6, 0, Byblos, Erech, Khafa\n") {
+		t.Errorf("csv: want the README format, got\n%s", buf.String())
+	}
+	nodes, diagnostics := ParseCSV(buf)
+	if len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	} else if got, err := FromNodes(nodes); err != nil {
+		t.Fatal(err)
+	} else if !sameBoard(b, got) {
+		t.Errorf("csv: round trip changed the board")
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	b := NewStandardBoard()
+	buf := &bytes.Buffer{}
+	if err := b.WriteGraphML(buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			Nodes []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Keys) != 8 || len(doc.Graph.Nodes) != len(b.Stars) || len(doc.Graph.Edges) != len(b.Warps()) {
+		t.Errorf("want 8 keys, %d nodes and %d edges, got %d, %d and %d", len(b.Stars), len(b.Warps()), len(doc.Keys), len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+	if n := doc.Graph.Nodes[0]; n.ID != "Adab" || n.Data[1].Value != "6" || n.Data[3].Value != "0" {
+		t.Errorf("Adab: got %+v", n)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	b := NewBoard(2, 2)
+	b.AddStar(`Ur "Old"`, 1, 1, 4)
+	b.AddStar("Adab", 2, 2, 1)
+	if err := b.AddWormHole(`Ur "Old"`, "Adab"); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := b.WriteDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `graph wow {
+  layout=neato;
+  node [shape=circle, fixedsize=true, width=1.2, fontsize=10];
+  "Adab" [label="Adab\n(1)", col=2, row=2, econ=1, pos="165,-190.526!"];
+  "Ur \"Old\"" [label="Ur \"Old\"\n(4)", col=1, row=1, econ=4, pos="82.5,-47.631!"];
+  "Adab" -- "Ur \"Old\"";
+}
+`
+	if buf.String() != want {
+		t.Errorf("want\n%s\ngot\n%s", want, buf.String())
+	}
+}
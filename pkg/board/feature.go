@@ -0,0 +1,261 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Features is the set of terrain features in a hex.
+type Features uint8
+
+const (
+	Nebula Features = 1 << iota
+	Asteroids
+	BlackHole
+	Blocked
+
+	AllFeatures = Nebula | Asteroids | BlackHole | Blocked
+)
+
+// FeatureRule says how a feature changes movement and combat.
+type FeatureRule struct {
+	Name string
+	// MoveCost is the number of movement points needed to enter the hex.
+	// Hexes without features cost 1, and a hex with more than one feature
+	// costs the most of them.
+	MoveCost int
+	// Impassable hexes can't be entered at all, and can't hold stars.
+	Impassable bool
+	// Attack and Defense are added to the combat factors of ships in the hex.
+	Attack, Defense int
+	// BlocksSight hides the hexes behind the hex (see Board.FieldOfView).
+	BlocksSight bool
+
+	fill string // used instead of the svg pattern in png and pdf files
+}
+
+// FeatureRules are the rules for each feature, in the order of the constants.
+var FeatureRules = []struct {
+	Feature Features
+	FeatureRule
+}{
+	{Nebula, FeatureRule{Name: "nebula", MoveCost: 2, Attack: -1, Defense: 1, BlocksSight: true, fill: "#d8c3ea"}},
+	{Asteroids, FeatureRule{Name: "asteroids", MoveCost: 3, Defense: 2, fill: "#c9c2b8"}},
+	{BlackHole, FeatureRule{Name: "black-hole", Impassable: true, BlocksSight: true, fill: "#2b2b2b"}},
+	{Blocked, FeatureRule{Name: "blocked", Impassable: true, BlocksSight: true, fill: "#9a9a9a"}},
+}
+
+// ParseFeatures returns the features named in a list separated by commas
+// or plus signs. "all" is every feature.
+func ParseFeatures(list string) (Features, error) {
+	var f Features
+	for _, name := range strings.FieldsFunc(strings.ToLower(list), func(r rune) bool { return r == ',' || r == '+' }) {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			f |= AllFeatures
+			continue
+		}
+		found := false
+		for _, r := range FeatureRules {
+			if r.Name == name {
+				f, found = f|r.Feature, true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("board: unknown feature %q", name)
+		}
+	}
+	return f, nil
+}
+
+// List returns the features in the set, one at a time.
+func (f Features) List() []Features {
+	var list []Features
+	for _, r := range FeatureRules {
+		if f&r.Feature != 0 {
+			list = append(list, r.Feature)
+		}
+	}
+	return list
+}
+
+// String returns the names of the features, joined with plus signs.
+func (f Features) String() string {
+	var names []string
+	for _, r := range FeatureRules {
+		if f&r.Feature != 0 {
+			names = append(names, r.Name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// MoveCost returns the movement points needed to enter a hex with the
+// features, and false if it can't be entered.
+func (f Features) MoveCost() (int, bool) {
+	cost := 1
+	for _, r := range FeatureRules {
+		if f&r.Feature == 0 {
+			continue
+		} else if r.Impassable {
+			return 0, false
+		} else if r.MoveCost > cost {
+			cost = r.MoveCost
+		}
+	}
+	return cost, true
+}
+
+// CombatModifiers returns the total attack and defense modifiers of the features.
+func (f Features) CombatModifiers() (attack, defense int) {
+	for _, r := range FeatureRules {
+		if f&r.Feature != 0 {
+			attack, defense = attack+r.Attack, defense+r.Defense
+		}
+	}
+	return attack, defense
+}
+
+// impassable reports whether any of the features can't be entered.
+func (f Features) impassable() bool {
+	_, ok := f.MoveCost()
+	return !ok
+}
+
+// BlocksSight reports whether any of the features block sight.
+func (f Features) BlocksSight() bool {
+	for _, r := range FeatureRules {
+		if f&r.Feature != 0 && r.BlocksSight {
+			return true
+		}
+	}
+	return false
+}
+
+// AddFeature adds features to a hex on the board.
+func (b *Board) AddFeature(col, row int, f Features) error {
+	c := Coords{Col: col, Row: row}
+	if !b.OnBoard(c) {
+		return fmt.Errorf("board: %d, %d is not on the board", col, row)
+	}
+	hex := b.Hexes[row][col]
+	if hex.HasStar && f.impassable() {
+		return fmt.Errorf("board: star %q can't be in a %s hex", hex.Name, f)
+	}
+	hex.Features |= f
+	return nil
+}
+
+// MoveCost returns the movement points needed to enter a hex,
+// and false if it can't be entered.
+func (b *Board) MoveCost(c Coords) (int, bool) {
+	if !b.OnBoard(c) {
+		return 0, false
+	}
+	return b.Hexes[c.Row][c.Col].Features.MoveCost()
+}
+
+// CombatModifiers returns the attack and defense modifiers for ships in a hex.
+func (b *Board) CombatModifiers(c Coords) (attack, defense int) {
+	if !b.OnBoard(c) {
+		return 0, 0
+	}
+	return b.Hexes[c.Row][c.Col].Features.CombatModifiers()
+}
+
+// Terrain is a feature in a hex, as it appears in map data.
+type Terrain struct {
+	Feature string `json:"feature"`
+	Col     int    `json:"col"`
+	Row     int    `json:"row"`
+}
+
+// Terrain returns the features on the board, one per hex and feature,
+// sorted by row and then column.
+func (b *Board) Terrain() []Terrain {
+	var list []Terrain
+	for row := range b.Hexes {
+		for col, hex := range b.Hexes[row] {
+			for _, f := range hex.Features.List() {
+				list = append(list, Terrain{Feature: f.String(), Col: col, Row: row})
+			}
+		}
+	}
+	return list
+}
+
+// AddTerrain adds the features in the map data to the board.
+func (b *Board) AddTerrain(terrain []Terrain) error {
+	for _, t := range terrain {
+		f, err := ParseFeatures(t.Feature)
+		if err != nil {
+			return err
+		} else if err := b.AddFeature(t.Col, t.Row, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateTerrain checks the features in map data. Problems are reported
+// for the whole map, naming the feature and its hex.
+func ValidateTerrain(nodes []Node, terrain []Terrain, mask Mask) []Diagnostic {
+	var diagnostics []Diagnostic
+	problem := func(severity Severity, t Terrain, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Severity: severity,
+			Record:   -1,
+			Field:    "features",
+			Message:  fmt.Sprintf("%s at %v: ", t.Feature, Coords{Col: t.Col, Row: t.Row}) + fmt.Sprintf(format, args...),
+		})
+	}
+	stars := make(map[Coords]string)
+	for _, n := range nodes {
+		stars[Coords{Col: n.Col, Row: n.Row}] = n.Name
+	}
+	seen := make(map[Terrain]bool)
+	for _, t := range terrain {
+		c := Coords{Col: t.Col, Row: t.Row}
+		f, err := ParseFeatures(t.Feature)
+		if err != nil || f == 0 {
+			problem(SeverityError, t, "unknown feature")
+			continue
+		}
+		if t.Col < 1 || t.Col > MaxCols || t.Row < 1 || t.Row > MaxRows {
+			problem(SeverityError, t, "col must be 1 to %d and row 1 to %d", MaxCols, MaxRows)
+		} else if !mask.Contains(c) {
+			problem(SeverityError, t, "hex is outside the mask")
+		} else if name, ok := stars[c]; ok && f.impassable() {
+			problem(SeverityError, t, "star %q can't be in an impassable hex", name)
+		}
+		if seen[t] {
+			problem(SeverityWarning, t, "listed more than once")
+		}
+		seen[t] = true
+	}
+	return diagnostics
+}
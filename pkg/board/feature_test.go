@@ -0,0 +1,196 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeatures(t *testing.T) {
+	f, err := ParseFeatures("nebula+asteroids")
+	if err != nil || f != Nebula|Asteroids || f.String() != "nebula+asteroids" {
+		t.Errorf("parse: got %s, %v", f, err)
+	}
+	if f, err := ParseFeatures("all"); err != nil || f != AllFeatures {
+		t.Errorf("all: got %s, %v", f, err)
+	}
+	if _, err := ParseFeatures("wormhole"); err == nil {
+		t.Errorf("wormhole: want error")
+	}
+	for _, tc := range []struct {
+		f               Features
+		cost            int
+		ok              bool
+		attack, defense int
+	}{
+		{0, 1, true, 0, 0},
+		{Nebula, 2, true, -1, 1},
+		{Nebula | Asteroids, 3, true, -1, 3},
+		{Asteroids | BlackHole, 0, false, 0, 2},
+		{Blocked, 0, false, 0, 0},
+	} {
+		cost, ok := tc.f.MoveCost()
+		attack, defense := tc.f.CombatModifiers()
+		if cost != tc.cost || ok != tc.ok || attack != tc.attack || defense != tc.defense {
+			t.Errorf("%s: got %d %v %+d/%+d, want %d %v %+d/%+d", tc.f, cost, ok, attack, defense, tc.cost, tc.ok, tc.attack, tc.defense)
+		}
+	}
+}
+
+func TestFeaturePath(t *testing.T) {
+	// a wall across column 3, including the border, with a gap of nebula in row 3
+	b := NewBoard(5, 5)
+	for row := 0; row < b.Rows; row++ {
+		_ = b.AddFeature(3, row, Blocked)
+	}
+	b.Hexes[3][3].Features = Nebula
+	g := Geometry{}
+
+	path, err := b.Path(g, Coords{Col: 2, Row: 3}, Coords{Col: 4, Row: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Coords{{Col: 2, Row: 3}, {Col: 3, Row: 3}, {Col: 4, Row: 3}}; !reflect.DeepEqual(path, want) {
+		t.Errorf("through the nebula: got %v, want %v", path, want)
+	} else if cost := b.PathCost(path); cost != 3 {
+		t.Errorf("through the nebula: cost %d, want 3", cost)
+	}
+
+	// asteroids cost more than the nebula, but are still passable
+	b.Hexes[3][3].Features = Asteroids
+	if path, err = b.Path(g, Coords{Col: 2, Row: 3}, Coords{Col: 4, Row: 3}); err != nil {
+		t.Fatal(err)
+	} else if cost := b.PathCost(path); cost != 4 {
+		t.Errorf("through the asteroids: got %v costing %d, want 4", path, cost)
+	}
+	if attack, defense := b.CombatModifiers(Coords{Col: 3, Row: 3}); attack != 0 || defense != 2 {
+		t.Errorf("asteroids: got %+d/%+d, want +0/+2", attack, defense)
+	}
+
+	// closing the gap leaves no route, and nothing can enter the wall
+	b.Hexes[3][3].Features = BlackHole
+	if _, err := b.Path(g, Coords{Col: 2, Row: 3}, Coords{Col: 4, Row: 3}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("closed wall: got %v, want %v", err, ErrNoRoute)
+	}
+	if _, err := b.Path(g, Coords{Col: 2, Row: 3}, Coords{Col: 3, Row: 3}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("into the wall: got %v, want %v", err, ErrNoRoute)
+	}
+}
+
+func TestFeatureMapData(t *testing.T) {
+	input := "Ur, 1, 1, 4, Adab\n@nebula, 1, 1\nAdab, 3, 2, 1\n@blocked, 2, 2\n@asteroids, 2, 2\n"
+	nodes, terrain, diagnostics := ParseMapCSV(strings.NewReader(input))
+	if len(diagnostics) != 0 {
+		t.Fatalf("diagnostics: %v", diagnostics)
+	}
+	if len(nodes) != 2 || len(terrain) != 3 {
+		t.Fatalf("got %d nodes and %d features, want 2 and 3", len(nodes), len(terrain))
+	}
+	if d := ValidateTerrain(nodes, terrain, nil); len(d) != 0 {
+		t.Errorf("validate: %v", d)
+	}
+	b, err := FromNodes(nodes)
+	if err != nil {
+		t.Fatal(err)
+	} else if err := b.AddTerrain(terrain); err != nil {
+		t.Fatal(err)
+	}
+
+	// the features come back out in both formats, after the stars
+	buf := &bytes.Buffer{}
+	_ = b.WriteCSV(buf)
+	if want := "Adab, 3, 2, 1, Ur\nUr, 1, 1, 4, Adab\n@nebula, 1, 1\n@asteroids, 2, 2\n@blocked, 2, 2\n"; buf.String() != want {
+		t.Errorf("csv: got\n%s\nwant\n%s", buf, want)
+	}
+	buf.Reset()
+	_ = b.WriteJSON(buf)
+	if !strings.Contains(buf.String(), `"features": [`) || !strings.Contains(buf.String(), `"feature": "blocked"`) {
+		t.Errorf("json: want the features in\n%s", buf)
+	}
+
+	// features are drawn with patterns in svg
+	data, _ := b.RenderSVG(DefaultRenderOptions(false))
+	for _, want := range []string{`<pattern id="feature-nebula"`, `fill="url(#feature-blocked)"`} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("svg: want %s", want)
+		}
+	}
+	if bytes.Contains(data, []byte("feature-black-hole")) {
+		t.Errorf("svg: want only the patterns that are used")
+	}
+
+	// bad feature records and features that break the rules
+	_, _, diagnostics = ParseMapCSV(strings.NewReader("@nebula, 1\n@nebula, x, 1\n"))
+	if len(diagnostics) != 2 || diagnostics[1].Error() != `features: line 2: col: "x" is not a number` {
+		t.Errorf("bad records: got %v", diagnostics)
+	}
+	terrain = []Terrain{{"black-hole", 1, 1}, {"fog", 2, 2}, {"nebula", 41, 1}, {"nebula", 3, 3}, {"nebula", 3, 3}}
+	var got []string
+	for _, d := range ValidateTerrain(nodes, terrain, nil) {
+		got = append(got, string(d.Severity)+": "+d.Error())
+	}
+	want := []string{
+		`error: features: black-hole at 0101: star "Ur" can't be in an impassable hex`,
+		`error: features: fog at 0202: unknown feature`,
+		`error: features: nebula at 4101: col must be 1 to 40 and row 1 to 40`,
+		`warning: features: nebula at 0303: listed more than once`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validate: got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if err := b.AddFeature(1, 1, BlackHole); err == nil {
+		t.Errorf("black hole on a star: want error")
+	}
+}
+
+func TestFieldOfView(t *testing.T) {
+	// looking down column 3, which is a straight line of flat hexes
+	b := NewBoard(5, 5)
+	_ = b.AddFeature(3, 2, Nebula)
+	g := Geometry{}
+	seen := make(map[Coords]bool)
+	for _, c := range b.FieldOfView(g, Coords{Col: 3, Row: 1}, 3) {
+		seen[c] = true
+	}
+	for c, want := range map[Coords]bool{{Col: 3, Row: 1}: true, {Col: 3, Row: 2}: true, {Col: 3, Row: 3}: false, {Col: 3, Row: 4}: false, {Col: 2, Row: 2}: true, {Col: 6, Row: 1}: true, {Col: 3, Row: 5}: false} {
+		if seen[c] != want {
+			t.Errorf("nebula: %v: got %v, want %v", c, seen[c], want)
+		}
+	}
+
+	// asteroids don't block sight
+	b.Hexes[2][3].Features = Asteroids
+	seen = make(map[Coords]bool)
+	for _, c := range b.FieldOfView(g, Coords{Col: 3, Row: 1}, 3) {
+		seen[c] = true
+	}
+	if !seen[Coords{Col: 3, Row: 3}] || !seen[Coords{Col: 3, Row: 4}] {
+		t.Errorf("asteroids: want 0303 and 0304 to be seen")
+	}
+}
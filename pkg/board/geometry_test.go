@@ -0,0 +1,64 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestGeometry(t *testing.T) {
+	for _, g := range []Geometry{{Orientation: "flat", Offset: "even"}, {Orientation: "flat", Offset: "odd"}, {Orientation: "pointy", Offset: "even"}, {Orientation: "pointy", Offset: "odd"}} {
+		for row := 0; row < 6; row++ {
+			for col := 0; col < 6; col++ {
+				c := Coords{Col: col, Row: row}
+				if got := g.FromCube(g.ToCube(Coords{Col: col, Row: row})); got != c {
+					t.Errorf("%v: %v: round trip got %v", g, c, got)
+				}
+
+				// the neighbors are the hexes one column or row away that are one step away
+				var want []Coords
+				for _, d := range [][2]int{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}} {
+					n := Coords{Col: col + d[0], Row: row + d[1]}
+					if g.ToCube(Coords{Col: col, Row: row}).Distance(g.ToCube(n)) == 1 {
+						want = append(want, n)
+					}
+				}
+				got := g.Neighbors(c)
+				sort.Slice(want, func(i, j int) bool { return want[i].Less(want[j]) })
+				sort.Slice(got, func(i, j int) bool { return got[i].Less(got[j]) })
+				if len(got) != 6 || len(want) != 6 {
+					t.Fatalf("%v: %v: got %d neighbors, want 6", g, c, len(got))
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Errorf("%v: %v: neighbors: got %v, want %v", g, c, got, want)
+						break
+					}
+				}
+			}
+		}
+	}
+}
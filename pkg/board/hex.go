@@ -24,16 +24,11 @@
 
 package board
 
-import "github.com/mdhender/wow/pkg/hexes"
-
 type Hex struct {
 	Coords        Coords
 	Name          string
 	HasStar       bool
 	EconValue     int
 	WormHoleExits []*Hex
-
-	hex hexes.Hex
+	Features      Features // terrain in the hex
 }
-
-
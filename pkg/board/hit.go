@@ -0,0 +1,44 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import "github.com/mdhender/wow/pkg/hexes"
+
+// HitTest returns the hex under a point on the board as it is drawn by
+// RenderSVG with the same options, and the star in it, if there is one.
+// The point is in SVG user units, which are pixels of a PNG at 96 dpi.
+// It returns false if the point isn't on a hex of the board.
+func (b *Board) HitTest(o RenderOptions, x, y float64) (Coords, *Hex, bool) {
+	o = o.WithDefaults()
+	layout, _ := b.layout(o)
+	c := o.Geometry().FromCube(layout.PixelToHex(hexes.NewPoint(x, y)).Round())
+	if !b.OnBoard(c) {
+		return Coords{}, nil, false
+	}
+	if hex := b.Hexes[c.Row][c.Col]; hex.HasStar {
+		return c, hex, true
+	}
+	return c, nil, true
+}
@@ -0,0 +1,75 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import "testing"
+
+func TestHitTest(t *testing.T) {
+	m, _ := ShapeMask("hexagon", 7, 7, Geometry{})
+	masked := NewMaskedBoard(m)
+	masked.AddStar("Ur", 4, 4, 4)
+	for _, tc := range []struct {
+		name string
+		b    *Board
+		o    RenderOptions
+	}{
+		{"standard", NewStandardBoard(), DefaultRenderOptions(false)},
+		{"pointy", NewStandardBoard(), RenderOptions{HexSize: 30, Orientation: "pointy", Offset: "odd", Margin: 10}},
+		{"masked", masked, DefaultRenderOptions(true)},
+	} {
+		s := tc.b.asSVG(tc.o)
+		// the center of every hex, and points near its corners, hit the hex
+		for _, h := range s.hexes {
+			for _, pt := range append([]point{{h.cx, h.cy}}, h.points...) {
+				x, y := h.cx+(pt.x-h.cx)*0.9, h.cy+(pt.y-h.cy)*0.9
+				if c, _, ok := tc.b.HitTest(tc.o, x, y); !ok || c != (Coords{Col: h.col, Row: h.row}) {
+					t.Errorf("%s: %.1f, %.1f: got %v %v, want %02d%02d", tc.name, x, y, c, ok, h.col, h.row)
+				}
+			}
+		}
+		for _, p := range s.polygons {
+			if _, star, ok := tc.b.HitTest(tc.o, p.cx, p.cy); !ok || star == nil || star.Name != p.text[0] {
+				t.Errorf("%s: %s: got %v %v", tc.name, p.text[0], star, ok)
+			}
+		}
+		if _, _, ok := tc.b.HitTest(tc.o, 1, 1); ok {
+			t.Errorf("%s: margin: want no hit", tc.name)
+		}
+	}
+
+	// the corners of a hexagon are outside the mask
+	s := masked.asSVG(DefaultRenderOptions(true))
+	for _, h := range s.hexes {
+		if h.col == 1 && h.row == 1 {
+			t.Fatalf("masked: 0101 is drawn")
+		}
+	}
+	o := DefaultRenderOptions(true).WithDefaults()
+	layout, toCube := masked.layout(o)
+	x, y := layout.CenterPoint(toCube(1, 1)).Coords()
+	if c, _, ok := masked.HitTest(o, x, y); ok {
+		t.Errorf("masked: 0101: got %v, want no hit", c)
+	}
+}
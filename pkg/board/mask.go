@@ -0,0 +1,201 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/hexes"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Mask is the set of hexes that are part of the board. Hexes outside
+// the mask are not drawn, can't hold stars and can't be moved through.
+// A nil mask is the whole rectangular board.
+type Mask map[Coords]bool
+
+// Shapes are the names of the masks that ShapeMask creates.
+var Shapes = []string{"rectangle", "hexagon", "triangle"}
+
+// ShapeMask returns a mask of the given shape that fits in a board of
+// cols by rows hexes. The hexagon is the largest one around the center
+// of the board; the triangle has its corner in the top left hex and
+// points right (flat hexes) or down (pointy hexes).
+func ShapeMask(shape string, cols, rows int, g Geometry) (Mask, error) {
+	if cols < 1 || rows < 1 || cols > MaxCols || rows > MaxRows {
+		return nil, fmt.Errorf("board: mask must be between 1 and %d columns and %d rows", MaxCols, MaxRows)
+	}
+	var grid *hexes.Grid
+	switch strings.ToLower(shape) {
+	case "rectangle":
+	case "hexagon":
+		grid = hexes.HexagonGrid(g.ToCube(Coords{Col: (cols + 1) / 2, Row: (rows + 1) / 2}), (minInt(cols, rows)-1)/2)
+	case "triangle":
+		grid = hexes.TriangleGrid(g.ToCube(Coords{Col: 1, Row: 1}), minInt(cols, rows))
+	default:
+		return nil, fmt.Errorf("board: unknown shape %q", shape)
+	}
+	m := make(Mask)
+	for row := 1; row <= rows; row++ {
+		for col := 1; col <= cols; col++ {
+			if grid == nil || grid.Has(g.ToCube(Coords{Col: col, Row: row})) {
+				m[Coords{Col: col, Row: row}] = true
+			}
+		}
+	}
+	return m, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Contains reports whether a hex is in the mask.
+func (m Mask) Contains(c Coords) bool {
+	return m == nil || m[c]
+}
+
+// Bounds returns the largest column and row in the mask.
+func (m Mask) Bounds() (cols, rows int) {
+	for c, ok := range m {
+		if ok {
+			if c.Col > cols {
+				cols = c.Col
+			}
+			if c.Row > rows {
+				rows = c.Row
+			}
+		}
+	}
+	return cols, rows
+}
+
+// Coords returns the hexes in the mask, sorted by row and then column.
+func (m Mask) Coords() []Coords {
+	var list []Coords
+	for c, ok := range m {
+		if ok {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Less(list[j])
+	})
+	return list
+}
+
+// ParseMask reads a mask drawn in ASCII. Each line is a row and each
+// character a column, starting from row 1 and column 1. A "." is a hex
+// on the board; a space, "-" or "x" is not. Lines starting with "#"
+// are comments.
+func ParseMask(r io.Reader) (Mask, error) {
+	m := make(Mask)
+	scanner := bufio.NewScanner(r)
+	line, row := 0, 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		row++
+		for i, ch := range []rune(text) {
+			switch ch {
+			case '.':
+				if i+1 > MaxCols || row > MaxRows {
+					return nil, fmt.Errorf("board: mask: line %d: must be at most %d columns and %d rows", line, MaxCols, MaxRows)
+				}
+				m[Coords{Col: i + 1, Row: row}] = true
+			case ' ', '-', 'x', 'X':
+			default:
+				return nil, fmt.Errorf("board: mask: line %d: unexpected %q", line, ch)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	} else if len(m) == 0 {
+		return nil, fmt.Errorf("board: mask: no hexes")
+	}
+	return m, nil
+}
+
+// String returns the mask drawn in ASCII, as ParseMask reads it.
+func (m Mask) String() string {
+	cols, rows := m.Bounds()
+	sb := &strings.Builder{}
+	for row := 1; row <= rows; row++ {
+		line := make([]byte, cols)
+		for col := 1; col <= cols; col++ {
+			line[col-1] = '-'
+			if m[Coords{Col: col, Row: row}] {
+				line[col-1] = '.'
+			}
+		}
+		sb.Write(bytes.TrimRight(line, "-"))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// MarshalJSON returns the mask as a string with the mask drawn in ASCII,
+// which is much shorter than the list of hexes.
+func (m Mask) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON reads a mask from a list of [column, row] pairs,
+// or from a string with the mask drawn in ASCII.
+func (m *Mask) UnmarshalJSON(data []byte) error {
+	var ascii string
+	if err := json.Unmarshal(data, &ascii); err == nil {
+		mask, err := ParseMask(strings.NewReader(ascii))
+		if err != nil {
+			return err
+		}
+		*m = mask
+		return nil
+	}
+	var pairs [][2]int
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return fmt.Errorf("board: mask must be a list of [column, row] pairs or an ASCII drawing")
+	}
+	mask := make(Mask)
+	for _, p := range pairs {
+		if p[0] < 1 || p[0] > MaxCols || p[1] < 1 || p[1] > MaxRows {
+			return fmt.Errorf("board: mask: hex %d, %d is off the board", p[0], p[1])
+		}
+		mask[Coords{Col: p[0], Row: p[1]}] = true
+	}
+	*m = mask
+	return nil
+}
@@ -0,0 +1,153 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShapeMask(t *testing.T) {
+	for _, tc := range []struct {
+		shape      string
+		cols, rows int
+		g          Geometry
+		want       string
+	}{
+		{"rectangle", 3, 2, Geometry{}, "...\n...\n"},
+		{"hexagon", 5, 5, Geometry{}, "-...\n.....\n.....\n.....\n--.\n"},
+		{"hexagon", 5, 5, Geometry{Orientation: "pointy", Offset: "odd"}, "-...\n-....\n.....\n-....\n-...\n"},
+		{"triangle", 4, 4, Geometry{}, "..\n....\n...\n.\n"},
+		{"triangle", 4, 4, Geometry{Orientation: "pointy"}, "....\n...\n-..\n-.\n"},
+	} {
+		m, err := ShapeMask(tc.shape, tc.cols, tc.rows, tc.g)
+		if err != nil {
+			t.Errorf("%s %+v: %v", tc.shape, tc.g, err)
+		} else if got := m.String(); got != tc.want {
+			t.Errorf("%s %+v: got\n%s\nwant\n%s", tc.shape, tc.g, got, tc.want)
+		}
+	}
+	if _, err := ShapeMask("circle", 5, 5, Geometry{}); err == nil {
+		t.Errorf("circle: want error")
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	m, err := ParseMask(strings.NewReader("# a ring\n...\n.x.\n-..\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.String(), "...\n.-.\n-..\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if cols, rows := m.Bounds(); cols != 3 || rows != 3 {
+		t.Errorf("bounds: got %d, %d, want 3, 3", cols, rows)
+	}
+
+	// both JSON forms read the same mask, and it is written in ASCII
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	} else if want := `"...\n.-.\n-..\n"`; string(data) != want {
+		t.Errorf("json: got %s, want %s", data, want)
+	}
+	for _, input := range []string{string(data), `[[1,1],[2,1],[3,1],[1,2],[3,2],[2,3],[3,3]]`} {
+		var got Mask
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Errorf("%s: %v", input, err)
+		} else if !reflect.DeepEqual(got, m) {
+			t.Errorf("%s: got\n%s", input, got)
+		}
+	}
+
+	for _, input := range []string{"", "# nothing\n", "..?\n", strings.Repeat(".", 41)} {
+		if _, err := ParseMask(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: want error", input)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	// a wall down column 3 with a gap at the bottom
+	mask, _ := ParseMask(strings.NewReader(".....\n..-..\n..-..\n.....\n"))
+	b := NewMaskedBoard(mask)
+	g := Geometry{}
+	for _, tc := range []struct {
+		from, to Coords
+		length   int
+	}{
+		{Coords{Col: 1, Row: 1}, Coords{Col: 1, Row: 1}, 1},
+		{Coords{Col: 1, Row: 1}, Coords{Col: 1, Row: 4}, 4},
+		{Coords{Col: 2, Row: 2}, Coords{Col: 4, Row: 2}, 5}, // around the bottom of the wall
+	} {
+		path, err := b.Path(g, tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%v to %v: %v", tc.from, tc.to, err)
+			continue
+		}
+		if len(path) != tc.length || path[0] != tc.from || path[len(path)-1] != tc.to {
+			t.Errorf("%v to %v: got %v, want %d hexes", tc.from, tc.to, path, tc.length)
+		}
+		for i, c := range path {
+			if !b.OnBoard(c) {
+				t.Errorf("%v to %v: %v is off the board", tc.from, tc.to, c)
+			} else if i > 0 && g.ToCube(c).Distance(g.ToCube(path[i-1])) != 1 {
+				t.Errorf("%v to %v: %v doesn't touch %v", tc.from, tc.to, c, path[i-1])
+			}
+		}
+	}
+	if _, err := b.Path(g, Coords{Col: 1, Row: 1}, Coords{Col: 3, Row: 2}); err == nil {
+		t.Errorf("into the wall: want error")
+	}
+
+	// close the gap
+	delete(mask, Coords{Col: 3, Row: 1})
+	delete(mask, Coords{Col: 3, Row: 4})
+	if _, err := b.Path(g, Coords{Col: 1, Row: 1}, Coords{Col: 5, Row: 1}); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("closed wall: got %v, want %v", err, ErrNoRoute)
+	}
+}
+
+func TestMaskedBoard(t *testing.T) {
+	mask, _ := ShapeMask("hexagon", 7, 7, Geometry{})
+	nodes := []Node{{Name: "Ur", Col: 4, Row: 4, Warps: []string{"Adab"}}, {Name: "Adab", Col: 1, Row: 4}}
+	b, err := FromNodesMasked(nodes, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := b.asSVG(DefaultRenderOptions(false)); len(s.hexes) != len(mask) {
+		t.Errorf("hexes: got %d, want %d", len(s.hexes), len(mask))
+	}
+	nodes[1].Col, nodes[1].Row = 1, 1
+	if _, err := FromNodesMasked(nodes, mask); err == nil || !strings.Contains(err.Error(), "outside the mask") {
+		t.Errorf("star outside the mask: got %v", err)
+	}
+	if d := ValidateMask(nodes, mask); len(d) != 1 || d[0].Error() != "record 2: hex 0101 is outside the mask" {
+		t.Errorf("validate: got %v", d)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/mdhender/wow/pkg/coords"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Node is a star in the map data format described in the README.
+type Node struct {
+	Name      string   `json:"name"`
+	Col       int      `json:"col"`
+	Row       int      `json:"row"`
+	EconValue int      `json:"econ-value"`
+	Warps     []string `json:"warps"`
+}
+
+// FromNodes creates a board that is just large enough to hold all the nodes,
+// adds the stars, then adds the wormholes.
+func FromNodes(nodes []Node) (*Board, error) {
+	return FromNodesMasked(nodes, nil)
+}
+
+// FromNodesMasked is FromNodes for a board with a mask.
+// The board is large enough to hold the mask, and every star must be inside it.
+func FromNodesMasked(nodes []Node, mask Mask) (*Board, error) {
+	maxCol, maxRow := mask.Bounds()
+	names := make(map[string]bool)
+	for _, n := range nodes {
+		if strings.TrimSpace(n.Name) == "" {
+			return nil, fmt.Errorf("board: star at %d, %d: missing name", n.Col, n.Row)
+		} else if names[n.Name] {
+			return nil, fmt.Errorf("board: duplicate star: %q", n.Name)
+		} else if n.Col < 0 || n.Row < 0 {
+			return nil, fmt.Errorf("board: star %q: invalid coordinates %d, %d", n.Name, n.Col, n.Row)
+		} else if !mask.Contains(Coords{Col: n.Col, Row: n.Row}) {
+			return nil, fmt.Errorf("board: star %q: %d, %d is outside the mask", n.Name, n.Col, n.Row)
+		}
+		names[n.Name] = true
+		if n.Row > maxRow {
+			maxRow = n.Row
+		}
+		if n.Col > maxCol {
+			maxCol = n.Col
+		}
+	}
+
+	b := NewBoard(maxRow, maxCol)
+	b.Mask = mask
+	for _, n := range nodes {
+		b.AddStar(n.Name, n.Row, n.Col, n.EconValue)
+	}
+	for _, n := range nodes {
+		for _, target := range n.Warps {
+			w, err := ParseWarp(n.Name, target)
+			if err != nil {
+				return nil, err
+			}
+			if err := b.AddWarp(w); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+// ParseCSV reads map data in the CSV format described in the README.
+// Each record is "name, column, row, economic-value" followed by the names
+// of the warp targets. The column and row can also be written as one
+// field in any notation that coords.Parse reads, like "name, 0203,
+// economic-value" or "name, B3, economic-value". Lines starting with "#"
+// are comments.
+//
+// It returns a node for every star record, so that the record index in a
+// diagnostic is also the index of the node. Fields that can't be read
+// are reported and left empty. Feature records are skipped; use
+// ParseMapCSV to read them.
+func ParseCSV(r io.Reader) ([]Node, []Diagnostic) {
+	nodes, _, diagnostics := ParseMapCSV(r)
+	return nodes, diagnostics
+}
+
+// ParseMapCSV is ParseCSV for map data with features. A record that
+// starts with "@" and the name of a feature, like "@nebula, 5, 7" or
+// "@nebula, 0507", puts the feature in that hex. Problems with
+// feature records are reported for the whole map, with the line number.
+func ParseMapCSV(r io.Reader) ([]Node, []Terrain, []Diagnostic) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1 // allow variable number of fields per line
+	cr.TrimLeadingSpace = true
+
+	var nodes []Node
+	var terrain []Terrain
+	var diagnostics []Diagnostic
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			d := Diagnostic{Severity: SeverityError, Record: len(nodes), Message: err.Error()}
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				d.Line, d.Message = pe.Line, pe.Err.Error()
+			}
+			diagnostics = append(diagnostics, d)
+			break
+		}
+		line, _ := cr.FieldPos(0)
+
+		if name := strings.TrimSpace(record[0]); strings.HasPrefix(name, "@") {
+			t := Terrain{Feature: strings.TrimPrefix(name, "@")}
+			var err error
+			if c, ok := hexField(record); ok && len(record) == 2 {
+				t.Col, t.Row = c.Col, c.Row
+			} else if len(record) == 2 {
+				if _, err = coords.Parse(record[1]); err != nil {
+					err = fmt.Errorf("hex: %v", err)
+				} else {
+					err = fmt.Errorf("hex: %q is not a hex", strings.TrimSpace(record[1]))
+				}
+			} else if len(record) != 3 {
+				err = fmt.Errorf("want 3 fields (@feature, col, row) or 2 (@feature, hex), got %d", len(record))
+			} else if t.Col, err = strconv.Atoi(strings.TrimSpace(record[1])); err != nil {
+				err = fmt.Errorf("col: %q is not a number", strings.TrimSpace(record[1]))
+			} else if t.Row, err = strconv.Atoi(strings.TrimSpace(record[2])); err != nil {
+				err = fmt.Errorf("row: %q is not a number", strings.TrimSpace(record[2]))
+			}
+			if err != nil {
+				diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Record: -1, Line: line, Field: "features", Message: fmt.Sprintf("line %d: %v", line, err)})
+				continue
+			}
+			terrain = append(terrain, t)
+			continue
+		}
+
+		i := len(nodes)
+		problem := func(field, format string, args ...interface{}) {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Record: i, Line: line, Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+
+		var n Node
+		n.Name = strings.TrimSpace(record[0])
+		if c, ok := hexField(record); ok {
+			// the hex is in one field, like "0203" or "B3"
+			if len(record) < 3 {
+				problem("", "want at least 3 fields (name, hex, econ-value), got %d", len(record))
+			}
+			record = append([]string{record[0], strconv.Itoa(c.Col), strconv.Itoa(c.Row)}, record[2:]...)
+		} else if len(record) < 4 {
+			problem("", "want at least 4 fields (name, col, row, econ-value), got %d", len(record))
+		}
+		for f, field := range []struct {
+			name  string
+			value *int
+		}{{"col", &n.Col}, {"row", &n.Row}, {"econ-value", &n.EconValue}} {
+			if f+1 >= len(record) {
+				break
+			}
+			v, err := strconv.Atoi(strings.TrimSpace(record[f+1]))
+			if err != nil {
+				problem(field.name, "%q is not a number", strings.TrimSpace(record[f+1]))
+			}
+			*field.value = v
+		}
+		if len(record) > 4 {
+			for _, target := range record[4:] {
+				if target = strings.TrimSpace(target); target != "" {
+					n.Warps = append(n.Warps, target)
+				}
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, terrain, diagnostics
+}
+
+// hexField returns the coordinates in the second field of a record, if
+// it holds a whole hex in any notation, like "0203", "B3" or "2,3" in
+// quotes. A plain column number is not a hex. Cube coordinates are for
+// flat hexes with the even columns pushed down, as coords.Parse reads them.
+func hexField(record []string) (Coords, bool) {
+	if len(record) < 2 {
+		return Coords{}, false
+	}
+	field := strings.TrimSpace(record[1])
+	if _, err := strconv.Atoi(field); err == nil && len(field) < 4 {
+		return Coords{}, false
+	}
+	c, err := coords.Parse(field)
+	return c, err == nil
+}
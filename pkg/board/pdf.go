@@ -0,0 +1,247 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// paperSizes are the supported paper sizes, in points, portrait.
+var paperSizes = map[string][2]float64{
+	"letter": {612, 792},
+	"a4":     {595.28, 841.89},
+}
+
+// AsPDF returns the board as a printable PDF.
+func (b *Board) AsPDF(mono bool, paper string) ([]byte, error) {
+	return b.RenderPDF(DefaultRenderOptions(mono), paper)
+}
+
+// RenderPDF returns the board as a printable PDF drawn with the given options.
+// The map is printed at actual size (96 SVG user units to the inch) and is
+// tiled across as many pages as needed, turning the pages sideways if
+// that takes fewer of them. Each page has crop marks at the corners of
+// its tile, registration targets on the edges it shares with other pages,
+// and a label saying where it goes.
+func (b *Board) RenderPDF(o RenderOptions, paper string) ([]byte, error) {
+	size, ok := paperSizes[strings.ToLower(paper)]
+	if !ok {
+		return nil, fmt.Errorf("board: unknown paper size %q", paper)
+	} else if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	s := b.asSVG(o)
+
+	// the scene is drawn once, as a form, in points with y going down
+	const scale = 72.0 / 96.0
+	sceneW, sceneH := float64(s.viewBox.width)*scale, float64(s.viewBox.height)*scale
+	form := &pdfCanvas{}
+	fmt.Fprintf(&form.buf, "1 j 1 J\n%s 0 0 %s %s %s cm\n", num(scale), num(scale), num(-float64(s.viewBox.minX)*scale), num(-float64(s.viewBox.minY)*scale))
+	if err := s.draw(form); err != nil {
+		return nil, err
+	}
+
+	// pick the orientation that needs fewer pages
+	const margin = 36.0
+	tiles := func(pw, ph float64) (cols, rows int) {
+		return int(math.Ceil(sceneW / (pw - 2*margin))), int(math.Ceil(sceneH / (ph - 2*margin)))
+	}
+	pw, ph := size[0], size[1]
+	cols, rows := tiles(pw, ph)
+	if lc, lr := tiles(ph, pw); lc*lr < cols*rows {
+		pw, ph, cols, rows = ph, pw, lc, lr
+	}
+	tw, th := pw-2*margin, ph-2*margin
+
+	w := &pdfWriter{}
+	w.object(0, "<< /Type /Catalog /Pages 2 0 R >>")
+	pagesID := w.reserve()
+	formID := w.stream(fmt.Sprintf("/Type /XObject /Subtype /Form /BBox [0 0 %s %s]", num(sceneW), num(sceneH)), form.buf.Bytes())
+	var kids []string
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			page := &pdfCanvas{}
+			// flip the page so that y goes down, like the scene
+			fmt.Fprintf(&page.buf, "1 0 0 -1 0 %s cm\n", num(ph))
+			fmt.Fprintf(&page.buf, "q %s %s %s %s re W n 1 0 0 1 %s %s cm /Fm0 Do Q\n",
+				num(margin), num(margin), num(tw), num(th), num(margin-float64(col)*tw), num(margin-float64(row)*th))
+			registrationMarks(page, margin, margin, tw, th, row > 0, col < cols-1, row < rows-1, col > 0)
+			label := fmt.Sprintf("row %d of %d, column %d of %d", row+1, rows, col+1, cols)
+			if p, err := textPath(label, pw/2, ph-margin/2+3, 9, true); err != nil {
+				return nil, err
+			} else {
+				page.fill(p, color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff})
+			}
+			contentID := w.stream("", page.buf.Bytes())
+			kids = append(kids, fmt.Sprintf("%d 0 R", w.object(0, fmt.Sprintf(
+				"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Fm0 %d 0 R >> >> /Contents %d 0 R >>",
+				pagesID, num(pw), num(ph), formID, contentID))))
+		}
+	}
+	w.object(pagesID, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	return w.bytes(), nil
+}
+
+// registrationMarks draws crop marks at the corners of the tile and
+// a target in the middle of each edge that joins another page.
+func registrationMarks(c *pdfCanvas, x, y, w, h float64, top, right, bottom, left bool) {
+	black := color.RGBA{A: 0xff}
+	const mark, gap = 18.0, 4.0
+	for _, corner := range [][2]float64{{x, y}, {x + w, y}, {x, y + h}, {x + w, y + h}} {
+		cx, cy := corner[0], corner[1]
+		dx, dy := -1.0, -1.0
+		if cx > x {
+			dx = 1
+		}
+		if cy > y {
+			dy = 1
+		}
+		c.stroke(linePath(cx+dx*gap, cy, cx+dx*(gap+mark), cy), black, 0.5)
+		c.stroke(linePath(cx, cy+dy*gap, cx, cy+dy*(gap+mark)), black, 0.5)
+	}
+	target := func(cx, cy float64) {
+		c.stroke(circlePath(cx, cy, 6), black, 0.5)
+		c.stroke(linePath(cx-10, cy, cx+10, cy), black, 0.5)
+		c.stroke(linePath(cx, cy-10, cx, cy+10), black, 0.5)
+	}
+	if top {
+		target(x+w/2, y)
+	}
+	if right {
+		target(x+w, y+h/2)
+	}
+	if bottom {
+		target(x+w/2, y+h)
+	}
+	if left {
+		target(x, y+h/2)
+	}
+}
+
+// pdfCanvas writes PDF content stream operators.
+type pdfCanvas struct {
+	buf bytes.Buffer
+}
+
+func (pc *pdfCanvas) fill(p path, c color.RGBA) {
+	fmt.Fprintf(&pc.buf, "%s rg\n", rgb(c))
+	pc.path(p)
+	pc.buf.WriteString("f\n")
+}
+
+func (pc *pdfCanvas) stroke(p path, c color.RGBA, width float64) {
+	fmt.Fprintf(&pc.buf, "%s RG %s w\n", rgb(c), num(width))
+	pc.path(p)
+	pc.buf.WriteString("S\n")
+}
+
+func (pc *pdfCanvas) path(p path) {
+	var cur point
+	for _, op := range p {
+		switch op.op {
+		case 'M':
+			cur = op.pts[0]
+			fmt.Fprintf(&pc.buf, "%s %s m\n", num(cur.x), num(cur.y))
+		case 'L':
+			cur = op.pts[0]
+			fmt.Fprintf(&pc.buf, "%s %s l\n", num(cur.x), num(cur.y))
+		case 'Q':
+			// pdf only has cubic curves, so raise the degree of the quadratic
+			c1 := point{x: cur.x + 2.0/3*(op.pts[0].x-cur.x), y: cur.y + 2.0/3*(op.pts[0].y-cur.y)}
+			c2 := point{x: op.pts[1].x + 2.0/3*(op.pts[0].x-op.pts[1].x), y: op.pts[1].y + 2.0/3*(op.pts[0].y-op.pts[1].y)}
+			cur = op.pts[1]
+			fmt.Fprintf(&pc.buf, "%s %s %s %s %s %s c\n", num(c1.x), num(c1.y), num(c2.x), num(c2.y), num(cur.x), num(cur.y))
+		case 'C':
+			cur = op.pts[2]
+			fmt.Fprintf(&pc.buf, "%s %s %s %s %s %s c\n", num(op.pts[0].x), num(op.pts[0].y), num(op.pts[1].x), num(op.pts[1].y), num(cur.x), num(cur.y))
+		case 'Z':
+			pc.buf.WriteString("h\n")
+		}
+	}
+}
+
+func rgb(c color.RGBA) string {
+	return fmt.Sprintf("%s %s %s", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255))
+}
+
+// num formats a number for a PDF file, which doesn't allow exponents.
+func num(f float64) string {
+	f = math.Round(f*1000) / 1000
+	if f == 0 {
+		f = 0 // no negative zero
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// pdfWriter collects the objects of a PDF file and writes the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int // offset of each object, indexed by id - 1
+}
+
+// reserve allocates an object id to be written later.
+func (w *pdfWriter) reserve() int {
+	w.offsets = append(w.offsets, 0)
+	return len(w.offsets)
+}
+
+// object writes an object and returns its id.
+// If id is zero a new id is allocated; otherwise it must have been reserved.
+func (w *pdfWriter) object(id int, body string) int {
+	if id == 0 {
+		id = w.reserve()
+	}
+	if w.buf.Len() == 0 {
+		w.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	}
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+	return id
+}
+
+// stream writes a compressed stream object and returns its id.
+func (w *pdfWriter) stream(dict string, data []byte) int {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	_, _ = zw.Write(data)
+	_ = zw.Close()
+	return w.object(0, fmt.Sprintf("<< %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", dict, z.Len(), z.String()))
+}
+
+func (w *pdfWriter) bytes() []byte {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, xref)
+	return w.buf.Bytes()
+}
@@ -0,0 +1,241 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/image/vector"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+// MaxPixels limits the size of the images created by AsPNG.
+const MaxPixels = 64 * 1024 * 1024
+
+// AsPNG returns the board as a PNG image at the given resolution.
+func (b *Board) AsPNG(mono bool, dpi float64) ([]byte, error) {
+	return b.RenderPNG(DefaultRenderOptions(mono), dpi)
+}
+
+// RenderPNG returns the board as a PNG image drawn with the given options.
+// SVG user units are treated as CSS pixels, so 96 dpi is "actual size".
+func (b *Board) RenderPNG(o RenderOptions, dpi float64) ([]byte, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("board: invalid dpi %v", dpi)
+	} else if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	s := b.asSVG(o)
+	scale := dpi / 96
+	width := int(math.Ceil(float64(s.viewBox.width) * scale))
+	height := int(math.Ceil(float64(s.viewBox.height) * scale))
+	if width*height > MaxPixels {
+		return nil, fmt.Errorf("board: %dx%d image is too large, try a lower dpi", width, height)
+	}
+
+	rc := &rasterCanvas{
+		img:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		scale: scale,
+		dx:    -float64(s.viewBox.minX),
+		dy:    -float64(s.viewBox.minY),
+	}
+	draw.Draw(rc.img, rc.img.Bounds(), image.White, image.Point{}, draw.Src)
+	if err := s.draw(rc); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, rc.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rasterCanvas draws onto an image.
+// Each shape is rasterized on its own, limited to its bounding box.
+type rasterCanvas struct {
+	img    *image.RGBA
+	scale  float64
+	dx, dy float64
+	z      vector.Rasterizer
+}
+
+func (rc *rasterCanvas) fill(p path, c color.RGBA) {
+	var polys [][]point
+	var start, cur point
+	for _, op := range p {
+		pts := rc.transform(op.pts)
+		switch op.op {
+		case 'M':
+			start, cur = pts[0], pts[0]
+			polys = append(polys, []point{cur})
+		case 'L':
+			cur = pts[0]
+			polys[len(polys)-1] = append(polys[len(polys)-1], cur)
+		case 'Q':
+			polys[len(polys)-1] = append(polys[len(polys)-1], flattenQuad(cur, pts[0], pts[1])...)
+			cur = pts[1]
+		case 'C':
+			polys[len(polys)-1] = append(polys[len(polys)-1], flattenCube(cur, pts[0], pts[1], pts[2])...)
+			cur = pts[2]
+		case 'Z':
+			cur = start
+		}
+	}
+	rc.rasterize(polys, c)
+}
+
+// stroke builds the outline of the stroke from a quadrilateral for every
+// segment and a disc at every vertex, giving round joins and caps.
+// All the pieces wind the same way so that where they overlap the
+// rasterizer fills them rather than cutting holes.
+func (rc *rasterCanvas) stroke(p path, c color.RGBA, width float64) {
+	hw := math.Max(width*rc.scale, 1) / 2
+	var polys [][]point
+	segment := func(a, b point) {
+		dx, dy := b.x-a.x, b.y-a.y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			return
+		}
+		nx, ny := -dy/length*hw, dx/length*hw
+		polys = append(polys, []point{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}})
+	}
+	joint := func(a point) {
+		disc := make([]point, 12)
+		for i := range disc {
+			theta := float64(i) * math.Pi / 6
+			disc[i] = point{a.x + hw*math.Cos(theta), a.y + hw*math.Sin(theta)}
+		}
+		polys = append(polys, disc)
+	}
+	var start, cur point
+	lineTo := func(pts ...point) {
+		for _, pt := range pts {
+			segment(cur, pt)
+			joint(pt)
+			cur = pt
+		}
+	}
+	for _, op := range p {
+		pts := rc.transform(op.pts)
+		switch op.op {
+		case 'M':
+			start, cur = pts[0], pts[0]
+			joint(cur)
+		case 'L':
+			lineTo(pts[0])
+		case 'Q':
+			lineTo(flattenQuad(cur, pts[0], pts[1])...)
+		case 'C':
+			lineTo(flattenCube(cur, pts[0], pts[1], pts[2])...)
+		case 'Z':
+			lineTo(start)
+		}
+	}
+	for _, poly := range polys {
+		if signedArea(poly) < 0 {
+			for i, j := 0, len(poly)-1; i < j; i, j = i+1, j-1 {
+				poly[i], poly[j] = poly[j], poly[i]
+			}
+		}
+	}
+	rc.rasterize(polys, c)
+}
+
+// rasterize fills the polygons, which are in image coordinates.
+func (rc *rasterCanvas) rasterize(polys [][]point, c color.RGBA) {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for _, pt := range poly {
+			minX, minY = math.Min(minX, pt.x), math.Min(minY, pt.y)
+			maxX, maxY = math.Max(maxX, pt.x), math.Max(maxY, pt.y)
+		}
+	}
+	r := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX))+1, int(math.Ceil(maxY))+1).Intersect(rc.img.Bounds())
+	if r.Empty() {
+		return
+	}
+	ox, oy := float64(r.Min.X), float64(r.Min.Y)
+	rc.z.Reset(r.Dx(), r.Dy())
+	for _, poly := range polys {
+		if len(poly) < 3 {
+			continue
+		}
+		rc.z.MoveTo(float32(poly[0].x-ox), float32(poly[0].y-oy))
+		for _, pt := range poly[1:] {
+			rc.z.LineTo(float32(pt.x-ox), float32(pt.y-oy))
+		}
+		rc.z.ClosePath()
+	}
+	rc.z.Draw(rc.img, r, image.NewUniform(c), image.Point{})
+}
+
+// transform converts points from user units to image coordinates.
+func (rc *rasterCanvas) transform(pts []point) []point {
+	out := make([]point, len(pts))
+	for i, pt := range pts {
+		out[i] = point{x: (pt.x + rc.dx) * rc.scale, y: (pt.y + rc.dy) * rc.scale}
+	}
+	return out
+}
+
+// flattenQuad and flattenCube approximate curves with line segments.
+// They return the points after the starting point.
+func flattenQuad(p0, p1, p2 point) []point {
+	var pts []point
+	for i := 1; i <= 8; i++ {
+		t := float64(i) / 8
+		u := 1 - t
+		pts = append(pts, point{x: u*u*p0.x + 2*u*t*p1.x + t*t*p2.x, y: u*u*p0.y + 2*u*t*p1.y + t*t*p2.y})
+	}
+	return pts
+}
+
+func flattenCube(p0, p1, p2, p3 point) []point {
+	var pts []point
+	for i := 1; i <= 16; i++ {
+		t := float64(i) / 16
+		u := 1 - t
+		pts = append(pts, point{
+			x: u*u*u*p0.x + 3*u*u*t*p1.x + 3*u*t*t*p2.x + t*t*t*p3.x,
+			y: u*u*u*p0.y + 3*u*u*t*p1.y + 3*u*t*t*p2.y + t*t*t*p3.y,
+		})
+	}
+	return pts
+}
+
+func signedArea(poly []point) float64 {
+	var area float64
+	for i := range poly {
+		j := (i + 1) % len(poly)
+		area += poly[i].x*poly[j].y - poly[j].x*poly[i].y
+	}
+	return area / 2
+}
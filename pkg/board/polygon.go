@@ -38,8 +38,10 @@ type polygon struct {
 		strokeWidth string
 	}
 	points    []point
+	features  Features
 	addCircle bool
 	text      []string
+	lx, ly    float64 // the bottom middle of the name of a star
 }
 
 func (p polygon) hexPointyPoints() (points []point) {
@@ -0,0 +1,252 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/hexes"
+	"math"
+	"sort"
+	"strings"
+)
+
+// RenderOptions control how a board is drawn.
+// Zero values are replaced by the defaults when the board is rendered,
+// so a partial set of options (from a theme file, say) is fine.
+type RenderOptions struct {
+	// HexSize is the distance from the center of a hex to a corner.
+	HexSize float64 `json:"hex-size,omitempty"`
+	// Orientation is "flat" (columns of hexes) or "pointy" (rows of hexes).
+	Orientation string `json:"orientation,omitempty"`
+	// Offset is "even" or "odd", and says which columns (or rows, for
+	// pointy hexes) are pushed out by half a hex.
+	Offset string `json:"offset,omitempty"`
+	Theme  Theme  `json:"theme"`
+	// FontFamily is only used by SVG output; the other formats use the Go fonts.
+	FontFamily string `json:"font-family,omitempty"`
+	// FontSize is the size of the labels. If it is zero, the size is
+	// scaled to the size of the hexes.
+	FontSize float64 `json:"font-size,omitempty"`
+	// StrokeWidth is the width of the outlines of hexes and stars.
+	StrokeWidth float64 `json:"stroke-width,omitempty"`
+	// WarpWidth is the width of the warp lines.
+	WarpWidth float64 `json:"warp-width,omitempty"`
+	// Margin is the space around the board.
+	Margin float64 `json:"margin,omitempty"`
+}
+
+// Theme is the set of colors used to draw a board.
+// Colors are SVG colors: names, "#rrggbb", "hsl(h, s%, l%)" or "none".
+type Theme struct {
+	Name string `json:"name,omitempty"`
+	// Background is left transparent in SVG files if it is empty or "none";
+	// other formats use white.
+	Background string `json:"background,omitempty"`
+	HexFill    string `json:"hex-fill,omitempty"`
+	HexStroke  string `json:"hex-stroke,omitempty"`
+	// LabelColor is used for the coordinates of the hexes.
+	LabelColor string `json:"label-color,omitempty"`
+	StarFill   string `json:"star-fill,omitempty"`
+	StarStroke string `json:"star-stroke,omitempty"`
+	// TextColor is used for the names and values of the stars.
+	TextColor string `json:"text-color,omitempty"`
+	WarpColor string `json:"warp-color,omitempty"`
+}
+
+// themes are the named themes.
+var themes = map[string]Theme{
+	"color": {
+		Name:    "color",
+		HexFill: "hsl(197, 78%, 85%)", HexStroke: "Grey", LabelColor: "grey",
+		StarFill: "hsl(53, 100%, 94%)", StarStroke: "Grey", TextColor: "black",
+		WarpColor: "black",
+	},
+	"mono": {
+		Name:    "mono",
+		HexFill: "none", HexStroke: "Grey", LabelColor: "grey",
+		StarFill: "White", StarStroke: "Grey", TextColor: "black",
+		WarpColor: "black",
+	},
+	"dark": {
+		Name: "dark", Background: "#1b1f2a",
+		HexFill: "#263042", HexStroke: "#445066", LabelColor: "#7a8599",
+		StarFill: "#f4d35e", StarStroke: "#c9a227", TextColor: "#1b1f2a",
+		WarpColor: "#9fb3d9",
+	},
+	"parchment": {
+		Name: "parchment", Background: "#f4ecd8",
+		HexFill: "#efe3c4", HexStroke: "#a08c6a", LabelColor: "#8a7a5c",
+		StarFill: "#fffaf0", StarStroke: "#6b5838", TextColor: "#3b2f1e",
+		WarpColor: "#6b5838",
+	},
+	"print": {
+		Name:    "print",
+		HexFill: "none", HexStroke: "black", LabelColor: "#555555",
+		StarFill: "white", StarStroke: "black", TextColor: "black",
+		WarpColor: "black",
+	},
+	"blueprint": {
+		Name: "blueprint", Background: "#1d3f73",
+		HexFill: "none", HexStroke: "#8fb4e8", LabelColor: "#8fb4e8",
+		StarFill: "#1d3f73", StarStroke: "white", TextColor: "white",
+		WarpColor: "white",
+	},
+}
+
+// ThemeNames returns the names of the built-in themes.
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupTheme returns the built-in theme with the given name.
+func LookupTheme(name string) (Theme, error) {
+	t, ok := themes[strings.ToLower(name)]
+	if !ok {
+		return Theme{}, fmt.Errorf("board: unknown theme %q: want one of %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	return t, nil
+}
+
+// DefaultRenderOptions returns the options used by AsSVG.
+// The theme is "mono" or "color".
+func DefaultRenderOptions(mono bool) RenderOptions {
+	o := RenderOptions{
+		HexSize:     55,
+		Orientation: "flat",
+		Offset:      "even",
+		Theme:       themes["color"],
+		StrokeWidth: 2,
+		WarpWidth:   2,
+		Margin:      40,
+	}
+	if mono {
+		o.Theme = themes["mono"]
+	}
+	return o
+}
+
+// ReadRenderOptions returns options from a JSON theme file.
+// The file holds a RenderOptions object. Anything it leaves out is taken
+// from the defaults and, if the theme names a built-in theme, from that
+// theme, so a file can tweak a single color of an existing theme.
+func ReadRenderOptions(data []byte) (RenderOptions, error) {
+	var named struct {
+		Theme struct {
+			Name string `json:"name"`
+		} `json:"theme"`
+	}
+	if err := json.Unmarshal(data, &named); err != nil {
+		return RenderOptions{}, fmt.Errorf("board: theme file: %w", err)
+	}
+	o := DefaultRenderOptions(false)
+	if t, ok := themes[strings.ToLower(named.Theme.Name)]; ok {
+		o.Theme = t
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return RenderOptions{}, fmt.Errorf("board: theme file: %w", err)
+	}
+	return o, o.Validate()
+}
+
+// Validate returns an error if any of the options are invalid.
+func (o RenderOptions) Validate() error {
+	for _, v := range []float64{o.HexSize, o.FontSize, o.StrokeWidth, o.WarpWidth, o.Margin} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("board: sizes must be finite")
+		} else if v < 0 {
+			return fmt.Errorf("board: sizes must not be negative")
+		}
+	}
+	switch strings.ToLower(o.Orientation) {
+	case "", "flat", "pointy":
+	default:
+		return fmt.Errorf("board: orientation must be flat or pointy, not %q", o.Orientation)
+	}
+	switch strings.ToLower(o.Offset) {
+	case "", "even", "odd":
+	default:
+		return fmt.Errorf("board: offset must be even or odd, not %q", o.Offset)
+	}
+	for _, c := range []string{o.Theme.Background, o.Theme.HexFill, o.Theme.HexStroke, o.Theme.LabelColor, o.Theme.StarFill, o.Theme.StarStroke, o.Theme.TextColor, o.Theme.WarpColor} {
+		if _, _, err := parseColor(c); err != nil {
+			return err
+		}
+	}
+	if strings.ContainsAny(o.FontFamily, `<>&"`) {
+		return fmt.Errorf("board: invalid font family %q", o.FontFamily)
+	}
+	return nil
+}
+
+// WithDefaults fills in any missing options.
+func (o RenderOptions) WithDefaults() RenderOptions {
+	d := DefaultRenderOptions(false)
+	if o.HexSize == 0 {
+		o.HexSize = d.HexSize
+	}
+	if o.Orientation == "" {
+		o.Orientation = d.Orientation
+	}
+	if o.Offset == "" {
+		o.Offset = d.Offset
+	}
+	if o.FontSize == 0 {
+		o.FontSize = 14 * o.HexSize / 55
+	}
+	if o.StrokeWidth == 0 {
+		o.StrokeWidth = d.StrokeWidth
+	}
+	if o.WarpWidth == 0 {
+		o.WarpWidth = d.WarpWidth
+	}
+	if o.Margin == 0 {
+		o.Margin = d.Margin
+	}
+	return o
+}
+
+// Geometry returns the orientation and offset of the options.
+func (o RenderOptions) Geometry() Geometry {
+	return Geometry{Orientation: o.Orientation, Offset: o.Offset}
+}
+
+// layout returns the hex layout and a function that converts
+// the offset coordinates of the board to cube coordinates.
+func (o RenderOptions) layout(origin hexes.Point) (hexes.Layout, func(col, row int) hexes.Hex) {
+	g, size := o.Geometry(), hexes.NewPoint(o.HexSize, o.HexSize)
+	toCube := func(col, row int) hexes.Hex {
+		return g.ToCube(Coords{Col: col, Row: row})
+	}
+	if layout, _ := g.Layout(); layout == hexes.POINTYHEX {
+		return hexes.NewPointyLayout(size, origin), toCube
+	}
+	return hexes.NewFlatLayout(size, origin), toCube
+}
@@ -0,0 +1,186 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want color.RGBA
+		ok   bool
+	}{
+		{"none", color.RGBA{}, false},
+		{"Grey", color.RGBA{0x80, 0x80, 0x80, 0xff}, true},
+		{"#fff", color.RGBA{0xff, 0xff, 0xff, 0xff}, true},
+		{"#1a2B3c", color.RGBA{0x1a, 0x2b, 0x3c, 0xff}, true},
+		{"hsl(0, 100%, 50%)", color.RGBA{0xff, 0x00, 0x00, 0xff}, true},
+		{"hsl(197, 78%, 85%)", color.RGBA{0xbb, 0xe6, 0xf7, 0xff}, true},
+	} {
+		got, ok, err := parseColor(tc.s)
+		if err != nil {
+			t.Errorf("%q: %v", tc.s, err)
+		} else if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: want %v %v, got %v %v", tc.s, tc.want, tc.ok, got, ok)
+		}
+	}
+	for _, s := range []string{"#12", "hsl(1, 2)", "chartreuse-ish"} {
+		if _, _, err := parseColor(s); err == nil {
+			t.Errorf("%q: want error, got none", s)
+		}
+	}
+}
+
+func TestAsPNG(t *testing.T) {
+	b := NewStandardBoard()
+	data, err := b.AsPNG(false, 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// half of actual size, plus rounding up
+	s := b.asSVG(DefaultRenderOptions(false))
+	if got, want := img.Bounds().Dx(), (s.viewBox.width+1)/2; got != want {
+		t.Errorf("width: want %d, got %d", want, got)
+	}
+	// the middle of a star is filled with the star color
+	star := b.Stars["Ur"]
+	for _, p := range s.polygons {
+		if p.text[0] == star.Name {
+			r, g, bl, _ := img.At(int(p.cx/2), int(p.cy/2+2)).RGBA()
+			if want := (color.RGBA{0xff, 0xfb, 0xe0, 0xff}); uint8(r>>8) != want.R || uint8(g>>8) != want.G || uint8(bl>>8) < 0xd0 {
+				t.Errorf("star fill: want %v, got %d %d %d", want, r>>8, g>>8, bl>>8)
+			}
+		}
+	}
+	if _, err := b.AsPNG(false, 0); err == nil {
+		t.Errorf("dpi 0: want error, got none")
+	}
+}
+
+func TestAsPDF(t *testing.T) {
+	b := NewStandardBoard()
+	for _, tc := range []struct {
+		paper string
+		pages int
+	}{
+		{"letter", 6},
+		{"A4", 6},
+	} {
+		data, err := b.AsPDF(true, tc.paper)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.paper, err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+			t.Errorf("%s: not a pdf file", tc.paper)
+		}
+		if got := bytes.Count(data, []byte("/Type /Page /Parent")); got != tc.pages {
+			t.Errorf("%s: pages: want %d, got %d", tc.paper, tc.pages, got)
+		}
+	}
+	if _, err := b.AsPDF(true, "tabloid"); err == nil || !strings.Contains(err.Error(), "tabloid") {
+		t.Errorf("tabloid: want error, got %v", err)
+	}
+}
+
+func TestReadRenderOptions(t *testing.T) {
+	o, err := ReadRenderOptions([]byte(`{"orientation": "pointy", "hex-size": 30, "theme": {"name": "dark", "warp-color": "#ff8800"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dark, _ := LookupTheme("dark")
+	if o.Orientation != "pointy" || o.HexSize != 30 || o.Offset != "even" || o.Margin != 40 {
+		t.Errorf("options: got %+v", o)
+	}
+	if o.Theme.WarpColor != "#ff8800" || o.Theme.HexFill != dark.HexFill {
+		t.Errorf("theme: want dark with orange warps, got %+v", o.Theme)
+	}
+	for _, data := range []string{
+		`{"orientation": "sideways"}`,
+		`{"theme": {"hex-fill": "plaid"}}`,
+		`{"hex-size": -1}`,
+		`{"font-family": "\"><script>"}`,
+		`not json`,
+	} {
+		if _, err := ReadRenderOptions([]byte(data)); err == nil {
+			t.Errorf("%s: want error, got none", data)
+		}
+	}
+}
+
+func TestValidateNotFinite(t *testing.T) {
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		for _, set := range []func(o *RenderOptions){
+			func(o *RenderOptions) { o.HexSize = v },
+			func(o *RenderOptions) { o.FontSize = v },
+			func(o *RenderOptions) { o.StrokeWidth = v },
+			func(o *RenderOptions) { o.WarpWidth = v },
+			func(o *RenderOptions) { o.Margin = v },
+		} {
+			o := DefaultRenderOptions(false)
+			set(&o)
+			if err := o.Validate(); err == nil {
+				t.Errorf("%+v: want error, got none", o)
+			}
+		}
+	}
+}
+
+func TestRenderLayout(t *testing.T) {
+	b := NewStandardBoard()
+	for _, o := range []RenderOptions{
+		DefaultRenderOptions(false),
+		{Orientation: "pointy", Offset: "odd", HexSize: 20, Margin: 10},
+	} {
+		s := b.asSVG(o)
+		o = o.WithDefaults()
+		minX, minY, maxX, maxY := 1e9, 1e9, -1e9, -1e9
+		for _, h := range s.hexes {
+			for _, p := range h.points {
+				minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+				maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+			}
+		}
+		if math.Abs(minX-o.Margin) > 1e-6 || math.Abs(minY-o.Margin) > 1e-6 {
+			t.Errorf("%s: top left: want %v, got %v, %v", o.Orientation, o.Margin, minX, minY)
+		}
+		if w := float64(s.viewBox.width); w < maxX+o.Margin || w > maxX+o.Margin+1 {
+			t.Errorf("%s: width: want %v, got %v", o.Orientation, maxX+o.Margin, w)
+		}
+		if h := float64(s.viewBox.height); h < maxY+o.Margin || h > maxY+o.Margin+1 {
+			t.Errorf("%s: height: want %v, got %v", o.Orientation, maxY+o.Margin, h)
+		}
+	}
+}
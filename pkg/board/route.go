@@ -0,0 +1,253 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"github.com/mdhender/wow/pkg/hexes"
+	"sort"
+)
+
+// ErrNoRoute is returned when two stars aren't connected by warp lines.
+var ErrNoRoute = errors.New("no route")
+
+// Route returns the quickest path along the warp lines from one star to
+// another, including both ends. Each warp takes as many jumps as its cost,
+// and one-way warps can only be travelled in their direction. When there
+// are several quickest paths, it prefers the one that visits stars earlier
+// in alphabetical order.
+func (b *Board) Route(from, to string) ([]string, error) {
+	if _, ok := b.Stars[from]; !ok {
+		return nil, fmt.Errorf("board: invalid source star: %q", from)
+	} else if _, ok := b.Stars[to]; !ok {
+		return nil, fmt.Errorf("board: invalid target star: %q", to)
+	}
+	next := b.nextHops(to, b.incoming())
+	route := []string{from}
+	for star := from; star != to; route = append(route, star) {
+		hop, ok := next[star]
+		if !ok {
+			return nil, fmt.Errorf("board: %s to %s: %w", from, to, ErrNoRoute)
+		}
+		star = hop
+	}
+	return route, nil
+}
+
+// RouteCost returns the number of jumps needed to follow a route.
+func (b *Board) RouteCost(route []string) int {
+	var total int
+	for i := 1; i < len(route); i++ {
+		w, _ := b.Warp(route[i-1], route[i])
+		total += w.Jumps()
+	}
+	return total
+}
+
+// NextHops returns a routing table for the warp lines.
+// For every star that can reach another, next[from][to] is the first
+// star to jump to on the quickest route from one to the other.
+func (b *Board) NextHops() map[string]map[string]string {
+	next := make(map[string]map[string]string)
+	for name := range b.Stars {
+		next[name] = make(map[string]string)
+	}
+	incoming := b.incoming()
+	for to := range b.Stars {
+		for from, hop := range b.nextHops(to, incoming) {
+			next[from][to] = hop
+		}
+	}
+	return next
+}
+
+// nextHops searches back along the warps that lead to the destination.
+// It returns the next star on the way to the destination from each
+// star that can reach it.
+func (b *Board) nextHops(to string, incoming map[string][]string) map[string]string {
+	// dijkstra's algorithm. stars the same number of jumps away are
+	// finished in the order they were reached, so that with warps of one
+	// jump it searches breadth first. a star may be queued more than once;
+	// only the first time it comes off the queue counts.
+	jumps := map[string]int{to: 0}
+	done := make(map[string]bool)
+	next := make(map[string]string)
+	queue := &hopQueue{{name: to}}
+	for seq := 1; queue.Len() > 0; {
+		best := heap.Pop(queue).(hop)
+		if done[best.name] {
+			continue
+		}
+		done[best.name] = true
+		for _, from := range incoming[best.name] {
+			w, _ := b.Warp(from, best.name)
+			n := best.jumps + w.Jumps()
+			if j, ok := jumps[from]; !ok || (!done[from] && n < j) {
+				jumps[from], next[from] = n, best.name
+				heap.Push(queue, hop{name: from, jumps: n, seq: seq})
+				seq++
+			}
+		}
+	}
+	return next
+}
+
+// hop is a star waiting to be finished by nextHops.
+type hop struct {
+	name       string
+	jumps, seq int
+}
+
+// hopQueue is a priority queue of stars, nearest first, then first reached.
+// It implements heap.Interface.
+type hopQueue []hop
+
+func (q hopQueue) Len() int { return len(q) }
+func (q hopQueue) Less(i, j int) bool {
+	return q[i].jumps < q[j].jumps || (q[i].jumps == q[j].jumps && q[i].seq < q[j].seq)
+}
+func (q hopQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *hopQueue) Push(x interface{}) { *q = append(*q, x.(hop)) }
+func (q *hopQueue) Pop() interface{} {
+	old := *q
+	h := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return h
+}
+
+// neighbours returns the names of the stars one warp line away, sorted.
+// A one-way warp only makes its target a neighbour of its source.
+func (b *Board) neighbours(name string) []string {
+	var names []string
+	for _, h := range b.Stars[name].WormHoleExits {
+		names = append(names, h.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// incoming returns, for each star, the sorted names of the stars that
+// have a warp to it.
+func (b *Board) incoming() map[string][]string {
+	incoming := make(map[string][]string)
+	for name, hex := range b.Stars {
+		for _, h := range hex.WormHoleExits {
+			incoming[h.Name] = append(incoming[h.Name], name)
+		}
+	}
+	for _, names := range incoming {
+		sort.Strings(names)
+	}
+	return incoming
+}
+
+// Path returns the cheapest path from one hex to another, moving a hex
+// at a time through the hexes on the board, including both ends.
+// Entering a hex costs its movement points (see Features.MoveCost);
+// when paths cost the same, it takes the one with fewer hexes.
+// Impassable hexes and hexes outside the mask can't be entered, and the
+// path can cross the edges of a board that wraps.
+func (b *Board) Path(g Geometry, from, to Coords) ([]Coords, error) {
+	if !b.OnBoard(from) {
+		return nil, fmt.Errorf("board: %d, %d is not on the board", from.Col, from.Row)
+	} else if !b.OnBoard(to) {
+		return nil, fmt.Errorf("board: %d, %d is not on the board", to.Col, to.Row)
+	}
+	// dijkstra's algorithm; the boards are small enough to scan for the next hex
+	type step struct {
+		cost, hexes int
+		prev        Coords
+		done        bool
+	}
+	steps := map[Coords]*step{from: {}}
+	for {
+		var hex Coords
+		var best *step
+		for c, s := range steps {
+			if !s.done && (best == nil || s.cost < best.cost || (s.cost == best.cost && (s.hexes < best.hexes || (s.hexes == best.hexes && c.Less(hex))))) {
+				hex, best = c, s
+			}
+		}
+		if best == nil {
+			return nil, fmt.Errorf("board: %d, %d to %d, %d: %w", from.Col, from.Row, to.Col, to.Row, ErrNoRoute)
+		} else if hex == to {
+			break
+		}
+		best.done = true
+		for _, n := range b.Neighbors(g, hex) {
+			cost, ok := b.MoveCost(n)
+			if !ok {
+				continue
+			}
+			next := step{cost: best.cost + cost, hexes: best.hexes + 1, prev: hex}
+			if s, ok := steps[n]; !ok || (!s.done && (next.cost < s.cost || (next.cost == s.cost && next.hexes < s.hexes))) {
+				steps[n] = &next
+			}
+		}
+	}
+	path := []Coords{to}
+	for hex := to; hex != from; path = append(path, hex) {
+		hex = steps[hex].prev
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// PathCost returns the movement points needed to follow a path.
+// Entering each hex after the first costs its movement points.
+func (b *Board) PathCost(path []Coords) int {
+	var total int
+	for i := 1; i < len(path); i++ {
+		cost, _ := b.MoveCost(path[i])
+		total += cost
+	}
+	return total
+}
+
+// FieldOfView returns the hexes on the board within radius steps of a hex
+// that can be seen from it, sorted. Hexes with features that block sight
+// hide the hexes behind them, but can be seen themselves (see
+// hexes.Hex.FieldOfView). Sight doesn't cross the edges of a board that
+// wraps.
+func (b *Board) FieldOfView(g Geometry, from Coords, radius int) []Coords {
+	blocked := func(h hexes.Hex) bool {
+		c := g.FromCube(h)
+		return b.OnBoard(c) && b.Hexes[c.Row][c.Col].Features.BlocksSight()
+	}
+	var list []Coords
+	for h := range g.ToCube(from).FieldOfView(radius, blocked) {
+		if c := g.FromCube(h); b.OnBoard(c) {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Less(list[j])
+	})
+	return list
+}
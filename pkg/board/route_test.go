@@ -0,0 +1,121 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	b := NewStandardBoard()
+	b.AddStar("Lost", 20, 1, 0)
+	for _, tc := range []struct {
+		from, to string
+		want     []string
+	}{
+		{"Ur", "Ur", []string{"Ur"}},
+		{"Ur", "Erech", []string{"Ur", "Erech"}},
+		{"Ur", "Khafa", []string{"Ur", "Erech", "Adab", "Khafa"}},
+		{"Khafa", "Ur", []string{"Khafa", "Adab", "Erech", "Ur"}},
+	} {
+		got, err := b.Route(tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%s to %s: %v", tc.from, tc.to, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s to %s: want %v, got %v", tc.from, tc.to, tc.want, got)
+		}
+	}
+	if _, err := b.Route("Ur", "Lost"); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("Ur to Lost: want %v, got %v", ErrNoRoute, err)
+	}
+	if _, err := b.Route("Ur", "Atlantis"); err == nil || errors.Is(err, ErrNoRoute) {
+		t.Errorf("Ur to Atlantis: want invalid star, got %v", err)
+	}
+
+	// every route in the table must agree with Route
+	next := b.NextHops()
+	for from, hops := range next {
+		for to, hop := range hops {
+			route, err := b.Route(from, to)
+			if err != nil || route[1] != hop {
+				t.Errorf("next[%s][%s]: want %v, got %q", from, to, route, hop)
+			}
+		}
+	}
+	if len(next["Lost"]) != 0 {
+		t.Errorf("next[Lost]: want no routes, got %v", next["Lost"])
+	}
+}
+
+func TestRenderViewer(t *testing.T) {
+	b := NewStandardBoard()
+	page, err := b.RenderViewer(DefaultRenderOptions(false), `Tom & "Jerry"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(page)
+	for _, want := range []string{
+		"<title>Tom &amp; &#34;Jerry&#34;</title>",
+		`<circle class="star" data-star="Ur"`,
+		`<line class="warp" data-from="Ur" data-to="Erech"`,
+		`<text class="coord"`,
+		"function route(from, to)",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("viewer: want %q", want)
+		}
+	}
+	// self-contained, so nothing is loaded from elsewhere
+	if m := regexp.MustCompile(`(src|href)=`).FindString(html); m != "" {
+		t.Errorf("viewer: want no external resources, found %q", m)
+	}
+
+	m := regexp.MustCompile(`(?s)<script id="board-data" type="application/json">(.*?)</script>`).FindStringSubmatch(html)
+	if m == nil {
+		t.Fatal("viewer: board data not found")
+	}
+	var data struct {
+		Stars []struct {
+			Name  string   `json:"name"`
+			Econ  int      `json:"econ"`
+			Warps []string `json:"warps"`
+		} `json:"stars"`
+	}
+	if err := json.Unmarshal([]byte(m[1]), &data); err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Stars) != len(b.Stars) {
+		t.Errorf("stars: want %d, got %d", len(b.Stars), len(data.Stars))
+	}
+	// routes are found by the script, so the page only grows with the stars
+	if strings.Contains(m[1], `"next"`) {
+		t.Errorf("viewer: want no routing table in the board data")
+	}
+}
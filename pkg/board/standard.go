@@ -0,0 +1,69 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+// StandardNodes returns the stars and warp lines of the standard map.
+// The layout is an untested guess at the original map.
+func StandardNodes() []Node {
+	return []Node{
+		{Name: "Adab", Col: 6, Row: 6, EconValue: 0, Warps: []string{"Erech", "Khafa", "Byblos"}},
+		{Name: "Akkad", Col: 7, Row: 16, EconValue: 3, Warps: []string{"Kish"}},
+		{Name: "Assur", Col: 12, Row: 10, EconValue: 2, Warps: []string{"Nippur", "Lagash"}},
+		{Name: "Babylon", Col: 6, Row: 18, EconValue: 4, Warps: []string{"Sumer"}},
+		{Name: "Byblos", Col: 2, Row: 6, EconValue: 3, Warps: []string{"Adab"}},
+		{Name: "Calah", Col: 8, Row: 4, EconValue: 1, Warps: []string{"Nippur"}},
+		{Name: "Elam", Col: 7, Row: 12, EconValue: 5, Warps: []string{"Lagash"}},
+		{Name: "Erech", Col: 4, Row: 4, EconValue: 3, Warps: []string{"Ur", "Adab"}},
+		{Name: "Eridu", Col: 12, Row: 16, EconValue: 1, Warps: []string{"Kish", "Ugarit"}},
+		{Name: "Girsu", Col: 8, Row: 13, EconValue: 1, Warps: []string{"Umma"}},
+		{Name: "Jarmo", Col: 11, Row: 12, EconValue: 3, Warps: []string{"Kish"}},
+		{Name: "Isin", Col: 1, Row: 15, EconValue: 1, Warps: []string{"Nineveh"}},
+		{Name: "Khafa", Col: 7, Row: 9, EconValue: 2, Warps: []string{"Adab"}},
+		{Name: "Kish", Col: 10, Row: 15, EconValue: 0, Warps: []string{"Jarmo", "Eridu"}},
+		{Name: "Lagash", Col: 9, Row: 11, EconValue: 1, Warps: []string{"Assur"}},
+		{Name: "Larsu", Col: 11, Row: 2, EconValue: 2, Warps: []string{"Susa"}},
+		{Name: "Mari", Col: 6, Row: 10, EconValue: 1, Warps: []string{"Ubaid", "Umma"}},
+		{Name: "Mosul", Col: 3, Row: 1, EconValue: 2, Warps: []string{"Sippur"}},
+		{Name: "Nineveh", Col: 3, Row: 19, EconValue: 2, Warps: []string{"Isin"}},
+		{Name: "Nippur", Col: 10, Row: 7, EconValue: 1, Warps: []string{"Calah", "Susa", "Assur", "Lagash"}},
+		{Name: "Sippur", Col: 2, Row: 4, EconValue: 1, Warps: []string{"Mosul"}},
+		{Name: "Sumarra", Col: 2, Row: 12, EconValue: 2, Warps: []string{"Ubaid", "Umma"}},
+		{Name: "Sumer", Col: 4, Row: 16, EconValue: 0, Warps: []string{"Umma", "Babylon"}},
+		{Name: "Susa", Col: 12, Row: 5, EconValue: 0, Warps: []string{"Larsu", "Nippur"}},
+		{Name: "Ubaid", Col: 3, Row: 8, EconValue: 5, Warps: []string{"Mari", "Sumarra"}},
+		{Name: "Ugarit", Col: 11, Row: 20, EconValue: 2, Warps: []string{"Eridu"}},
+		{Name: "Umma", Col: 5, Row: 14, EconValue: 2, Warps: []string{"Sumarra", "Mari", "Girsu", "Sumer"}},
+		{Name: "Ur", Col: 7, Row: 2, EconValue: 4, Warps: []string{"Erech"}},
+	}
+}
+
+// NewStandardBoard returns a board with the standard map.
+func NewStandardBoard() *Board {
+	b, err := FromNodes(StandardNodes())
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,85 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"github.com/mdhender/wow/pkg/hexes"
+	"math"
+)
+
+// Stub is a warp line that leaves the board, like a warp to a star in
+// another sector. It is drawn as a short dashed line heading away from the
+// middle of the board, with the label at its end.
+type Stub struct {
+	Star  string `json:"star"`  // star on this board
+	Label string `json:"label"` // destination of the warp
+}
+
+// stub is a Stub laid out on the svg.
+type stub struct {
+	x1, y1, x2, y2 float64 // the line, from the edge of the star outwards
+	lx, ly         float64 // the bottom middle of the label
+	star, label    string
+}
+
+// stubs lays out the stubs of the board. Stubs on the same star fan out
+// from the first, which points directly away from the middle of the board.
+func (b *Board) stubs(layout hexes.Layout, toCube func(col, row int) hexes.Hex, radius, fontSize float64) []stub {
+	mx, my := layout.CenterPoint(toCube(b.Cols/2, b.Rows/2)).Coords()
+	var list []stub
+	count := make(map[string]int)
+	for _, st := range b.Stubs {
+		hex, ok := b.Stars[st.Star]
+		if !ok {
+			continue
+		}
+		cx, cy := layout.CenterPoint(toCube(hex.Coords.Col, hex.Coords.Row)).Coords()
+		angle := -math.Pi / 2 // straight up from the middle of the board
+		if cx != mx || cy != my {
+			angle = math.Atan2(cy-my, cx-mx)
+		}
+		// 0, +30, -30, +60, -60 degrees and so on
+		n := count[st.Star]
+		count[st.Star]++
+		angle += float64((n+1)/2) * math.Pi / 6 * float64(1-2*(n%2))
+
+		dx, dy := math.Cos(angle), math.Sin(angle)
+		s := stub{star: st.Star, label: st.Label}
+		s.x1, s.y1 = cx+dx*radius*0.88, cy+dy*radius*0.88
+		s.x2, s.y2 = cx+dx*radius*2.2, cy+dy*radius*2.2
+		s.lx, s.ly = s.x2+dx*fontSize, s.y2+dy*fontSize+fontSize/3
+		list = append(list, s)
+	}
+	return list
+}
+
+// bounds returns the corners of the box around the stub and its label.
+// The width of the label is estimated from the number of characters.
+func (s stub) bounds(fontSize float64) (minX, minY, maxX, maxY float64) {
+	half := float64(len(s.label)) * fontSize * 0.35
+	minX, maxX = math.Min(s.x2, s.lx-half), math.Max(s.x2, s.lx+half)
+	minY, maxY = math.Min(s.y2, s.ly-fontSize), math.Max(s.y2, s.ly)
+	return minX, minY, maxX, maxY
+}
@@ -25,7 +25,11 @@
 package board
 
 import (
-	"fmt"
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // svg is the container for our board
@@ -37,50 +41,298 @@ type svg struct {
 	}
 	hexes    []*polygon
 	polygons []*polygon
-	lines    [][4]float64
+	lines    []warpLine
+	stubs    []stub // warp lines that leave the board
+
+	background string
+	fontFamily string
+	fontSize   float64
+	labelColor string // hex coordinates
+	textColor  string // star names and values
+	warpColor  string
+	warpWidth  float64
+
+	// interactive adds the classes and data attributes used by the viewer
+	interactive bool
 }
 
+// String returns the SVG document.
 func (s svg) String() string {
-	fontSize := 14
-	t := "<svg"
+	sb := &strings.Builder{}
+	_, _ = s.WriteTo(sb)
+	return sb.String()
+}
+
+// WriteTo implements the io.WriterTo interface.
+// It streams the SVG document to w, escaping all the text and attribute values.
+func (s svg) WriteTo(w io.Writer) (int64, error) {
+	x := newXMLWriter(w)
+	fontSize := s.fontSize
+
+	// tag adds the viewer's class and star name to an element
+	tag := func(class, star string) {
+		if !s.interactive {
+			return
+		}
+		x.str(` class="`).str(class).str(`"`)
+		if star != "" {
+			x.str(` data-star="`).escape(star).str(`"`)
+		}
+	}
+
+	// text writes a bold text element with the given fill and font size
+	text := func(cx, cy float64, fill string, size float64, content, class, star string) {
+		x.str(`<text`)
+		tag(class, star)
+		x.str(` x="`).float(cx).str(`" y="`).float(cy).str(`" text-anchor="middle" fill="`).escape(fill)
+		x.str(`" font-size="`).general(size).str(`" font-weight="bold"`)
+		if s.fontFamily != "" {
+			x.str(` font-family="`).escape(s.fontFamily).str(`"`)
+		}
+		x.str(`>`).escape(content).str(`</text>`)
+	}
+
+	x.str("<svg")
 	if s.id != "" {
-		t += fmt.Sprintf(" id=%q", s.id)
+		x.str(` id="`).escape(s.id).str(`"`)
 	}
-	t += fmt.Sprintf(` width="%d" height="%d"`, s.viewBox.width+40, s.viewBox.height+40)
-	t += fmt.Sprintf(` viewBox="%d %d %d %d"`, s.viewBox.minX, s.viewBox.minY, s.viewBox.width+40, s.viewBox.height+40)
-	t += ` xmlns="http://www.w3.org/2000/svg">`
+	x.str(` width="`).int(s.viewBox.width).str(`" height="`).int(s.viewBox.height).str(`"`)
+	x.str(` viewBox="`).int(s.viewBox.minX).str(" ").int(s.viewBox.minY).str(" ").int(s.viewBox.width).str(" ").int(s.viewBox.height).str(`"`)
+	x.str(` xmlns="http://www.w3.org/2000/svg">`)
+	if _, ok, _ := parseColor(s.background); ok {
+		x.str(`<rect x="`).int(s.viewBox.minX).str(`" y="`).int(s.viewBox.minY).str(`" width="`).int(s.viewBox.width).str(`" height="`).int(s.viewBox.height)
+		x.str(`" fill="`).escape(s.background).str("\"/>\n")
+	}
+	var features Features
 	for _, h := range s.hexes {
-		if len(h.points) == 0 {
-			continue
+		features |= h.features
+	}
+	if features != 0 {
+		x.str("<defs>")
+		for _, f := range features.List() {
+			x.str(featurePatterns[f])
 		}
-		p := fmt.Sprintf(`<polygon style="fill: %s; stroke: %s; stroke-width: %s;"`, h.style.fill, h.style.stroke, h.style.strokeWidth)
-		p += fmt.Sprintf(` points="`)
+		x.str("</defs>\n")
+	}
+	points := func(h *polygon) {
+		x.str(` points="`)
 		for i, pt := range h.points {
 			if i > 0 {
-				p += " "
+				x.str(" ")
+			}
+			x.float(pt.x).str(",").float(pt.y)
+		}
+		x.str(`"`)
+	}
+	for _, h := range s.hexes {
+		if len(h.points) == 0 {
+			continue
+		}
+		x.str(`<polygon style="fill: `).escape(h.style.fill).str(`; stroke: `).escape(h.style.stroke).str(`; stroke-width: `).escape(h.style.strokeWidth).str(`;"`)
+		points(h)
+		x.str("></polygon>\n")
+		// features are drawn over the hex, each with its own pattern
+		for _, f := range h.features.List() {
+			x.str(`<polygon`)
+			if s.interactive {
+				x.str(` class="feature `).str(f.String()).str(`"`)
 			}
-			p += pt.String()
+			x.str(` fill="url(#feature-`).str(f.String()).str(`)" stroke="none"`)
+			points(h)
+			x.str("></polygon>\n")
 		}
-		p += `"`
-		p += "></polygon>\n"
-		p += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="grey" font-size="%d" font-weight="bold">%s</text>`, h.cx, h.cy, fontSize, fmt.Sprintf("%02d%02d", h.col, h.row))
-		t += p
+		text(h.cx, h.cy, s.labelColor, fontSize, Coords{Col: h.col, Row: h.row}.String(), "coord", "")
 	}
 	for _, l := range s.lines {
-		x1, y1, x2, y2 := l[0], l[1], l[2], l[3]
-		t += fmt.Sprintf(`<line x1="%f" y1="%f" x2="%f" y2="%f" stroke-width="2" stroke="black"/>`, x1, y1, x2, y2)
+		if l.curved {
+			x.str(`<path`)
+		} else {
+			x.str(`<line`)
+		}
+		if s.interactive {
+			x.str(` class="warp" data-from="`).escape(l.from).str(`" data-to="`).escape(l.to).str(`"`)
+		}
+		if l.curved {
+			x.str(` d="M `).float(l.x1).str(" ").float(l.y1).str(" Q ").float(l.qx).str(" ").float(l.qy).str(" ").float(l.x2).str(" ").float(l.y2)
+			x.str(`" fill="none`)
+		} else {
+			x.str(` x1="`).float(l.x1).str(`" y1="`).float(l.y1).str(`" x2="`).float(l.x2).str(`" y2="`).float(l.y2)
+		}
+		x.str(`" stroke-width="`).general(s.warpWidth).str(`" stroke="`).escape(s.warpColor)
+		if l.dashed {
+			x.str(`" stroke-dasharray="`).general(s.warpWidth * 4).str(" ").general(s.warpWidth * 2)
+		}
+		x.str(`"/>`)
+		if len(l.head) != 0 {
+			x.str(`<polygon`)
+			if s.interactive {
+				x.str(` class="warp-head" data-from="`).escape(l.from).str(`" data-to="`).escape(l.to).str(`"`)
+			}
+			x.str(` points="`)
+			for i, pt := range l.head {
+				if i > 0 {
+					x.str(" ")
+				}
+				x.float(pt.x).str(",").float(pt.y)
+			}
+			x.str(`" fill="`).escape(s.warpColor).str(`"/>`)
+		}
+	}
+	for _, st := range s.stubs {
+		x.str(`<line`)
+		if s.interactive {
+			x.str(` class="stub" data-from="`).escape(st.star).str(`" data-to="`).escape(st.label).str(`"`)
+		}
+		x.str(` x1="`).float(st.x1).str(`" y1="`).float(st.y1).str(`" x2="`).float(st.x2).str(`" y2="`).float(st.y2)
+		x.str(`" stroke-width="`).general(s.warpWidth).str(`" stroke="`).escape(s.warpColor).str(`" stroke-dasharray="`).general(s.warpWidth * 3).str(`"/>`)
+		text(st.lx, st.ly, s.warpColor, fontSize, st.label, "stub-text", st.star)
 	}
 	for _, p := range s.polygons {
-		t += fmt.Sprintf(`<circle cx="%f" cy="%f" r="%f" style="fill: %s; stroke: %s; stroke-width: %s" />`, p.cx, p.cy, p.radius*0.88, p.style.fill, p.style.stroke, p.style.strokeWidth) + "\n"
+		var name string
+		if len(p.text) != 0 {
+			name = p.text[0]
+		}
+		x.str(`<circle`)
+		tag("star", name)
+		x.str(` cx="`).float(p.cx).str(`" cy="`).float(p.cy).str(`" r="`).float(p.radius * 0.88)
+		x.str(`" style="fill: `).escape(p.style.fill).str(`; stroke: `).escape(p.style.stroke).str(`; stroke-width: `).escape(p.style.strokeWidth).str("\" />\n")
 
-		yOffset := float64(fontSize) * 0.6
-		for i, text := range p.text {
+		yOffset := fontSize * 0.6
+		for i, t := range p.text {
 			if i == 0 {
-				t += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="black" font-size="%d" font-weight="bold">%s</text>`, p.cx, p.cy-yOffset, fontSize, text)
+				text(p.lx, p.ly, s.textColor, fontSize, t, "star-text", name)
 			} else {
-				t += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="black" font-size="%d" font-weight="bold">%s</text>`, p.cx, p.cy+yOffset*3, fontSize+2, text)
+				text(p.cx, p.cy+yOffset*3, s.textColor, fontSize+2, t, "star-text", name)
 			}
 		}
 	}
-	return t + "\n</svg>"
+	// the labels of the warps are drawn over the stars so they can always be read
+	for _, l := range s.lines {
+		if l.label != "" {
+			text(l.lx, l.ly, s.warpColor, fontSize, l.label, "warp-text", l.from)
+		}
+	}
+	x.str("\n</svg>")
+	return x.flush()
+}
+
+// featurePatterns are the svg patterns that fill the hexes with features.
+// Each is named "feature-" and the name of the feature.
+var featurePatterns = map[Features]string{
+	Nebula: `<pattern id="feature-nebula" width="36" height="36" patternUnits="userSpaceOnUse">` +
+		`<rect width="36" height="36" fill="#9b59b6" fill-opacity="0.25"/>` +
+		`<circle cx="9" cy="10" r="7" fill="#8e44ad" fill-opacity="0.25"/>` +
+		`<circle cx="26" cy="24" r="9" fill="#8e44ad" fill-opacity="0.2"/>` +
+		`<circle cx="28" cy="6" r="3" fill="#6c3483" fill-opacity="0.3"/></pattern>`,
+	Asteroids: `<pattern id="feature-asteroids" width="24" height="24" patternUnits="userSpaceOnUse">` +
+		`<circle cx="5" cy="6" r="2.5" fill="#6e6259"/>` +
+		`<circle cx="16" cy="4" r="1.5" fill="#6e6259"/>` +
+		`<circle cx="13" cy="15" r="3" fill="#857567"/>` +
+		`<circle cx="3" cy="19" r="1.5" fill="#6e6259"/>` +
+		`<circle cx="21" cy="21" r="2" fill="#857567"/></pattern>`,
+	BlackHole: `<radialGradient id="feature-black-hole">` +
+		`<stop offset="0" stop-color="#000"/>` +
+		`<stop offset="0.45" stop-color="#000" stop-opacity="0.9"/>` +
+		`<stop offset="0.6" stop-color="#4a235a" stop-opacity="0.6"/>` +
+		`<stop offset="1" stop-color="#000" stop-opacity="0"/></radialGradient>`,
+	Blocked: `<pattern id="feature-blocked" width="12" height="12" patternUnits="userSpaceOnUse" patternTransform="rotate(45)">` +
+		`<rect width="12" height="12" fill="#7f7f7f" fill-opacity="0.25"/>` +
+		`<line x1="0" y1="0" x2="0" y2="12" stroke="#555" stroke-width="4"/></pattern>`,
+}
+
+// xmlWriter is a buffered writer for XML documents.
+// It remembers the first error so that callers can check once at the end.
+type xmlWriter struct {
+	w       *bufio.Writer
+	n       int64
+	err     error
+	scratch []byte
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	return &xmlWriter{w: bufio.NewWriterSize(w, 32*1024), scratch: make([]byte, 0, 32)}
+}
+
+// xmlEscaper escapes text for use in both elements and attribute values.
+// It drops the control characters that XML 1.0 does not allow.
+// Invalid UTF-8 is replaced before the text gets here (see escape).
+var xmlEscaper = strings.NewReplacer(xmlEscapes()...)
+
+// xmlSpecial lists every character that xmlEscaper changes.
+var xmlSpecial = "&<>\"'" + xmlIllegal()
+
+func xmlEscapes() []string {
+	pairs := []string{"&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&#34;", "'", "&#39;"}
+	for _, r := range xmlIllegal() {
+		pairs = append(pairs, string(r), "")
+	}
+	return pairs
+}
+
+// xmlIllegal returns the control characters that may not appear in XML text.
+func xmlIllegal() string {
+	var sb strings.Builder
+	for r := rune(0); r < 0x20; r++ {
+		if r != '\t' && r != '\n' && r != '\r' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func (x *xmlWriter) str(s string) *xmlWriter {
+	if x.err == nil {
+		var n int
+		n, x.err = x.w.WriteString(s)
+		x.n += int64(n)
+	}
+	return x
+}
+
+func (x *xmlWriter) escape(s string) *xmlWriter {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+	if !strings.ContainsAny(s, xmlSpecial) {
+		return x.str(s)
+	} else if x.err == nil {
+		var n int
+		n, x.err = xmlEscaper.WriteString(x.w, s)
+		x.n += int64(n)
+	}
+	return x
+}
+
+func (x *xmlWriter) bytes(b []byte) *xmlWriter {
+	if x.err == nil {
+		var n int
+		n, x.err = x.w.Write(b)
+		x.n += int64(n)
+	}
+	return x
+}
+
+// float writes f like the %f verb.
+func (x *xmlWriter) float(f float64) *xmlWriter {
+	x.scratch = strconv.AppendFloat(x.scratch[:0], f, 'f', 6, 64)
+	return x.bytes(x.scratch)
+}
+
+// general writes f like the %g verb.
+func (x *xmlWriter) general(f float64) *xmlWriter {
+	x.scratch = strconv.AppendFloat(x.scratch[:0], f, 'g', -1, 64)
+	return x.bytes(x.scratch)
+}
+
+func (x *xmlWriter) int(i int) *xmlWriter {
+	x.scratch = strconv.AppendInt(x.scratch[:0], int64(i), 10)
+	return x.bytes(x.scratch)
+}
+
+// flush writes any buffered data and returns the totals.
+func (x *xmlWriter) flush() (int64, error) {
+	if x.err == nil {
+		x.err = x.w.Flush()
+	}
+	return x.n, x.err
 }
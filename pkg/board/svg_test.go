@@ -0,0 +1,198 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// legacySVG is a frozen copy of the renderer that WriteTo replaced. It
+// builds the document by concatenating strings and doesn't escape anything.
+// It is only kept as the baseline for BenchmarkSVGWriter; don't update it
+// when the renderer changes.
+func legacySVG(s svg) string {
+	fontSize := s.fontSize
+	font := ""
+	if s.fontFamily != "" {
+		font = fmt.Sprintf(` font-family="%s"`, s.fontFamily)
+	}
+	t := "<svg"
+	if s.id != "" {
+		t += fmt.Sprintf(" id=%q", s.id)
+	}
+	t += fmt.Sprintf(` width="%d" height="%d"`, s.viewBox.width, s.viewBox.height)
+	t += fmt.Sprintf(` viewBox="%d %d %d %d"`, s.viewBox.minX, s.viewBox.minY, s.viewBox.width, s.viewBox.height)
+	t += ` xmlns="http://www.w3.org/2000/svg">`
+	if _, ok, _ := parseColor(s.background); ok {
+		t += fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, s.viewBox.minX, s.viewBox.minY, s.viewBox.width, s.viewBox.height, s.background) + "\n"
+	}
+	for _, h := range s.hexes {
+		if len(h.points) == 0 {
+			continue
+		}
+		p := fmt.Sprintf(`<polygon style="fill: %s; stroke: %s; stroke-width: %s;"`, h.style.fill, h.style.stroke, h.style.strokeWidth)
+		p += fmt.Sprintf(` points="`)
+		for i, pt := range h.points {
+			if i > 0 {
+				p += " "
+			}
+			p += pt.String()
+		}
+		p += `"`
+		p += "></polygon>\n"
+		p += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="%s" font-size="%g" font-weight="bold"%s>%s</text>`, h.cx, h.cy, s.labelColor, fontSize, font, fmt.Sprintf("%02d%02d", h.col, h.row))
+		t += p
+	}
+	for _, l := range s.lines {
+		if l.curved {
+			t += fmt.Sprintf(`<path d="M %f %f Q %f %f %f %f" fill="none" stroke-width="%g" stroke="%s"/>`, l.x1, l.y1, l.qx, l.qy, l.x2, l.y2, s.warpWidth, s.warpColor)
+			continue
+		}
+		t += fmt.Sprintf(`<line x1="%f" y1="%f" x2="%f" y2="%f" stroke-width="%g" stroke="%s"/>`, l.x1, l.y1, l.x2, l.y2, s.warpWidth, s.warpColor)
+	}
+	for _, p := range s.polygons {
+		t += fmt.Sprintf(`<circle cx="%f" cy="%f" r="%f" style="fill: %s; stroke: %s; stroke-width: %s" />`, p.cx, p.cy, p.radius*0.88, p.style.fill, p.style.stroke, p.style.strokeWidth) + "\n"
+
+		yOffset := fontSize * 0.6
+		for i, text := range p.text {
+			if i == 0 {
+				t += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="%s" font-size="%g" font-weight="bold"%s>%s</text>`, p.lx, p.ly, s.textColor, fontSize, font, text)
+			} else {
+				t += fmt.Sprintf(`<text x="%f" y="%f" text-anchor="middle" fill="%s" font-size="%g" font-weight="bold"%s>%s</text>`, p.cx, p.cy+yOffset*3, s.textColor, fontSize+2, font, text)
+			}
+		}
+	}
+	return t + "\n</svg>"
+}
+
+// bigBoard returns a 40x40 board with a star in every third hex,
+// each with a warp line to the star before it.
+func bigBoard() *Board {
+	b := NewBoard(40, 40)
+	prev := ""
+	for row := 1; row <= 40; row++ {
+		for col := 1; col <= 40; col++ {
+			if (row*40+col)%3 != 0 {
+				continue
+			}
+			name := fmt.Sprintf("Star %d-%d", col, row)
+			b.AddStar(name, row, col, (row+col)%6)
+			if prev != "" {
+				_ = b.AddWormHole(prev, name)
+			}
+			prev = name
+		}
+	}
+	return b
+}
+
+// TestSVGGolden checks the svg against files written by the renderer that
+// built the document by concatenating strings, before it was replaced by
+// the escaping writer. Only update them when the picture is meant to change.
+func TestSVGGolden(t *testing.T) {
+	themed := RenderOptions{Orientation: "pointy", FontFamily: "Georgia, serif", Theme: themes["parchment"]}
+	for _, o := range []RenderOptions{DefaultRenderOptions(false), DefaultRenderOptions(true), themed} {
+		want, err := os.ReadFile(filepath.Join("testdata", "standard-"+o.Theme.Name+".svg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NewStandardBoard().asSVG(o).String(); got != string(want) {
+			t.Errorf("%s: svg differs from testdata", o.Theme.Name)
+		}
+	}
+}
+
+func TestSVGEscaping(t *testing.T) {
+	b, err := FromNodes([]Node{
+		{Name: "A&B", Col: 1, Row: 1, Warps: []string{"<x>"}},
+		{Name: "<x>", Col: 3, Row: 2},
+		{Name: `"quoted"`, Col: 2, Row: 4},
+		{Name: "Bad\x01Star", Col: 4, Row: 3},
+		{Name: "Ad\xffab", Col: 5, Row: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := b.RenderSVG(DefaultRenderOptions(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the document must be well formed, and the names must come back intact
+	var names []string
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("svg is not well formed: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			inText = tok.Name.Local == "text"
+		case xml.CharData:
+			if inText {
+				names = append(names, string(tok))
+			}
+		case xml.EndElement:
+			inText = false
+		}
+	}
+	for _, want := range []string{"A&B", "<x>", `"quoted"`, "BadStar", "Ad\ufffdab"} {
+		found := false
+		for _, name := range names {
+			found = found || name == want
+		}
+		if !found {
+			t.Errorf("star %q: missing from svg", want)
+		}
+	}
+}
+
+func BenchmarkSVGLegacy(b *testing.B) {
+	s := bigBoard().asSVG(DefaultRenderOptions(false))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = io.WriteString(io.Discard, legacySVG(*s))
+	}
+}
+
+func BenchmarkSVGWriter(b *testing.B) {
+	s := bigBoard().asSVG(DefaultRenderOptions(false))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = s.WriteTo(io.Discard)
+	}
+}
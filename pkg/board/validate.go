@@ -0,0 +1,257 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Limits on maps sent to the web server.
+const (
+	MaxCols  = 40
+	MaxRows  = 40
+	MaxStars = 40
+)
+
+// Severity is how serious a problem with the map data is.
+// Maps with errors can't be drawn; warnings are only advice.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in map data.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Record   int      `json:"record"`          // index of the node or CSV record, or -1 for the whole map
+	Line     int      `json:"line,omitempty"`  // line in the CSV input, if known
+	Field    string   `json:"field,omitempty"` // name, col, row, econ-value or warps
+	Message  string   `json:"message"`
+}
+
+// Error implements the error interface.
+func (d Diagnostic) Error() string {
+	var sb strings.Builder
+	if d.Record >= 0 {
+		_, _ = fmt.Fprintf(&sb, "record %d: ", d.Record+1)
+	}
+	if d.Field != "" {
+		sb.WriteString(d.Field + ": ")
+	}
+	sb.WriteString(d.Message)
+	return sb.String()
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateNodes checks map data against the limits of the web server.
+// It returns every problem found rather than stopping at the first.
+func ValidateNodes(nodes []Node) []Diagnostic {
+	var diagnostics []Diagnostic
+	problem := func(severity Severity, record int, field, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Severity: severity, Record: record, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	if len(nodes) == 0 {
+		problem(SeverityError, -1, "", "missing map data")
+		return diagnostics
+	} else if len(nodes) > MaxStars {
+		problem(SeverityError, -1, "", "maximum number of stars is %d", MaxStars)
+	}
+
+	names := make(map[string]int)
+	hexes := make(map[Coords]string)
+	for i, n := range nodes {
+		if strings.TrimSpace(n.Name) == "" {
+			problem(SeverityError, i, "name", "missing name")
+		} else if other, ok := names[n.Name]; ok {
+			problem(SeverityError, i, "name", "duplicate name %q, first used by record %d", n.Name, other+1)
+		} else {
+			names[n.Name] = i
+		}
+		if n.Col < 1 || n.Col > MaxCols {
+			problem(SeverityError, i, "col", "col must be 1 to %d", MaxCols)
+		}
+		if n.Row < 1 || n.Row > MaxRows {
+			problem(SeverityError, i, "row", "row must be 1 to %d", MaxRows)
+		}
+		if other, ok := hexes[Coords{Col: n.Col, Row: n.Row}]; ok {
+			problem(SeverityError, i, "", "hex %v is taken by %q", Coords{Col: n.Col, Row: n.Row}, other)
+		} else {
+			hexes[Coords{Col: n.Col, Row: n.Row}] = n.Name
+		}
+		if n.EconValue < 0 {
+			problem(SeverityError, i, "econ-value", "economic value must not be negative")
+		}
+	}
+
+	degree := make(map[string]int)
+	for i, n := range nodes {
+		seen := make(map[string]bool)
+		for _, target := range n.Warps {
+			w, err := ParseWarp(n.Name, target)
+			if err != nil {
+				problem(SeverityError, i, "warps", "%s", strings.TrimPrefix(err.Error(), "board: "))
+				continue
+			}
+			target = w.To
+			if target == n.Name {
+				problem(SeverityError, i, "warps", "warp leads back to %q", n.Name)
+			} else if _, ok := names[target]; !ok {
+				problem(SeverityError, i, "warps", "warp to unknown star %q", target)
+			} else if seen[target] {
+				problem(SeverityWarning, i, "warps", "warp to %q is listed more than once", target)
+			} else {
+				degree[n.Name]++
+				degree[target]++
+			}
+			seen[target] = true
+		}
+	}
+	for i, n := range nodes {
+		if degree[n.Name] == 0 && names[n.Name] == i {
+			problem(SeverityWarning, i, "warps", "%q has no warps", n.Name)
+		}
+	}
+	return diagnostics
+}
+
+// ValidateMask checks that the stars are on the hexes of a mask.
+func ValidateMask(nodes []Node, m Mask) []Diagnostic {
+	var diagnostics []Diagnostic
+	for i, n := range nodes {
+		if !m.Contains(Coords{Col: n.Col, Row: n.Row}) {
+			diagnostics = append(diagnostics, Diagnostic{Severity: SeverityError, Record: i, Message: fmt.Sprintf("hex %v is outside the mask", Coords{Col: n.Col, Row: n.Row})})
+		}
+	}
+	return diagnostics
+}
+
+// Stats describes the map and its warp graph.
+// Warps to unknown stars are ignored, and every warp line is counted
+// as going both ways whatever its direction and cost.
+type Stats struct {
+	Stars      int     `json:"stars"`
+	Warps      int     `json:"warps"`      // warp lines, each counted once
+	EconValue  int     `json:"econ-value"` // total for the map
+	Cols       int     `json:"cols"`       // largest column used
+	Rows       int     `json:"rows"`       // largest row used
+	MinWarps   int     `json:"min-warps"`  // fewest warps from a star
+	MaxWarps   int     `json:"max-warps"`  // most warps from a star
+	MeanWarps  float64 `json:"mean-warps"`
+	Isolated   int     `json:"isolated"`   // stars without warps
+	Components int     `json:"components"` // groups of stars connected by warps
+	Diameter   int     `json:"diameter"`   // most jumps on a shortest route between connected stars
+}
+
+// NodeStats returns statistics for map data.
+func NodeStats(nodes []Node) Stats {
+	var stats Stats
+	neighbours := make(map[string]map[string]bool)
+	for _, n := range nodes {
+		if _, ok := neighbours[n.Name]; !ok {
+			neighbours[n.Name] = make(map[string]bool)
+			stats.Stars++
+			stats.EconValue += n.EconValue
+		}
+		if n.Col > stats.Cols {
+			stats.Cols = n.Col
+		}
+		if n.Row > stats.Rows {
+			stats.Rows = n.Row
+		}
+	}
+	for _, n := range nodes {
+		for _, target := range n.Warps {
+			if w, err := ParseWarp(n.Name, target); err == nil {
+				target = w.To
+			}
+			if _, ok := neighbours[target]; !ok || target == n.Name || neighbours[n.Name][target] {
+				continue
+			}
+			neighbours[n.Name][target], neighbours[target][n.Name] = true, true
+			stats.Warps++
+		}
+	}
+	if stats.Stars == 0 {
+		return stats
+	}
+
+	var names []string
+	for name := range neighbours {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	stats.MinWarps = len(nodes)
+	component := make(map[string]int)
+	for _, name := range names {
+		degree := len(neighbours[name])
+		if degree < stats.MinWarps {
+			stats.MinWarps = degree
+		}
+		if degree > stats.MaxWarps {
+			stats.MaxWarps = degree
+		}
+		if degree == 0 {
+			stats.Isolated++
+		}
+
+		// breadth first search for the distance to every star this one can reach
+		distance := map[string]int{name: 0}
+		queue := []string{name}
+		for len(queue) != 0 {
+			star := queue[0]
+			queue = queue[1:]
+			if distance[star] > stats.Diameter {
+				stats.Diameter = distance[star]
+			}
+			for next := range neighbours[star] {
+				if _, ok := distance[next]; !ok {
+					distance[next] = distance[star] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+		if _, ok := component[name]; !ok {
+			stats.Components++
+			for star := range distance {
+				component[star] = stats.Components
+			}
+		}
+	}
+	stats.MeanWarps = float64(2*stats.Warps) / float64(stats.Stars)
+	return stats
+}
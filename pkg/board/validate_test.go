@@ -0,0 +1,139 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateNodes(t *testing.T) {
+	if diagnostics := ValidateNodes(StandardNodes()); len(diagnostics) != 0 {
+		t.Errorf("standard: want no problems, got %v", diagnostics)
+	}
+	if diagnostics := ValidateNodes(nil); len(diagnostics) != 1 || diagnostics[0].Record != -1 {
+		t.Errorf("empty: want 1 problem with the map, got %v", diagnostics)
+	}
+
+	diagnostics := ValidateNodes([]Node{
+		{Name: "Ur", Col: 1, Row: 1, EconValue: 4, Warps: []string{"Ur", "Kish", "Susa", "Susa"}},
+		{Name: "Ur", Col: 2, Row: 2},
+		{Name: " ", Col: 1, Row: 1},
+		{Name: "Susa", Col: 0, Row: 41, EconValue: -1},
+		{Name: "Lost", Col: 9, Row: 9},
+	})
+	for _, want := range []Diagnostic{
+		{SeverityError, 0, 0, "warps", `warp leads back to "Ur"`},
+		{SeverityError, 0, 0, "warps", `warp to unknown star "Kish"`},
+		{SeverityWarning, 0, 0, "warps", `warp to "Susa" is listed more than once`},
+		{SeverityError, 1, 0, "name", `duplicate name "Ur", first used by record 1`},
+		{SeverityError, 2, 0, "name", "missing name"},
+		{SeverityError, 2, 0, "", `hex 0101 is taken by "Ur"`},
+		{SeverityError, 3, 0, "col", "col must be 1 to 40"},
+		{SeverityError, 3, 0, "row", "row must be 1 to 40"},
+		{SeverityError, 3, 0, "econ-value", "economic value must not be negative"},
+		{SeverityWarning, 4, 0, "warps", `"Lost" has no warps`},
+	} {
+		found := false
+		for _, d := range diagnostics {
+			found = found || d == want
+		}
+		if !found {
+			t.Errorf("want %+v in\n%+v", want, diagnostics)
+		}
+	}
+	if len(diagnostics) != 10 {
+		t.Errorf("want 10 problems, got %d", len(diagnostics))
+	}
+	if !HasErrors(diagnostics) || HasErrors(diagnostics[len(diagnostics)-1:]) {
+		t.Errorf("HasErrors: want errors only in the full list")
+	}
+	if got := diagnostics[0].Error(); got != `record 2: name: duplicate name "Ur", first used by record 1` {
+		t.Errorf("Error: got %q", got)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	nodes, diagnostics := ParseCSV(strings.NewReader(`# name, col, row, econ-value, warps
+Ur, 1, 1, 4, Adab, "Kish, the Great"
+
+Adab, 3, two, 1
+Kish, 5
+"Kish, the Great", 5, 3, 0,
+`))
+	if len(nodes) != 4 {
+		t.Fatalf("want 4 nodes, got %d", len(nodes))
+	}
+	if n := nodes[0]; n.Name != "Ur" || n.Col != 1 || n.Row != 1 || n.EconValue != 4 || len(n.Warps) != 2 || n.Warps[1] != "Kish, the Great" {
+		t.Errorf("Ur: got %+v", n)
+	}
+	if n := nodes[3]; n.Name != "Kish, the Great" || n.Col != 5 || len(n.Warps) != 0 {
+		t.Errorf("Kish: got %+v", n)
+	}
+	want := []Diagnostic{
+		{SeverityError, 1, 4, "row", `"two" is not a number`},
+		{SeverityError, 2, 5, "", "want at least 4 fields (name, col, row, econ-value), got 2"},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("want %+v, got %+v", want, diagnostics)
+	}
+	for i := range want {
+		if diagnostics[i] != want[i] {
+			t.Errorf("want %+v, got %+v", want[i], diagnostics[i])
+		}
+	}
+
+	if _, diagnostics = ParseCSV(strings.NewReader("Ur, 1, 1, 4\nAdab, 3, \"2\"x, 1\n")); len(diagnostics) != 1 || diagnostics[0].Line != 2 {
+		t.Errorf("bad quote: want a problem on line 2, got %+v", diagnostics)
+	}
+
+	// the hex can be one field in any notation
+	nodes, terrain, diagnostics := ParseMapCSV(strings.NewReader("Ur, 0101, 4, Adab\nAdab, C2, 1, Ur\nKish, \"5,3\", 0\nSusa, 1208\n@nebula, 0507\n@nebula, Q\n"))
+	if len(nodes) != 4 || nodes[0].Col != 1 || nodes[0].Row != 1 || nodes[0].EconValue != 4 || len(nodes[0].Warps) != 1 ||
+		nodes[1].Col != 3 || nodes[1].Row != 2 || nodes[2].Col != 5 || nodes[2].Row != 3 {
+		t.Errorf("hexes: got %+v", nodes)
+	}
+	if len(terrain) != 1 || terrain[0] != (Terrain{Feature: "nebula", Col: 5, Row: 7}) {
+		t.Errorf("hexes: terrain: got %+v", terrain)
+	}
+	if len(diagnostics) != 2 || diagnostics[0].Message != "want at least 3 fields (name, hex, econ-value), got 2" ||
+		diagnostics[1].Error() != `features: line 6: hex: coords: "Q" is not a hex` {
+		t.Errorf("hexes: got %v", diagnostics)
+	}
+}
+
+func TestNodeStats(t *testing.T) {
+	got := NodeStats([]Node{
+		{Name: "Ur", Col: 1, Row: 1, EconValue: 4, Warps: []string{"Adab"}},
+		{Name: "Adab", Col: 3, Row: 2, EconValue: 1, Warps: []string{"Kish", "Ur"}},
+		{Name: "Kish", Col: 5, Row: 3, EconValue: 0, Warps: []string{"Susa", "Nowhere"}},
+		{Name: "Susa", Col: 7, Row: 4, EconValue: 3},
+		{Name: "Lost", Col: 2, Row: 9, EconValue: 2},
+	})
+	want := Stats{Stars: 5, Warps: 3, EconValue: 10, Cols: 7, Rows: 9, MinWarps: 0, MaxWarps: 2, MeanWarps: 1.2, Isolated: 1, Components: 2, Diameter: 3}
+	if got != want {
+		t.Errorf("want %+v\ngot  %+v", want, got)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"sort"
+)
+
+// the viewer is self-contained: the page, style sheet and script are
+// compiled into the binary and written inline, so it works offline.
+var (
+	//go:embed viewer/viewer.html
+	viewerPage string
+	//go:embed viewer/viewer.css
+	viewerStyle string
+	//go:embed viewer/viewer.js
+	viewerScript string
+
+	viewerTemplate = template.Must(template.New("viewer").Parse(viewerPage))
+)
+
+// viewerStar is the data the viewer shows for each star.
+type viewerStar struct {
+	Name   string         `json:"name"`
+	Col    int            `json:"col"`
+	Row    int            `json:"row"`
+	Coords string         `json:"coords"`
+	Econ   int            `json:"econ"`
+	Warps  []string       `json:"warps"`
+	Jumps  map[string]int `json:"jumps,omitempty"` // warps that take more than one jump
+}
+
+// RenderViewer returns the board as an interactive HTML page.
+// The page lets the user pan and zoom the map, shows the details of a
+// star on hover, highlights the warp neighbours of the selected star
+// and the quickest routes from it, and toggles the hex coordinates.
+func (b *Board) RenderViewer(o RenderOptions, title string) ([]byte, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	s := b.asSVG(o)
+	s.interactive = true
+	svgBuf := &bytes.Buffer{}
+	if _, err := s.WriteTo(svgBuf); err != nil {
+		return nil, err
+	}
+
+	var stars []viewerStar
+	for name, hex := range b.Stars {
+		star := viewerStar{Name: name, Col: hex.Coords.Col, Row: hex.Coords.Row, Coords: hex.Coords.String(), Econ: hex.EconValue, Warps: b.neighbours(name)}
+		if star.Warps == nil {
+			star.Warps = []string{}
+		}
+		for _, to := range star.Warps {
+			if w, _ := b.Warp(name, to); w.Jumps() > 1 {
+				if star.Jumps == nil {
+					star.Jumps = make(map[string]int)
+				}
+				star.Jumps[to] = w.Jumps()
+			}
+		}
+		stars = append(stars, star)
+	}
+	sort.Slice(stars, func(i, j int) bool { return stars[i].Name < stars[j].Name })
+
+	buf := &bytes.Buffer{}
+	err := viewerTemplate.Execute(buf, struct {
+		Title  string
+		Style  template.CSS
+		Script template.JS
+		SVG    template.HTML // escaped by the svg writer
+		Data   interface{}
+	}{
+		Title:  title,
+		Style:  template.CSS(viewerStyle),
+		Script: template.JS(viewerScript),
+		SVG:    template.HTML(svgBuf.String()),
+		Data: struct {
+			Stars []viewerStar `json:"stars"`
+		}{Stars: stars},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,367 @@
+// viewer adds pan, zoom, hover details and route finding to the map.
+// The board data has the stars with their warps; routes are found here
+// when a star is selected, so the page stays small on big boards.
+(function () {
+    "use strict";
+
+    var data = JSON.parse(document.getElementById("board-data").textContent);
+    var container = document.getElementById("map");
+    var svg = container.querySelector("svg");
+    var panel = document.getElementById("panel");
+    var tooltip = document.getElementById("tooltip");
+
+    var stars = {};
+    data.stars.forEach(function (star) {
+        stars[star.name] = star;
+    });
+
+    // index the circles and warp lines by star name
+    var circles = {};
+    Array.prototype.forEach.call(svg.querySelectorAll("circle.star"), function (el) {
+        circles[el.getAttribute("data-star")] = el;
+    });
+    var warps = {};
+    Array.prototype.forEach.call(svg.querySelectorAll(".warp"), function (el) {
+        [el.getAttribute("data-from") + "\n" + el.getAttribute("data-to"),
+            el.getAttribute("data-to") + "\n" + el.getAttribute("data-from")].forEach(function (key) {
+            (warps[key] = warps[key] || []).push(el);
+        });
+    });
+
+    // let the svg fill the container; the view box does the scaling
+    var initial = svg.viewBox.baseVal;
+    var home = {x: initial.x, y: initial.y, width: initial.width, height: initial.height};
+    var view = {x: home.x, y: home.y, width: home.width, height: home.height};
+    svg.removeAttribute("width");
+    svg.removeAttribute("height");
+
+    function setView(v) {
+        view = v;
+        svg.setAttribute("viewBox", [v.x, v.y, v.width, v.height].join(" "));
+    }
+
+    // toSVG converts a point on the screen to the svg's coordinates
+    function toSVG(clientX, clientY) {
+        var pt = svg.createSVGPoint();
+        pt.x = clientX;
+        pt.y = clientY;
+        return pt.matrixTransform(svg.getScreenCTM().inverse());
+    }
+
+    // zoom scales the view, keeping the point at (cx, cy) in place
+    function zoom(factor, cx, cy) {
+        var width = Math.min(Math.max(view.width * factor, home.width / 20), home.width * 4);
+        factor = width / view.width;
+        setView({
+            x: cx - (cx - view.x) * factor,
+            y: cy - (cy - view.y) * factor,
+            width: width,
+            height: view.height * factor
+        });
+    }
+
+    function zoomCenter(factor) {
+        zoom(factor, view.x + view.width / 2, view.y + view.height / 2);
+    }
+
+    container.addEventListener("wheel", function (e) {
+        e.preventDefault();
+        var pt = toSVG(e.clientX, e.clientY);
+        zoom(e.deltaY < 0 ? 1 / 1.2 : 1.2, pt.x, pt.y);
+    }, {passive: false});
+
+    // dragging pans the map; a click that doesn't move is a selection
+    var drag = null;
+    container.addEventListener("pointerdown", function (e) {
+        if (e.button !== 0) {
+            return;
+        }
+        drag = {start: toSVG(e.clientX, e.clientY), clientX: e.clientX, clientY: e.clientY, moved: false};
+        container.setPointerCapture(e.pointerId);
+    });
+    container.addEventListener("pointermove", function (e) {
+        if (!drag) {
+            return;
+        }
+        if (Math.abs(e.clientX - drag.clientX) + Math.abs(e.clientY - drag.clientY) > 4) {
+            drag.moved = true;
+            container.classList.add("dragging");
+            hideTooltip();
+        }
+        if (drag.moved) {
+            var pt = toSVG(e.clientX, e.clientY);
+            setView({
+                x: view.x - (pt.x - drag.start.x),
+                y: view.y - (pt.y - drag.start.y),
+                width: view.width,
+                height: view.height
+            });
+        }
+    });
+    container.addEventListener("pointerup", function (e) {
+        if (!drag) {
+            return;
+        }
+        var moved = drag.moved;
+        drag = null;
+        container.classList.remove("dragging");
+        container.releasePointerCapture(e.pointerId);
+        if (!moved) {
+            var el = document.elementFromPoint(e.clientX, e.clientY);
+            var name = el && el.getAttribute("data-star");
+            if (name && stars[name]) {
+                clickStar(name);
+            } else {
+                select(null);
+            }
+        }
+    });
+
+    // hovering over a star shows its details
+    function hideTooltip() {
+        tooltip.hidden = true;
+    }
+
+    svg.addEventListener("mouseover", function (e) {
+        var name = e.target.getAttribute("data-star");
+        if (drag || !name || !stars[name]) {
+            return;
+        }
+        var star = stars[name];
+        tooltip.textContent = "";
+        var title = document.createElement("strong");
+        title.textContent = star.name + " (" + star.coords + ")";
+        tooltip.appendChild(title);
+        [
+            "Econ value: " + star.econ,
+            "Warps to: " + (star.warps.length ? star.warps.join(", ") : "none")
+        ].forEach(function (line) {
+            var div = document.createElement("div");
+            div.textContent = line;
+            tooltip.appendChild(div);
+        });
+        tooltip.hidden = false;
+    });
+    svg.addEventListener("mousemove", function (e) {
+        tooltip.style.left = (e.clientX + 14) + "px";
+        tooltip.style.top = (e.clientY + 14) + "px";
+    });
+    svg.addEventListener("mouseout", function (e) {
+        if (e.target.getAttribute("data-star")) {
+            hideTooltip();
+        }
+    });
+
+    // routes finds the quickest routes from a star with dijkstra's algorithm.
+    // It returns the star before each reachable star on its route.
+    // Stars the same number of jumps away are finished in the order they
+    // were reached, so that with warps of one jump it searches breadth first.
+    var cached = {from: null, prev: null};
+
+    function routes(from) {
+        if (cached.from === from) {
+            return cached.prev;
+        }
+        var total = {}, prev = {}, done = {}, open = [from];
+        total[from] = 0;
+        while (open.length) {
+            var best = 0;
+            for (var i = 1; i < open.length; i++) {
+                if (total[open[i]] < total[open[best]]) {
+                    best = i;
+                }
+            }
+            var name = open.splice(best, 1)[0];
+            done[name] = true;
+            stars[name].warps.forEach(function (to) {
+                var n = total[name] + ((stars[name].jumps || {})[to] || 1);
+                if (done[to] || (to in total && n >= total[to])) {
+                    return;
+                } else if (!(to in total)) {
+                    open.push(to);
+                }
+                total[to] = n;
+                prev[to] = name;
+            });
+        }
+        cached = {from: from, prev: prev};
+        return prev;
+    }
+
+    // route returns the stars on the quickest route, or null if there isn't one
+    function route(from, to) {
+        var prev = routes(from), path = [to];
+        while (to !== from) {
+            to = prev[to];
+            if (to === undefined) {
+                return null;
+            }
+            path.unshift(to);
+        }
+        return path;
+    }
+
+    // jumps returns the number of jumps it takes to follow a route
+    function jumps(path) {
+        var n = 0;
+        for (var i = 1; i < path.length; i++) {
+            n += (stars[path[i - 1]].jumps || {})[path[i]] || 1;
+        }
+        return n;
+    }
+
+    var selected = null, target = null;
+
+    function clearHighlights() {
+        Array.prototype.forEach.call(svg.querySelectorAll(".selected, .neighbour, .on-route"), function (el) {
+            el.classList.remove("selected", "neighbour", "on-route");
+        });
+    }
+
+    function highlight(from, to, className) {
+        (warps[from + "\n" + to] || []).forEach(function (el) {
+            el.classList.add(className);
+        });
+    }
+
+    function clickStar(name) {
+        if (selected === null || name === selected) {
+            select(name === selected ? null : name);
+        } else {
+            showRoute(name === target ? null : name);
+        }
+    }
+
+    // select highlights a star and its warp neighbours, and lists its routes
+    function select(name) {
+        selected = name;
+        target = null;
+        clearHighlights();
+        panel.textContent = "";
+        if (name === null) {
+            var p = document.createElement("p");
+            p.className = "hint";
+            p.textContent = "No star selected.";
+            panel.appendChild(p);
+            return;
+        }
+        var star = stars[name];
+        circles[name].classList.add("selected");
+        star.warps.forEach(function (neighbour) {
+            circles[neighbour].classList.add("neighbour");
+            highlight(name, neighbour, "neighbour");
+        });
+
+        var h2 = document.createElement("h2");
+        h2.textContent = star.name + " (" + star.coords + ")";
+        panel.appendChild(h2);
+        var info = document.createElement("p");
+        info.textContent = "Econ value " + star.econ + ", " + star.warps.length + " warp" + (star.warps.length === 1 ? "" : "s") + ".";
+        panel.appendChild(info);
+
+        // list the other stars by the number of jumps needed to reach them
+        var reachable = [], unreachable = [];
+        data.stars.forEach(function (other) {
+            if (other.name === name) {
+                return;
+            }
+            var path = route(name, other.name);
+            if (path) {
+                reachable.push({name: other.name, jumps: jumps(path)});
+            } else {
+                unreachable.push(other.name);
+            }
+        });
+        reachable.sort(function (a, b) {
+            return a.jumps - b.jumps || (a.name < b.name ? -1 : a.name > b.name ? 1 : 0);
+        });
+        var h3 = document.createElement("h2");
+        h3.textContent = "Routes";
+        panel.appendChild(h3);
+        var list = document.createElement("ol");
+        list.id = "routes";
+        reachable.forEach(function (r) {
+            var li = document.createElement("li");
+            li.setAttribute("data-target", r.name);
+            li.textContent = r.name + " — " + r.jumps + " jump" + (r.jumps === 1 ? "" : "s");
+            li.addEventListener("click", function () {
+                showRoute(r.name === target ? null : r.name);
+            });
+            list.appendChild(li);
+        });
+        panel.appendChild(list);
+        if (unreachable.length) {
+            var none = document.createElement("p");
+            none.className = "hint";
+            none.textContent = "No route to " + unreachable.join(", ") + ".";
+            panel.appendChild(none);
+        }
+    }
+
+    // showRoute highlights the shortest route from the selected star
+    function showRoute(name) {
+        var from = selected;
+        select(from);
+        target = name;
+        Array.prototype.forEach.call(panel.querySelectorAll("li"), function (li) {
+            li.classList.toggle("active", li.getAttribute("data-target") === name);
+        });
+        if (name === null) {
+            return;
+        }
+        var path = route(from, name);
+        if (!path) {
+            return;
+        }
+        clearHighlights();
+        circles[from].classList.add("selected");
+        path.forEach(function (star, i) {
+            if (i > 0) {
+                circles[star].classList.add("on-route");
+                highlight(path[i - 1], star, "on-route");
+            }
+        });
+    }
+
+    // toolbar and keyboard controls
+    var showCoords = document.getElementById("show-coords");
+
+    function toggleCoords() {
+        svg.classList.toggle("hide-coords", !showCoords.checked);
+    }
+
+    showCoords.addEventListener("change", toggleCoords);
+    document.getElementById("zoom-in").addEventListener("click", function () {
+        zoomCenter(1 / 1.2);
+    });
+    document.getElementById("zoom-out").addEventListener("click", function () {
+        zoomCenter(1.2);
+    });
+    document.getElementById("zoom-reset").addEventListener("click", function () {
+        setView(home);
+    });
+    document.addEventListener("keydown", function (e) {
+        if (e.target.tagName === "INPUT" || e.ctrlKey || e.metaKey || e.altKey) {
+            return;
+        }
+        switch (e.key) {
+            case "+":
+            case "=":
+                zoomCenter(1 / 1.2);
+                break;
+            case "-":
+                zoomCenter(1.2);
+                break;
+            case "0":
+                setView(home);
+                break;
+            case "c":
+                showCoords.checked = !showCoords.checked;
+                toggleCoords();
+                break;
+            case "Escape":
+                select(null);
+                break;
+        }
+    });
+})();
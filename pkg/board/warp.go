@@ -0,0 +1,201 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Warp is a warp line between two stars. The warps that AddWormHole adds
+// go both ways, take one jump to travel and have no label; AddWarp adds
+// warps that only go one way, take longer or have a label on the map.
+type Warp struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	OneWay bool   `json:"one-way,omitempty"` // can only be travelled from From to To
+	Cost   int    `json:"cost,omitempty"`    // jumps to travel it; 0 is the same as 1
+	Label  string `json:"label,omitempty"`   // shown on the map, like "long warp: 2 turns"
+}
+
+// Jumps returns the number of jumps it takes to travel the warp.
+func (w Warp) Jumps() int {
+	if w.Cost < 1 {
+		return 1
+	}
+	return w.Cost
+}
+
+// ParseWarp reads a warp from a star to a target in the map data. The
+// target is the name of the star, with ">" in front for a one-way warp.
+// It can be followed by ";" and the cost, and then ";" and the label,
+// like ">Kish;2;long warp". Star names can't contain ";".
+func ParseWarp(from, target string) (Warp, error) {
+	w := Warp{From: from}
+	fields := strings.SplitN(target, ";", 3)
+	w.To = strings.TrimSpace(fields[0])
+	if strings.HasPrefix(w.To, ">") {
+		w.OneWay, w.To = true, strings.TrimSpace(strings.TrimPrefix(w.To, ">"))
+	}
+	if w.To == "" {
+		return w, fmt.Errorf("board: warp %q from %q: missing star", target, from)
+	}
+	if len(fields) > 1 {
+		if cost := strings.TrimSpace(fields[1]); cost != "" {
+			var err error
+			if w.Cost, err = strconv.Atoi(cost); err != nil || w.Cost < 1 {
+				return w, fmt.Errorf("board: warp %q from %q: cost must be a positive number", target, from)
+			}
+		}
+	}
+	if len(fields) > 2 {
+		w.Label = strings.TrimSpace(fields[2])
+	}
+	return w, nil
+}
+
+// Target returns the warp as ParseWarp reads it from the map data of
+// the star it starts from.
+func (w Warp) Target() string {
+	s := w.To
+	if w.OneWay {
+		s = ">" + s
+	}
+	if w.Cost > 1 || w.Label != "" {
+		s += ";" + strconv.Itoa(w.Jumps())
+	}
+	if w.Label != "" {
+		s += ";" + w.Label
+	}
+	return s
+}
+
+// warpKey finds a warp on the board. Warps that go both ways are kept
+// under the names of their stars in sorted order.
+type warpKey struct {
+	from, to string
+	oneWay   bool
+}
+
+func (w Warp) key() warpKey {
+	if !w.OneWay && w.To < w.From {
+		return warpKey{from: w.To, to: w.From}
+	}
+	return warpKey{from: w.From, to: w.To, oneWay: w.OneWay}
+}
+
+// AddWarp adds a warp to the board. A warp that goes both ways can be
+// added from either end, and adding it again only changes the cost or
+// label if they are given. A one-way warp between stars that already
+// have a warp both ways updates that warp instead, and a warp both ways
+// replaces any one-way warps between its stars.
+func (b *Board) AddWarp(w Warp) error {
+	from, ok := b.Stars[w.From]
+	if !ok {
+		return fmt.Errorf("board: invalid source star: %q", w.From)
+	}
+	to, ok := b.Stars[w.To]
+	if !ok {
+		return fmt.Errorf("board: invalid target star: %q", w.To)
+	}
+	if b.warps == nil {
+		b.warps = make(map[warpKey]Warp)
+	}
+	if w.OneWay {
+		both := Warp{From: w.From, To: w.To}
+		if _, ok := b.warps[both.key()]; ok {
+			w.OneWay = false
+		}
+	} else {
+		delete(b.warps, warpKey{from: w.From, to: w.To, oneWay: true})
+		delete(b.warps, warpKey{from: w.To, to: w.From, oneWay: true})
+	}
+	key := w.key()
+	if old, ok := b.warps[key]; ok {
+		if w.Cost == 0 {
+			w.Cost = old.Cost
+		}
+		if w.Label == "" {
+			w.Label = old.Label
+		}
+		w.From, w.To = old.From, old.To
+	}
+	b.warps[key] = w
+
+	from.AddWormHole(to)
+	if !w.OneWay {
+		to.AddWormHole(from)
+	}
+	return nil
+}
+
+// Warp returns the warp that leads from one star to another, if there is one.
+func (b *Board) Warp(from, to string) (Warp, bool) {
+	w, ok := b.warps[Warp{From: from, To: to}.key()]
+	if !ok {
+		w, ok = b.warps[warpKey{from: from, to: to, oneWay: true}]
+	}
+	w.From, w.To = from, to
+	return w, ok
+}
+
+// targets returns the warps that start at a star, as they are written
+// in the map data, sorted by the name of the star they lead to.
+func (b *Board) targets(name string) []string {
+	var targets []string
+	for _, to := range b.neighbours(name) {
+		w, ok := b.Warp(name, to)
+		if !ok {
+			// added with Hex.AddWormHole, so it goes one way unless both ends were
+			w.OneWay = true
+			for _, h := range b.Stars[to].WormHoleExits {
+				w.OneWay = w.OneWay && h.Name != name
+			}
+		}
+		targets = append(targets, w.Target())
+	}
+	return targets
+}
+
+// Warps returns every warp on the board once, sorted by the names of
+// their stars. Warps that go both ways start at the star that sorts first.
+func (b *Board) Warps() []Warp {
+	var list []Warp
+	for key, w := range b.warps {
+		w.From, w.To = key.from, key.to
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].From != list[j].From {
+			return list[i].From < list[j].From
+		} else if list[i].To != list[j].To {
+			return list[i].To < list[j].To
+		}
+		return !list[i].OneWay && list[j].OneWay
+	})
+	return list
+}
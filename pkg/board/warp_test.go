@@ -0,0 +1,218 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWarp(t *testing.T) {
+	for _, tc := range []struct {
+		target, canonical string
+		want              Warp
+	}{
+		{"Kish", "Kish", Warp{From: "Ur", To: "Kish"}},
+		{" > Kish ", ">Kish", Warp{From: "Ur", To: "Kish", OneWay: true}},
+		{">Kish;2;long warp: 2 turns", ">Kish;2;long warp: 2 turns", Warp{From: "Ur", To: "Kish", OneWay: true, Cost: 2, Label: "long warp: 2 turns"}},
+		{"Kish;;scenic; slow", "Kish;1;scenic; slow", Warp{From: "Ur", To: "Kish", Label: "scenic; slow"}},
+		{"Kish;1", "Kish", Warp{From: "Ur", To: "Kish", Cost: 1}},
+	} {
+		got, err := ParseWarp("Ur", tc.target)
+		if err != nil {
+			t.Errorf("%q: %v", tc.target, err)
+			continue
+		} else if got != tc.want {
+			t.Errorf("%q: want %+v, got %+v", tc.target, tc.want, got)
+		}
+		if s := got.Target(); s != tc.canonical {
+			t.Errorf("%q: target: want %q, got %q", tc.target, tc.canonical, s)
+		}
+	}
+	for _, target := range []string{"", ">", "Kish;0", "Kish;two"} {
+		if _, err := ParseWarp("Ur", target); err == nil {
+			t.Errorf("%q: want an error, got nil", target)
+		}
+	}
+}
+
+// warpNodes is a map with a long warp and a one-way loop:
+// Ur - Adab costs 3, Ur -> Kish -> Adab -> Ur.
+func warpNodes() []Node {
+	return []Node{
+		{Name: "Ur", Col: 1, Row: 1, Warps: []string{"Adab;3;long warp", ">Kish"}},
+		{Name: "Adab", Col: 5, Row: 1, Warps: []string{"Ur"}},
+		{Name: "Kish", Col: 3, Row: 4, Warps: []string{">Adab"}},
+	}
+}
+
+func TestAddWarp(t *testing.T) {
+	b, err := FromNodes(warpNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Warp{
+		{From: "Adab", To: "Ur", Cost: 3, Label: "long warp"},
+		{From: "Kish", To: "Adab", OneWay: true},
+		{From: "Ur", To: "Kish", OneWay: true},
+	}
+	if got := b.Warps(); !reflect.DeepEqual(got, want) {
+		t.Errorf("warps: want %+v, got %+v", want, got)
+	}
+	if w, ok := b.Warp("Ur", "Adab"); !ok || w.From != "Ur" || w.Jumps() != 3 {
+		t.Errorf("Ur to Adab: got %+v, %v", w, ok)
+	}
+	if _, ok := b.Warp("Kish", "Ur"); ok {
+		t.Errorf("Kish to Ur: want no warp")
+	}
+
+	// a warp both ways replaces a one-way warp, and keeps its cost
+	if err := b.AddWarp(Warp{From: "Kish", To: "Ur", Cost: 2}); err != nil {
+		t.Fatal(err)
+	} else if err := b.AddWormHole("Ur", "Kish"); err != nil {
+		t.Fatal(err)
+	}
+	if w, ok := b.Warp("Ur", "Kish"); !ok || w.OneWay || w.Cost != 2 {
+		t.Errorf("Ur to Kish: want both ways at 2, got %+v", w)
+	} else if len(b.Warps()) != 3 {
+		t.Errorf("want 3 warps, got %+v", b.Warps())
+	}
+	if err := b.AddWarp(Warp{From: "Ur", To: "Atlantis"}); err == nil {
+		t.Errorf("Ur to Atlantis: want an error, got nil")
+	}
+}
+
+func TestRouteWarps(t *testing.T) {
+	b, err := FromNodes(warpNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		from, to string
+		want     []string
+		jumps    int
+	}{
+		{"Ur", "Adab", []string{"Ur", "Kish", "Adab"}, 2},
+		{"Adab", "Ur", []string{"Adab", "Ur"}, 3},
+		{"Kish", "Ur", []string{"Kish", "Adab", "Ur"}, 4},
+	} {
+		got, err := b.Route(tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%s to %s: %v", tc.from, tc.to, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s to %s: want %v, got %v", tc.from, tc.to, tc.want, got)
+		} else if jumps := b.RouteCost(got); jumps != tc.jumps {
+			t.Errorf("%s to %s: want %d jumps, got %d", tc.from, tc.to, tc.jumps, jumps)
+		}
+	}
+	if next := b.NextHops(); next["Kish"]["Ur"] != "Adab" || next["Ur"]["Adab"] != "Kish" {
+		t.Errorf("next hops: got %v", next)
+	}
+}
+
+func TestWarpMapData(t *testing.T) {
+	b, err := FromNodes(warpNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Adab;3;long warp", ">Kish"}
+	if nodes := b.Nodes(); !reflect.DeepEqual(nodes[0].Warps, []string{"Ur;3;long warp"}) || !reflect.DeepEqual(nodes[2].Warps, want) {
+		t.Errorf("nodes: got %+v", b.Nodes())
+	}
+
+	buf := &bytes.Buffer{}
+	if err := b.WriteCSV(buf); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), "Ur, 1, 1, 0, Adab;3;long warp, >Kish\n") {
+		t.Errorf("csv: got\n%s", buf.String())
+	}
+	nodes, diagnostics := ParseCSV(buf)
+	if len(diagnostics) != 0 {
+		t.Fatal(diagnostics)
+	} else if got, err := FromNodes(nodes); err != nil {
+		t.Fatal(err)
+	} else if !sameBoard(b, got) || !reflect.DeepEqual(got.Warps(), b.Warps()) {
+		t.Errorf("csv: round trip changed the board")
+	}
+
+	buf.Reset()
+	if err := b.WriteDOT(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`"Adab" -- "Ur" [cost=3, style=dashed, label="long warp"];`,
+		`"Kish" -- "Adab" [dir=forward];`,
+		`"Ur" -- "Kish" [dir=forward];`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("dot: want %s, got\n%s", line, buf.String())
+		}
+	}
+
+	nodes = warpNodes()
+	nodes[0].Warps = append(nodes[0].Warps, "Kish;0")
+	if diagnostics := ValidateNodes(nodes); len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "cost") {
+		t.Errorf("validate: want a bad cost, got %v", diagnostics)
+	} else if _, err := FromNodes(nodes); err == nil {
+		t.Errorf("FromNodes: want a bad cost, got nil")
+	}
+}
+
+func TestRenderWarps(t *testing.T) {
+	b, err := FromNodes(warpNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := DefaultRenderOptions(false)
+	s := b.asSVG(o)
+	s.interactive = true
+	doc := s.String()
+	if n := strings.Count(doc, `class="warp-head"`); n != 2 {
+		t.Errorf("want 2 arrowheads, got %d", n)
+	}
+	if n := strings.Count(doc, "stroke-dasharray"); n != 2 {
+		t.Errorf("want the long warp dashed from both ends, got %d dashed lines", n)
+	}
+	if n := strings.Count(doc, ">long warp<"); n != 1 {
+		t.Errorf("want the label once, got %d", n)
+	}
+
+	// a one-way warp across the edge has its arrow on the half at its target
+	b, err = FromNodes([]Node{
+		{Name: "Ur", Col: 1, Row: 3, Warps: []string{">Adab"}},
+		{Name: "Adab", Col: 8, Row: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if err := b.SetWrap(WrapEastWest, Geometry{}); err != nil {
+		t.Fatal(err)
+	}
+	s = b.asSVG(o)
+	if len(s.lines) != 2 || len(s.lines[0].head) != 0 || len(s.lines[1].head) != 3 || s.lines[1].from != "Ur" {
+		t.Errorf("wrapped: got %+v", s.lines)
+	}
+}
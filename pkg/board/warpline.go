@@ -0,0 +1,383 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"github.com/mdhender/wow/pkg/hexes"
+	"math"
+)
+
+// warpLine is a warp line laid out on the svg. A line that would pass
+// through a star it doesn't lead to is drawn as a quadratic curve with
+// its control point at qx, qy.
+type warpLine struct {
+	x1, y1, x2, y2 float64
+	curved         bool
+	qx, qy         float64
+	wrapped        bool    // the line stops at the edge, halfway to the other star
+	from, to       string  // names of the stars at the ends of the line
+	oneWay         bool    // the warp only goes from the star at from to the one at to
+	into           bool    // a one-way warp that comes into the star at x1, y1 across the edge
+	dashed         bool    // the warp takes more than one jump
+	label          string  // the label of the warp, set on only one of its lines
+	lx, ly         float64 // where the label is drawn
+	head           []point // the arrowhead of a one-way warp
+}
+
+// curveSteps is the number of straight pieces used to check a curve.
+const curveSteps = 16
+
+// at returns the point on the line at t, from 0 at the start to 1 at the end.
+func (l warpLine) at(t float64) point {
+	if !l.curved {
+		return point{x: l.x1 + (l.x2-l.x1)*t, y: l.y1 + (l.y2-l.y1)*t}
+	}
+	u := 1 - t
+	return point{x: u*u*l.x1 + 2*u*t*l.qx + t*t*l.x2, y: u*u*l.y1 + 2*u*t*l.qy + t*t*l.y2}
+}
+
+// points returns the line as a list of points joined by straight pieces.
+func (l warpLine) points() []point {
+	steps := 1
+	if l.curved {
+		steps = curveSteps
+	}
+	list := make([]point, 0, steps+1)
+	for i := 0; i <= steps; i++ {
+		list = append(list, l.at(float64(i)/float64(steps)))
+	}
+	return list
+}
+
+// visible returns the points of the line, leaving out the parts under the
+// circles of the stars at its ends, which are drawn over it.
+func (l warpLine) visible(starRadius float64) []point {
+	start, end := point{x: l.x1, y: l.y1}, point{x: l.x2, y: l.y2}
+	if !l.curved {
+		length := math.Hypot(l.x2-l.x1, l.y2-l.y1)
+		t0, t1 := starRadius/length, 1-starRadius/length
+		if l.wrapped {
+			t1 = 1
+		}
+		if length == 0 || t0 >= t1 {
+			return nil
+		}
+		return []point{l.at(t0), l.at(t1)}
+	}
+	var list []point
+	for _, p := range l.points() {
+		if distance(p, start) > starRadius && (l.wrapped || distance(p, end) > starRadius) {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// arrow returns the arrowhead of a one-way warp, with its tip on the
+// circle of the star the warp leads to. Warps that cross the edge of a
+// board that wraps have it on the half that comes into the star.
+func (l warpLine) arrow(starRadius, size float64) []point {
+	if !l.oneWay || (l.wrapped && !l.into) {
+		return nil
+	}
+	// the tip is found by bisection, since the line may be curved
+	end, lo, hi := point{x: l.x2, y: l.y2}, 0.0, 1.0
+	if l.into {
+		end = point{x: l.x1, y: l.y1}
+	}
+	for i := 0; i < 32; i++ {
+		t := (lo + hi) / 2
+		if (distance(l.at(t), end) > starRadius) != l.into {
+			lo = t
+		} else {
+			hi = t
+		}
+	}
+	t, back := (lo+hi)/2, 0.01
+	if l.into {
+		back = -back
+	}
+	tip, tail := l.at(t), l.at(t-back)
+	length := distance(tip, tail)
+	if length == 0 {
+		return nil
+	}
+	dx, dy := (tip.x-tail.x)/length, (tip.y-tail.y)/length
+	bx, by := tip.x-dx*size, tip.y-dy*size
+	return []point{tip, {x: bx - dy*size/2, y: by + dx*size/2}, {x: bx + dy*size/2, y: by - dx*size/2}}
+}
+
+// placeWarpLabels places the labels of the warps next to the middle of
+// their lines: above or below a line that is more flat than steep, and to
+// its right or left otherwise. The first place that is clear of the stars
+// is kept, or the first place if neither is.
+//
+// It returns the bottom right corner of the labels.
+func placeWarpLabels(lines []warpLine, stars []*polygon, starRadius, fontSize float64) (maxX, maxY float64) {
+	for i := range lines {
+		l := &lines[i]
+		if l.label == "" {
+			continue
+		}
+		// the width is estimated from the number of characters, as for stubs
+		half, m := float64(len(l.label))*fontSize*0.35, l.at(0.5)
+		spots := []point{{x: m.x, y: m.y - fontSize*0.4}, {x: m.x, y: m.y + fontSize*1.1}}
+		if math.Abs(l.x2-l.x1) < math.Abs(l.y2-l.y1) {
+			spots = []point{{x: m.x + half + fontSize*0.3, y: m.y + fontSize*0.3}, {x: m.x - half - fontSize*0.3, y: m.y + fontSize*0.3}}
+		}
+		l.lx, l.ly = spots[0].x, spots[0].y
+	spot:
+		for _, c := range spots {
+			for _, p := range stars {
+				if circleHitsBox(point{x: p.cx, y: p.cy}, starRadius, c.x-half, c.y-fontSize*0.8, c.x+half, c.y+fontSize*0.2) {
+					continue spot
+				}
+			}
+			l.lx, l.ly = c.x, c.y
+			break
+		}
+		maxX, maxY = math.Max(maxX, l.lx+half), math.Max(maxY, l.ly+fontSize*0.2)
+	}
+	return maxX, maxY
+}
+
+// starCircle is the circle drawn for a star.
+type starCircle struct {
+	name string
+	c    point
+}
+
+// warpLines lays out the warp lines of the board. Each star draws a line to
+// every star it has a warp to, so each warp that goes both ways is drawn
+// twice, once from each end. The two lines always match. A one-way warp is
+// drawn once, by the star it starts from, and its label is on one line only.
+//
+// On a board that wraps, the line heads for the nearest copy of the other
+// star; if that is across an edge, each star draws the half of the line on
+// its side, even the star a one-way warp leads to. Lines that would pass
+// through another star are curved around it if they can be.
+func (b *Board) warpLines(layout hexes.Layout, toCube func(col, row int) hexes.Hex, starRadius, clearance float64) []warpLine {
+	var stars []starCircle
+	var lines []warpLine
+	incoming := b.incoming()
+	for row := 0; row < b.Rows; row++ {
+		for col := 0; col < b.Cols; col++ {
+			hex := b.Hexes[row][col]
+			if hex.Name == "" {
+				continue // not a star
+			}
+			cx, cy := layout.CenterPoint(toCube(col, row)).Coords()
+			stars = append(stars, starCircle{name: hex.Name, c: point{x: cx, y: cy}})
+			// towards returns the line from this star towards another
+			towards := func(star *Hex) warpLine {
+				var sx, sy float64
+				var wrapped bool
+				for i, image := range b.images(star.Coords) {
+					x, y := layout.CenterPoint(toCube(image.Col, image.Row)).Coords()
+					if i == 0 || math.Hypot(x-cx, y-cy) < math.Hypot(sx-cx, sy-cy) {
+						sx, sy, wrapped = x, y, i != 0
+					}
+				}
+				if wrapped {
+					sx, sy = (cx+sx)/2, (cy+sy)/2
+				}
+				return warpLine{x1: cx, y1: cy, x2: sx, y2: sy, wrapped: wrapped}
+			}
+			for _, star := range hex.WormHoleExits {
+				w, ok := b.Warp(hex.Name, star.Name)
+				l := towards(star)
+				l.from, l.to, l.dashed = hex.Name, star.Name, w.Jumps() > 1
+				l.oneWay = w.OneWay || (!ok && !hasExit(star, hex.Name))
+				if l.oneWay || l.from < l.to {
+					l.label = w.Label
+				}
+				lines = append(lines, l)
+			}
+			// the half of a one-way warp that comes across the edge
+			for _, name := range incoming[hex.Name] {
+				w, ok := b.Warp(name, hex.Name)
+				if !w.OneWay && (ok || hasExit(hex, name)) {
+					continue // this star draws its half of the warp itself
+				} else if l := towards(b.Stars[name]); l.wrapped {
+					l.from, l.to, l.oneWay, l.into, l.dashed = name, hex.Name, true, true, w.Jumps() > 1
+					lines = append(lines, l)
+				}
+			}
+		}
+	}
+	for i := range lines {
+		if !lines[i].wrapped {
+			lines[i].route(stars, starRadius, clearance)
+		}
+	}
+	return lines
+}
+
+// hasExit reports whether a star has a warp to the named star.
+func hasExit(h *Hex, name string) bool {
+	for _, exit := range h.WormHoleExits {
+		if exit.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// offDrawing reports whether the line goes off the top or left of the drawing.
+func (l warpLine) offDrawing() bool {
+	for _, p := range l.points() {
+		if p.x < 0 || p.y < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hits returns the number of stars, other than the ones at its ends,
+// that the line passes within the clearance of.
+func (l warpLine) hits(stars []starCircle, clearance float64) int {
+	pts, n := l.points(), 0
+	for _, s := range stars {
+		if s.name == l.from || s.name == l.to {
+			continue
+		}
+		for i := 1; i < len(pts); i++ {
+			if distanceToSegment(s.c, pts[i-1], pts[i]) < clearance {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// route bends a line that passes through a star into a gentle curve that
+// misses it. The curves are tried from the gentlest, bowing out by one star
+// radius, up to four, first on one side and then the other; the first that
+// misses every star is kept. If none do, the line takes the curve that hits
+// the fewest stars, or stays straight if none of them help. Curves that
+// would bow off the top or left of the drawing aren't tried.
+//
+// The side is chosen from the names of the stars, not the direction of
+// the line, so the lines drawn from either end of a warp are the same.
+func (l *warpLine) route(stars []starCircle, starRadius, clearance float64) {
+	best := l.hits(stars, clearance)
+	if best == 0 {
+		return
+	}
+	dx, dy := l.x2-l.x1, l.y2-l.y1
+	length := math.Hypot(dx, dy)
+	nx, ny := -dy/length, dx/length
+	if l.from > l.to {
+		nx, ny = -nx, -ny
+	}
+	mx, my := (l.x1+l.x2)/2, (l.y1+l.y2)/2
+	straight := *l
+	for bow := 1; bow <= 4; bow++ {
+		for _, side := range []float64{1, -1} {
+			// the middle of a quadratic curve is halfway to its control point
+			d := 2 * float64(bow) * starRadius * side
+			c := straight
+			c.curved, c.qx, c.qy = true, mx+nx*d, my+ny*d
+			if c.offDrawing() {
+				continue
+			}
+			if n := c.hits(stars, clearance); n < best {
+				*l, best = c, n
+				if n == 0 {
+					return
+				}
+			}
+		}
+	}
+}
+
+// placeLabels moves the names of the stars off the warp lines. A name is
+// drawn in the top half of its star's circle unless it overlaps a line
+// outside the circle, which is drawn over the lines;
+// then it is tried above the circle, below it, to its right and to its
+// left, keeping the first place that is clear of the lines and the other
+// stars. A name that overlaps a line everywhere stays in the circle.
+//
+// It returns the bottom right corner of the names that were moved,
+// which may be outside the board.
+func placeLabels(stars []*polygon, lines []warpLine, starRadius, fontSize, warpWidth float64) (maxX, maxY float64) {
+	var visible [][]point
+	for _, l := range lines {
+		if pts := l.visible(starRadius); len(pts) > 1 {
+			visible = append(visible, pts)
+		}
+	}
+	for _, p := range stars {
+		p.lx, p.ly = p.cx, p.cy-fontSize*0.6
+		if len(p.text) == 0 {
+			continue
+		}
+		// the width is estimated from the number of characters, as for stubs
+		half := float64(len(p.text[0]))*fontSize*0.35 + warpWidth/2
+		centre := point{x: p.cx, y: p.cy}
+		box := func(x, y float64) (minX, minY, maxX, maxY float64) {
+			return x - half, y - fontSize*0.8 - warpWidth/2, x + half, y + fontSize*0.2 + warpWidth/2
+		}
+		clear := func(x, y float64, outside bool) bool {
+			minX, minY, maxX, maxY := box(x, y)
+			if minX < 0 || minY < 0 {
+				return false
+			}
+			for _, pts := range visible {
+				for i := 1; i < len(pts); i++ {
+					a, b, ok := clipToBox(pts[i-1], pts[i], minX, minY, maxX, maxY)
+					// lines are drawn under the circles of the stars
+					if ok && (distance(a, centre) >= starRadius || distance(b, centre) >= starRadius) {
+						return false
+					}
+				}
+			}
+			for _, q := range stars {
+				if outside && q != p && circleHitsBox(point{x: q.cx, y: q.cy}, starRadius, minX, minY, maxX, maxY) {
+					return false
+				}
+			}
+			return true
+		}
+		if clear(p.lx, p.ly, false) {
+			continue
+		}
+		for _, c := range []point{
+			{x: p.cx, y: p.cy - starRadius - fontSize*0.3},
+			{x: p.cx, y: p.cy + starRadius + fontSize},
+			{x: p.cx + starRadius + half + fontSize*0.3, y: p.cy + fontSize*0.3},
+			{x: p.cx - starRadius - half - fontSize*0.3, y: p.cy + fontSize*0.3},
+		} {
+			if clear(c.x, c.y, true) {
+				p.lx, p.ly = c.x, c.y
+				_, _, x, y := box(c.x, c.y)
+				maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+				break
+			}
+		}
+	}
+	return maxX, maxY
+}
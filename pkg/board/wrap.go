@@ -0,0 +1,163 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wrap says which edges of the board wrap around to the opposite edge.
+// A board that wraps east to west is a cylinder; one that wraps both ways
+// is a torus. The hexes that wrap are the ones inside the border that
+// NewBoard adds, so a board of 20 columns wraps from column 20 to column 1.
+type Wrap int
+
+const (
+	WrapNone       Wrap = 0
+	WrapEastWest   Wrap = 1
+	WrapNorthSouth Wrap = 2
+	WrapBoth            = WrapEastWest | WrapNorthSouth
+)
+
+// String returns the name of the wrap.
+func (w Wrap) String() string {
+	switch w {
+	case WrapNone:
+		return "none"
+	case WrapEastWest:
+		return "east-west"
+	case WrapNorthSouth:
+		return "north-south"
+	case WrapBoth:
+		return "both"
+	}
+	return fmt.Sprintf("Wrap(%d)", int(w))
+}
+
+// ParseWrap returns the wrap with the given name. "cylinder" is
+// another name for east-west, and "torus" for both.
+func ParseWrap(name string) (Wrap, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return WrapNone, nil
+	case "east-west", "cylinder":
+		return WrapEastWest, nil
+	case "north-south":
+		return WrapNorthSouth, nil
+	case "both", "torus":
+		return WrapBoth, nil
+	}
+	return WrapNone, fmt.Errorf("board: wrap must be none, east-west, north-south or both")
+}
+
+// MarshalText returns the name of the wrap.
+func (w Wrap) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText reads the name of a wrap.
+func (w *Wrap) UnmarshalText(text []byte) error {
+	wrap, err := ParseWrap(string(text))
+	if err != nil {
+		return err
+	}
+	*w = wrap
+	return nil
+}
+
+// SetWrap makes the edges of the board wrap around. The offset rows
+// or columns alternate, so an edge can only wrap if the hexes on either
+// side of the seam line up: flat hexes need an even number of columns
+// to wrap east to west, and pointy hexes an even number of rows to wrap
+// north to south.
+func (b *Board) SetWrap(w Wrap, g Geometry) error {
+	cols, rows := b.Cols-2, b.Rows-2
+	pointy := strings.ToLower(g.Orientation) == "pointy"
+	if w&WrapEastWest != 0 && (cols < 2 || (!pointy && cols%2 != 0)) {
+		return fmt.Errorf("board: a board of flat hexes must have an even number of columns to wrap east to west")
+	} else if w&WrapNorthSouth != 0 && (rows < 2 || (pointy && rows%2 != 0)) {
+		return fmt.Errorf("board: a board of pointy hexes must have an even number of rows to wrap north to south")
+	}
+	b.Wrap = w
+	return nil
+}
+
+// wrap moves a hex that is past a wrapped edge back onto the board.
+func (b *Board) wrap(c Coords) Coords {
+	if cols := b.Cols - 2; b.Wrap&WrapEastWest != 0 && cols > 0 {
+		c.Col = ((c.Col-1)%cols+cols)%cols + 1
+	}
+	if rows := b.Rows - 2; b.Wrap&WrapNorthSouth != 0 && rows > 0 {
+		c.Row = ((c.Row-1)%rows+rows)%rows + 1
+	}
+	return c
+}
+
+// images returns the copies of a hex that lie just past the wrapped
+// edges, as though the board were repeated, starting with the hex itself.
+func (b *Board) images(c Coords) []Coords {
+	dcols, drows := []int{0}, []int{0}
+	if b.Wrap&WrapEastWest != 0 {
+		dcols = append(dcols, -(b.Cols - 2), b.Cols-2)
+	}
+	if b.Wrap&WrapNorthSouth != 0 {
+		drows = append(drows, -(b.Rows - 2), b.Rows-2)
+	}
+	var list []Coords
+	for _, drow := range drows {
+		for _, dcol := range dcols {
+			list = append(list, Coords{Col: c.Col + dcol, Row: c.Row + drow})
+		}
+	}
+	return list
+}
+
+// Neighbors returns the hexes on the board next to a hex,
+// wrapping around the edges of the board.
+func (b *Board) Neighbors(g Geometry, c Coords) []Coords {
+	var list []Coords
+	seen := make(map[Coords]bool)
+	for _, n := range g.Neighbors(c) {
+		if n = b.wrap(n); b.OnBoard(n) && n != c && !seen[n] {
+			seen[n] = true
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+// Distance returns the number of hexes between two hexes,
+// taking the shortest way around the wrapped edges of the board.
+func (b *Board) Distance(g Geometry, from, to Coords) int {
+	h := g.ToCube(from)
+	best := -1
+	for _, image := range b.images(to) {
+		if d := h.Distance(g.ToCube(image)); best < 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
@@ -0,0 +1,145 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package board
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrapNeighbors(t *testing.T) {
+	for _, g := range []Geometry{{Orientation: "flat", Offset: "even"}, {Orientation: "flat", Offset: "odd"}, {Orientation: "pointy", Offset: "even"}, {Orientation: "pointy", Offset: "odd"}} {
+		for _, w := range []Wrap{WrapNone, WrapEastWest, WrapNorthSouth, WrapBoth} {
+			b := NewBoard(6, 8)
+			if err := b.SetWrap(w, g); err != nil {
+				t.Errorf("%v %s: %v", g, w, err)
+				continue
+			}
+			// being neighbors works both ways, even across the seams
+			for row := 0; row < b.Rows; row++ {
+				for col := 0; col < b.Cols; col++ {
+					c := Coords{Col: col, Row: row}
+					if !b.OnBoard(c) {
+						continue
+					}
+					neighbors := b.Neighbors(g, c)
+					if w == WrapBoth && len(neighbors) != 6 {
+						t.Errorf("%v %s: %v has %d neighbors", g, w, c, len(neighbors))
+					}
+					for _, n := range neighbors {
+						if b.Distance(g, c, n) != 1 {
+							t.Errorf("%v %s: %v to %v: distance %d", g, w, c, n, b.Distance(g, c, n))
+						}
+						found := false
+						for _, back := range b.Neighbors(g, n) {
+							found = found || back == c
+						}
+						if !found {
+							t.Errorf("%v %s: %v is next to %v, but not the other way", g, w, c, n)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	b := NewBoard(6, 8)
+	g := Geometry{}
+	if d := b.Distance(g, Coords{Col: 1, Row: 3}, Coords{Col: 8, Row: 3}); d != 7 {
+		t.Errorf("no wrap: distance %d, want 7", d)
+	}
+	_ = b.SetWrap(WrapEastWest, g)
+	if d := b.Distance(g, Coords{Col: 1, Row: 3}, Coords{Col: 8, Row: 3}); d != 1 {
+		t.Errorf("east-west: distance %d, want 1", d)
+	}
+	if path, err := b.Path(g, Coords{Col: 2, Row: 3}, Coords{Col: 7, Row: 3}); err != nil || len(path) != 4 {
+		t.Errorf("east-west: path %v, %v, want 4 hexes", path, err)
+	}
+	if b.OnBoard(Coords{Col: 0, Row: 3}) || !b.OnBoard(Coords{Col: 1, Row: 0}) {
+		t.Errorf("east-west: want the border left off the wrapped edges only")
+	}
+}
+
+func TestSetWrap(t *testing.T) {
+	for _, tc := range []struct {
+		rows, cols int
+		w          Wrap
+		g          Geometry
+		ok         bool
+	}{
+		{6, 8, WrapBoth, Geometry{}, true},
+		{5, 8, WrapBoth, Geometry{}, true},
+		{6, 7, WrapEastWest, Geometry{}, false},
+		{6, 7, WrapNorthSouth, Geometry{}, true},
+		{5, 8, WrapNorthSouth, Geometry{Orientation: "pointy"}, false},
+		{6, 7, WrapEastWest, Geometry{Orientation: "pointy"}, true},
+	} {
+		err := NewBoard(tc.rows, tc.cols).SetWrap(tc.w, tc.g)
+		if (err == nil) != tc.ok {
+			t.Errorf("%dx%d %s %v: got %v", tc.cols, tc.rows, tc.w, tc.g, err)
+		}
+	}
+	for _, name := range []string{"none", "east-west", "north-south", "both"} {
+		if w, err := ParseWrap(name); err != nil || w.String() != name {
+			t.Errorf("%s: got %s, %v", name, w, err)
+		}
+	}
+	if w, err := ParseWrap("torus"); err != nil || w != WrapBoth {
+		t.Errorf("torus: got %s, %v", w, err)
+	}
+	if _, err := ParseWrap("sphere"); err == nil {
+		t.Errorf("sphere: want error")
+	}
+}
+
+func TestWrapSVG(t *testing.T) {
+	nodes := []Node{{Name: "Ur", Col: 1, Row: 2, Warps: []string{"Adab"}}, {Name: "Adab", Col: 8, Row: 2}}
+	mask, _ := ShapeMask("rectangle", 8, 4, Geometry{})
+	b, err := FromNodesMasked(nodes, mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = b.SetWrap(WrapEastWest, Geometry{})
+
+	// each star draws half of the line, out across the nearest edge
+	s := b.asSVG(DefaultRenderOptions(false))
+	if len(s.lines) != 2 {
+		t.Fatalf("want 2 lines, got %d", len(s.lines))
+	}
+	for _, l := range s.lines {
+		leftward := l.x2 < l.x1
+		if want := l.from == "Ur"; leftward != want {
+			t.Errorf("%s to %s: line %+v goes the wrong way", l.from, l.to, l)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := b.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), `"wrap": "east-west"`) {
+		t.Errorf("json: want the wrap in\n%s", buf)
+	}
+}
@@ -22,13 +22,32 @@
  * SOFTWARE.
  */
 
-// Package coords implements a hex-based coordinate system.
+// Package coords implements the coordinates of the hexes on a board.
+//
+// A hex is named by its column and row, counting from 1 in the top left.
+// Coordinates can be written in several notations:
+//
+//	CCRR     0203      two digits each for the column and row, as printed on the map
+//	col,row  2,3       the column and row
+//	cube     2,2,-4    the q, r and s cube coordinates of the hex
+//	chess    B3        a letter for the column (A, B, ..., Z, AA, AB, ...) and the row
+//
+// Parse reads any of them.
 package coords
 
+import (
+	"fmt"
+	"github.com/mdhender/wow/pkg/hexes"
+	"strconv"
+	"strings"
+)
+
+// Coords are the column and row of a hex.
 type Coords struct {
 	Col, Row int
 }
 
+// Less orders coordinates by row and then column.
 func (c Coords) Less(d Coords) bool {
 	if c.Row < d.Row {
 		return true
@@ -37,3 +56,194 @@ func (c Coords) Less(d Coords) bool {
 	}
 	return c.Col < d.Col
 }
+
+// String returns the coordinates in CCRR notation, like "0203".
+func (c Coords) String() string {
+	b := make([]byte, 0, 8)
+	for _, n := range []int{c.Col, c.Row} {
+		if n >= 0 && n < 10 {
+			b = append(b, '0')
+		}
+		b = strconv.AppendInt(b, int64(n), 10)
+	}
+	return string(b)
+}
+
+// Format returns the coordinates in the given notation. Cube coordinates
+// are for flat hexes with the even columns pushed down; use
+// Geometry.Format for other boards.
+func (c Coords) Format(n Notation) string {
+	return Geometry{}.Format(c, n)
+}
+
+// Parse reads coordinates in any notation. Cube coordinates are for flat
+// hexes with the even columns pushed down; use Geometry.Parse for other
+// boards.
+func Parse(s string) (Coords, error) {
+	return Geometry{}.Parse(s)
+}
+
+// Notation is a way of writing coordinates.
+type Notation int
+
+const (
+	CCRR   Notation = 0
+	ColRow Notation = 1
+	Cube   Notation = 2
+	Chess  Notation = 3
+)
+
+// Notations are the names of the notations, in order.
+var Notations = []string{"ccrr", "col,row", "cube", "chess"}
+
+// String returns the name of the notation.
+func (n Notation) String() string {
+	if n >= 0 && int(n) < len(Notations) {
+		return Notations[n]
+	}
+	return fmt.Sprintf("Notation(%d)", int(n))
+}
+
+// ParseNotation returns the notation with the given name.
+func ParseNotation(name string) (Notation, error) {
+	switch strings.ToLower(name) {
+	case "", "ccrr":
+		return CCRR, nil
+	case "col,row", "colrow":
+		return ColRow, nil
+	case "cube":
+		return Cube, nil
+	case "chess":
+		return Chess, nil
+	}
+	return CCRR, fmt.Errorf("coords: notation must be ccrr, col,row, cube or chess")
+}
+
+// MarshalText returns the name of the notation.
+func (n Notation) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText reads the name of a notation.
+func (n *Notation) UnmarshalText(text []byte) error {
+	notation, err := ParseNotation(string(text))
+	if err != nil {
+		return err
+	}
+	*n = notation
+	return nil
+}
+
+// Format returns the coordinates in the given notation.
+// Chess notation can't name column 0, so the border hexes on the
+// left of the board are returned in col,row notation instead.
+func (g Geometry) Format(c Coords, n Notation) string {
+	switch n {
+	case ColRow:
+		return fmt.Sprintf("%d,%d", c.Col, c.Row)
+	case Cube:
+		q, r, s := g.ToCube(c).Coords()
+		return fmt.Sprintf("%d,%d,%d", q, r, s)
+	case Chess:
+		if c.Col < 1 {
+			return g.Format(c, ColRow)
+		}
+		return chessColumn(c.Col) + strconv.Itoa(c.Row)
+	}
+	return c.String()
+}
+
+// Parse reads coordinates in any notation. The notation is found from
+// the text: a number with an even number of digits is CCRR, two numbers
+// separated by a comma are col,row, three are cube coordinates and
+// letters followed by a number are chess notation. Spaces and
+// surrounding parentheses are ignored.
+func (g Geometry) Parse(s string) (Coords, error) {
+	text := strings.TrimSpace(s)
+	if strings.HasPrefix(text, "(") && strings.HasSuffix(text, ")") {
+		text = text[1 : len(text)-1]
+	}
+	text = strings.ReplaceAll(text, " ", "")
+	if text == "" {
+		return Coords{}, fmt.Errorf("coords: missing coordinates")
+	}
+
+	var c Coords
+	if fields := strings.Split(text, ","); len(fields) > 1 {
+		var n []int
+		for _, field := range fields {
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				return Coords{}, fmt.Errorf("coords: %q: %q is not a number", s, field)
+			}
+			n = append(n, v)
+		}
+		switch len(n) {
+		case 2:
+			c = Coords{Col: n[0], Row: n[1]}
+		case 3:
+			if n[0]+n[1]+n[2] != 0 {
+				return Coords{}, fmt.Errorf("coords: %q: cube coordinates must add up to 0", s)
+			}
+			c = g.FromCube(hexes.NewHex(n[0], n[1], n[2]))
+		default:
+			return Coords{}, fmt.Errorf("coords: %q: want col,row or q,r,s", s)
+		}
+	} else if letters := strings.IndexFunc(text, isDigit); letters > 0 {
+		col, ok := parseChessColumn(text[:letters])
+		row, err := strconv.Atoi(text[letters:])
+		if !ok || err != nil || !allDigits(text[letters:]) {
+			return Coords{}, fmt.Errorf("coords: %q: want letters and a row number, like B3", s)
+		}
+		c = Coords{Col: col, Row: row}
+	} else if allDigits(text) {
+		if len(text) < 4 || len(text)%2 != 0 {
+			return Coords{}, fmt.Errorf("coords: %q: want two digits each for the column and row, like 0203", s)
+		}
+		c.Col, _ = strconv.Atoi(text[:len(text)/2])
+		c.Row, _ = strconv.Atoi(text[len(text)/2:])
+	} else {
+		return Coords{}, fmt.Errorf("coords: %q is not a hex", s)
+	}
+	if c.Col < 0 || c.Row < 0 {
+		return Coords{}, fmt.Errorf("coords: %q is off the board", s)
+	}
+	return c, nil
+}
+
+// chessColumn returns the letters for a column: A to Z for 1 to 26,
+// then AA, AB and so on, like the columns of a spreadsheet.
+func chessColumn(col int) string {
+	var b []byte
+	for ; col > 0; col = (col - 1) / 26 {
+		b = append([]byte{byte('A' + (col-1)%26)}, b...)
+	}
+	return string(b)
+}
+
+// parseChessColumn is the inverse of chessColumn. Letters aren't case-sensitive.
+func parseChessColumn(s string) (int, bool) {
+	col := 0
+	for _, ch := range strings.ToUpper(s) {
+		if ch < 'A' || ch > 'Z' {
+			return 0, false
+		} else if col > 1e6 {
+			return 0, false
+		}
+		col = col*26 + int(ch-'A') + 1
+	}
+	return col, true
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+func allDigits(s string) bool {
+	for _, ch := range s {
+		if !isDigit(ch) {
+			return false
+		}
+	}
+	return s != ""
+}
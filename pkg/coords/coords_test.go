@@ -0,0 +1,105 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package coords
+
+import (
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		c    Coords
+		n    Notation
+		want string
+	}{
+		{Coords{Col: 2, Row: 3}, CCRR, "0203"},
+		{Coords{Col: 12, Row: 40}, CCRR, "1240"},
+		{Coords{Col: 2, Row: 3}, ColRow, "2,3"},
+		{Coords{Col: 2, Row: 3}, Cube, "2,2,-4"},
+		{Coords{Col: 2, Row: 3}, Chess, "B3"},
+		{Coords{Col: 26, Row: 1}, Chess, "Z1"},
+		{Coords{Col: 27, Row: 1}, Chess, "AA1"},
+		{Coords{Col: 0, Row: 4}, Chess, "0,4"},
+	} {
+		if got := tc.c.Format(tc.n); got != tc.want {
+			t.Errorf("%v %s: want %q, got %q", tc.c, tc.n, tc.want, got)
+		}
+	}
+	if got := (Geometry{Orientation: "pointy", Offset: "odd"}).Format(Coords{Col: 2, Row: 3}, Cube); got != "1,3,-4" {
+		t.Errorf("pointy odd cube: want %q, got %q", "1,3,-4", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want Coords
+	}{
+		{"0203", Coords{Col: 2, Row: 3}},
+		{" 1240 ", Coords{Col: 12, Row: 40}},
+		{"2,3", Coords{Col: 2, Row: 3}},
+		{"(2, 3)", Coords{Col: 2, Row: 3}},
+		{"2,2,-4", Coords{Col: 2, Row: 3}},
+		{"B3", Coords{Col: 2, Row: 3}},
+		{"b3", Coords{Col: 2, Row: 3}},
+		{"AA10", Coords{Col: 27, Row: 10}},
+	} {
+		if got, err := Parse(tc.s); err != nil {
+			t.Errorf("%q: want %v, got %v", tc.s, tc.want, err)
+		} else if got != tc.want {
+			t.Errorf("%q: want %v, got %v", tc.s, tc.want, got)
+		}
+	}
+	for _, s := range []string{"", "23", "023", "Ur", "3B", "B", "B3x", "2,x", "1,1,1", "1,2,3,4", "-1,3"} {
+		if c, err := Parse(s); err == nil {
+			t.Errorf("%q: want error, got %v", s, c)
+		}
+	}
+
+	// every notation reads back what it writes, on every geometry
+	for _, g := range []Geometry{{Orientation: "flat", Offset: "even"}, {Orientation: "flat", Offset: "odd"}, {Orientation: "pointy", Offset: "even"}, {Orientation: "pointy", Offset: "odd"}} {
+		for col := 1; col <= 30; col++ {
+			for row := 1; row <= 12; row++ {
+				c := Coords{Col: col, Row: row}
+				for n := range Notations {
+					if got, err := g.Parse(g.Format(c, Notation(n))); err != nil || got != c {
+						t.Errorf("%+v: %v %s: got %v, %v", g, c, Notation(n), got, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestParseNotation(t *testing.T) {
+	for i, name := range Notations {
+		if n, err := ParseNotation(name); err != nil || n != Notation(i) {
+			t.Errorf("%q: want %d, got %d, %v", name, i, n, err)
+		}
+	}
+	if _, err := ParseNotation("roman"); err == nil {
+		t.Errorf("roman: want error, got nil")
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package coords
+
+import (
+	"github.com/mdhender/wow/pkg/hexes"
+	"strings"
+)
+
+// Geometry says how the offset coordinates of a board map to hexes.
+// It has the same orientation and offset as the render options;
+// the zero value is flat hexes with the even columns pushed down.
+//
+// Boards store their hexes by column and row; everything that needs
+// cube coordinates (drawing, distances, neighbors, sight) converts here.
+type Geometry struct {
+	Orientation string // flat or pointy
+	Offset      string // even or odd
+}
+
+// Layout returns the orientation of the hexes and the offset of the
+// columns (flat hexes) or rows (pointy hexes).
+func (g Geometry) Layout() (hexes.HEXLAYOUT, hexes.OFFSET) {
+	layout, offset := hexes.FLATHEX, hexes.EVEN
+	if strings.ToLower(g.Orientation) == "pointy" {
+		layout = hexes.POINTYHEX
+	}
+	if strings.ToLower(g.Offset) == "odd" {
+		offset = hexes.ODD
+	}
+	return layout, offset
+}
+
+// ToCube converts offset coordinates to cube coordinates.
+func (g Geometry) ToCube(c Coords) hexes.Hex {
+	layout, offset := g.Layout()
+	if layout == hexes.POINTYHEX {
+		return hexes.ROffsetToCube(c.Col, c.Row, offset)
+	}
+	return hexes.QOffsetToCube(c.Col, c.Row, offset)
+}
+
+// FromCube converts cube coordinates to offset coordinates.
+func (g Geometry) FromCube(h hexes.Hex) Coords {
+	var col, row int
+	if layout, offset := g.Layout(); layout == hexes.POINTYHEX {
+		col, row = hexes.ROffsetFromCube(h, offset).Coords()
+	} else {
+		col, row = hexes.QOffsetFromCube(h, offset).Coords()
+	}
+	return Coords{Col: col, Row: row}
+}
+
+// Neighbors returns the six hexes around a hex, in the order of the
+// hex directions. Some of them may be off the board.
+func (g Geometry) Neighbors(c Coords) []Coords {
+	h := g.ToCube(c)
+	list := make([]Coords, 0, 6)
+	for direction := 0; direction < 6; direction++ {
+		list = append(list, g.FromCube(h.Neighbor(direction)))
+	}
+	return list
+}
@@ -0,0 +1,224 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package galaxy joins boards into a map for big campaigns.
+// Each board is a named sector, and warp lines between stars in different
+// sectors link the sectors together.
+package galaxy
+
+import (
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"sort"
+	"strings"
+)
+
+// Galaxy is a set of sectors and the warp lines between them.
+type Galaxy struct {
+	Sectors []*Sector // in the order they were added
+	Warps   []Warp    // warp lines between sectors
+}
+
+// Sector is a named board in the galaxy.
+type Sector struct {
+	Name  string
+	Board *board.Board
+}
+
+// Endpoint is a star in a sector.
+type Endpoint struct {
+	Sector string
+	Star   string
+}
+
+// ParseEndpoint parses "sector:star".
+func ParseEndpoint(s string) (Endpoint, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return Endpoint{}, fmt.Errorf("galaxy: %q: want sector:star", s)
+	}
+	e := Endpoint{Sector: strings.TrimSpace(s[:i]), Star: strings.TrimSpace(s[i+1:])}
+	if e.Sector == "" || e.Star == "" {
+		return Endpoint{}, fmt.Errorf("galaxy: %q: want sector:star", s)
+	}
+	return e, nil
+}
+
+// String implements the Stringer interface.
+func (e Endpoint) String() string {
+	return e.Sector + ":" + e.Star
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (e Endpoint) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (e *Endpoint) UnmarshalText(text []byte) (err error) {
+	*e, err = ParseEndpoint(string(text))
+	return err
+}
+
+// Warp is a warp line between stars in different sectors.
+type Warp struct {
+	From Endpoint `json:"from"`
+	To   Endpoint `json:"to"`
+}
+
+// New returns an empty galaxy.
+func New() *Galaxy {
+	return &Galaxy{}
+}
+
+// AddSector adds a board to the galaxy.
+// Sector names are used for file names, so they can't contain slashes,
+// and they can't contain colons, which separate them from star names.
+func (g *Galaxy) AddSector(name string, b *board.Board) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("galaxy: missing sector name")
+	} else if strings.ContainsAny(name, `:/\`) {
+		return fmt.Errorf("galaxy: sector %q: name can't contain ':', '/' or '\\'", name)
+	} else if _, ok := g.Sector(name); ok {
+		return fmt.Errorf("galaxy: duplicate sector %q", name)
+	} else if b == nil {
+		return fmt.Errorf("galaxy: sector %q: missing board", name)
+	}
+	g.Sectors = append(g.Sectors, &Sector{Name: name, Board: b})
+	return nil
+}
+
+// Sector returns the sector with the given name.
+func (g *Galaxy) Sector(name string) (*Sector, bool) {
+	for _, s := range g.Sectors {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// AddWarp adds a warp line between stars in different sectors.
+// Warp lines inside a sector belong on its board.
+func (g *Galaxy) AddWarp(from, to Endpoint) error {
+	for _, e := range []Endpoint{from, to} {
+		if s, ok := g.Sector(e.Sector); !ok {
+			return fmt.Errorf("galaxy: unknown sector %q", e.Sector)
+		} else if _, ok := s.Board.Stars[e.Star]; !ok {
+			return fmt.Errorf("galaxy: sector %q: unknown star %q", e.Sector, e.Star)
+		}
+	}
+	if from.Sector == to.Sector {
+		return fmt.Errorf("galaxy: %s to %s: warp lines inside a sector belong on its board", from, to)
+	}
+	for _, w := range g.Warps {
+		if (w.From == from && w.To == to) || (w.From == to && w.To == from) {
+			return nil
+		}
+	}
+	g.Warps = append(g.Warps, Warp{From: from, To: to})
+	return nil
+}
+
+// Route returns the shortest path along the warp lines from one star to
+// another, including both ends, crossing between sectors where it must.
+// When there are several shortest paths, it prefers the one that visits
+// stars earlier in alphabetical order of "sector:star".
+func (g *Galaxy) Route(from, to Endpoint) ([]Endpoint, error) {
+	for _, e := range []Endpoint{from, to} {
+		if s, ok := g.Sector(e.Sector); !ok {
+			return nil, fmt.Errorf("galaxy: unknown sector %q", e.Sector)
+		} else if _, ok := s.Board.Stars[e.Star]; !ok {
+			return nil, fmt.Errorf("galaxy: sector %q: unknown star %q", e.Sector, e.Star)
+		}
+	}
+
+	// breadth first search out from the destination, remembering the
+	// next star on the way back to it
+	next := make(map[Endpoint]Endpoint)
+	visited := map[Endpoint]bool{to: true}
+	queue := []Endpoint{to}
+	for len(queue) != 0 && !visited[from] {
+		star := queue[0]
+		queue = queue[1:]
+		for _, neighbour := range g.neighbours(star) {
+			if visited[neighbour] {
+				continue
+			}
+			visited[neighbour] = true
+			next[neighbour] = star
+			queue = append(queue, neighbour)
+		}
+	}
+	if !visited[from] {
+		return nil, fmt.Errorf("galaxy: %s to %s: %w", from, to, board.ErrNoRoute)
+	}
+	route := []Endpoint{from}
+	for star := from; star != to; route = append(route, star) {
+		star = next[star]
+	}
+	return route, nil
+}
+
+// neighbours returns the stars one warp line away, in this sector or
+// another, sorted.
+func (g *Galaxy) neighbours(e Endpoint) []Endpoint {
+	var list []Endpoint
+	if s, ok := g.Sector(e.Sector); ok {
+		for _, h := range s.Board.Stars[e.Star].WormHoleExits {
+			list = append(list, Endpoint{Sector: e.Sector, Star: h.Name})
+		}
+	}
+	for _, w := range g.Warps {
+		if w.From == e {
+			list = append(list, w.To)
+		} else if w.To == e {
+			list = append(list, w.From)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].String() < list[j].String()
+	})
+	return list
+}
+
+// SectorBoard returns the board of a sector with a stub for each warp
+// line that leaves it, labelled with the star at the other end.
+// The sector's own board isn't changed.
+func (g *Galaxy) SectorBoard(name string) (*board.Board, error) {
+	s, ok := g.Sector(name)
+	if !ok {
+		return nil, fmt.Errorf("galaxy: unknown sector %q", name)
+	}
+	b := *s.Board
+	b.Stubs = append([]board.Stub(nil), s.Board.Stubs...)
+	for _, w := range g.Warps {
+		if w.From.Sector == name {
+			b.Stubs = append(b.Stubs, board.Stub{Star: w.From.Star, Label: w.To.String()})
+		} else if w.To.Sector == name {
+			b.Stubs = append(b.Stubs, board.Stub{Star: w.To.Star, Label: w.From.String()})
+		}
+	}
+	return &b, nil
+}
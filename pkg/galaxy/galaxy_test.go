@@ -0,0 +1,166 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package galaxy
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mdhender/wow/pkg/board"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testGalaxy returns three sectors. Alpha and Beta are joined by two warp
+// lines; Gamma is on its own.
+func testGalaxy(t *testing.T) *Galaxy {
+	t.Helper()
+	g := New()
+	for _, s := range []struct {
+		name  string
+		nodes []board.Node
+	}{
+		{"Alpha", []board.Node{{Name: "Ur", Col: 1, Row: 1, Warps: []string{"Adab"}}, {Name: "Adab", Col: 4, Row: 3}, {Name: "Kish", Col: 2, Row: 5}}},
+		{"Beta", []board.Node{{Name: "Susa", Col: 2, Row: 2, Warps: []string{"Elam"}}, {Name: "Elam", Col: 3, Row: 4}}},
+		{"Gamma", []board.Node{{Name: "Mari", Col: 1, Row: 1}}},
+	} {
+		b, err := board.FromNodes(s.nodes)
+		if err != nil {
+			t.Fatal(err)
+		} else if err = g.AddSector(s.name, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, w := range [][2]string{{"Alpha:Adab", "Beta:Susa"}, {"Beta:Elam", "Alpha:Kish"}} {
+		from, _ := ParseEndpoint(w[0])
+		to, _ := ParseEndpoint(w[1])
+		if err := g.AddWarp(from, to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestRoute(t *testing.T) {
+	g := testGalaxy(t)
+	route, err := g.Route(Endpoint{"Alpha", "Ur"}, Endpoint{"Beta", "Elam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range route {
+		got = append(got, e.String())
+	}
+	if want := []string{"Alpha:Ur", "Alpha:Adab", "Beta:Susa", "Beta:Elam"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("route: got %v, want %v", got, want)
+	}
+	if _, err := g.Route(Endpoint{"Alpha", "Ur"}, Endpoint{"Gamma", "Mari"}); !errors.Is(err, board.ErrNoRoute) {
+		t.Errorf("gamma: got %v, want %v", err, board.ErrNoRoute)
+	}
+
+	for _, tc := range []struct {
+		from, to string
+		want     string
+	}{
+		{"Alpha:Ur", "Beta:Ur", `unknown star "Ur"`},
+		{"Alpha:Ur", "Delta:Ur", `unknown sector "Delta"`},
+		{"Alpha:Ur", "Alpha:Kish", "belong on its board"},
+	} {
+		from, _ := ParseEndpoint(tc.from)
+		to, _ := ParseEndpoint(tc.to)
+		if err := g.AddWarp(from, to); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s to %s: got %v, want %q", tc.from, tc.to, err, tc.want)
+		}
+	}
+	if err := g.AddSector("Alpha", board.NewBoard(1, 1)); err == nil {
+		t.Errorf("duplicate sector: want error")
+	}
+	if _, err := ParseEndpoint("Ur"); err == nil {
+		t.Errorf("endpoint without sector: want error")
+	}
+}
+
+func TestRender(t *testing.T) {
+	g := testGalaxy(t)
+	b, err := g.SectorBoard("Alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []board.Stub{{Star: "Adab", Label: "Beta:Susa"}, {Star: "Kish", Label: "Beta:Elam"}}; !reflect.DeepEqual(b.Stubs, want) {
+		t.Errorf("stubs: got %v, want %v", b.Stubs, want)
+	}
+	if s, _ := g.Sector("Alpha"); len(s.Board.Stubs) != 0 {
+		t.Errorf("stubs: the sector's board was changed")
+	}
+	data, err := b.RenderSVG(board.DefaultRenderOptions(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(">Beta:Susa</text>")) || !bytes.Contains(data, []byte("stroke-dasharray")) {
+		t.Errorf("sector: want dashed stubs with labels\n%s", data)
+	}
+
+	data, err = g.RenderOverview(board.DefaultRenderOptions(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`data-sector="Gamma"`, `data-from="Alpha" data-to="Beta"`, ">2 warps</text>", ">1 star</text>"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("overview: want %s in\n%s", want, data)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	g := testGalaxy(t)
+	buf := &bytes.Buffer{}
+	if err := g.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.String()
+	if !strings.Contains(data, `"from": "Alpha:Adab"`) {
+		t.Errorf("json: want the warps in\n%s", data)
+	}
+	g, err := Read(strings.NewReader(data), board.Geometry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	_ = g.WriteJSON(buf)
+	if buf.String() != data {
+		t.Errorf("json: round trip: got\n%s\nwant\n%s", buf, data)
+	}
+
+	for _, tc := range []struct{ input, want string }{
+		{`{"sectors": [{"name": "A", "nodes": [{"name": "Ur", "col": 1, "row": 1}]}], "warps": [{"from": "A:Ur", "to": "B:Ur"}]}`, `unknown sector "B"`},
+		{`{"sectors": [{"name": "A", "nodes": [{"name": "Ur", "col": 0, "row": 1}]}]}`, `sector "A": record 1: col`},
+		{`{"sectors": [{"name": "A/B", "nodes": [{"name": "Ur", "col": 1, "row": 1}]}]}`, `can't contain`},
+		{`{"sectors": [], "warps": [{"from": "Ur", "to": "A:Ur"}]}`, `want sector:star`},
+	} {
+		if _, err := Read(strings.NewReader(tc.input), board.Geometry{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", tc.input, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package galaxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"io"
+)
+
+// galaxyJSON is the JSON form of a galaxy. Each sector has the fields of
+// the JSON map data for a board, plus its name.
+type galaxyJSON struct {
+	Sectors []sectorJSON `json:"sectors"`
+	Warps   []Warp       `json:"warps,omitempty"`
+}
+
+type sectorJSON struct {
+	Name     string          `json:"name"`
+	Nodes    []board.Node    `json:"nodes"`
+	Features []board.Terrain `json:"features,omitempty"`
+	Mask     board.Mask      `json:"mask,omitempty"`
+	Wrap     board.Wrap      `json:"wrap,omitempty"`
+}
+
+// Read decodes a galaxy from JSON. Wrapping sectors need the geometry of
+// the hexes to check their size.
+func Read(r io.Reader, geo board.Geometry) (*Galaxy, error) {
+	var data galaxyJSON
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("galaxy: %w", err)
+	}
+	g := New()
+	for _, s := range data.Sectors {
+		for _, d := range board.ValidateNodes(s.Nodes) {
+			if d.Severity == board.SeverityError {
+				return nil, fmt.Errorf("galaxy: sector %q: %w", s.Name, d)
+			}
+		}
+		b, err := board.FromNodesMasked(s.Nodes, s.Mask)
+		if err != nil {
+			return nil, fmt.Errorf("galaxy: sector %q: %w", s.Name, err)
+		} else if err = b.AddTerrain(s.Features); err != nil {
+			return nil, fmt.Errorf("galaxy: sector %q: %w", s.Name, err)
+		} else if err = b.SetWrap(s.Wrap, geo); err != nil {
+			return nil, fmt.Errorf("galaxy: sector %q: %w", s.Name, err)
+		} else if err = g.AddSector(s.Name, b); err != nil {
+			return nil, err
+		}
+	}
+	for _, w := range data.Warps {
+		if err := g.AddWarp(w.From, w.To); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// WriteJSON writes the galaxy in the format read by Read.
+func (g *Galaxy) WriteJSON(w io.Writer) error {
+	data := galaxyJSON{Sectors: []sectorJSON{}, Warps: g.Warps}
+	for _, s := range g.Sectors {
+		nodes := s.Board.Nodes()
+		for i := range nodes {
+			if nodes[i].Warps == nil {
+				nodes[i].Warps = []string{}
+			}
+		}
+		data.Sectors = append(data.Sectors, sectorJSON{Name: s.Name, Nodes: nodes, Features: s.Board.Terrain(), Mask: s.Board.Mask, Wrap: s.Board.Wrap})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
@@ -0,0 +1,118 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package galaxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"html"
+	"io"
+	"math"
+)
+
+// RenderOverview returns an SVG of the galaxy with each sector drawn as a
+// circle, and a line between sectors that are joined by warp lines.
+func (g *Galaxy) RenderOverview(o board.RenderOptions) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := g.WriteOverview(buf, o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteOverview streams the overview of the galaxy as an SVG.
+// The sectors are placed around a circle in the order they were added,
+// and use the colors of the stars in the theme. Lines are labelled with
+// the number of warps when there is more than one.
+func (g *Galaxy) WriteOverview(w io.Writer, o board.RenderOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	o = o.WithDefaults()
+	radius := o.HexSize // of the circle for a sector
+
+	// place the sectors around a circle with room between them
+	ring := 0.0
+	if n := len(g.Sectors); n > 1 {
+		ring = math.Max(3*radius*float64(n)/(2*math.Pi), 2*radius)
+	}
+	size := 2 * (ring + radius + o.Margin)
+	centers := make(map[string][2]float64)
+	for i, s := range g.Sectors {
+		angle := -math.Pi/2 + 2*math.Pi*float64(i)/float64(len(g.Sectors))
+		centers[s.Name] = [2]float64{size/2 + ring*math.Cos(angle), size/2 + ring*math.Sin(angle)}
+	}
+
+	// count the warps between each pair of sectors
+	type pair struct{ a, b string }
+	var pairs []pair
+	count := make(map[pair]int)
+	for _, warp := range g.Warps {
+		p := pair{warp.From.Sector, warp.To.Sector}
+		if p.a > p.b {
+			p.a, p.b = p.b, p.a
+		}
+		if count[p] == 0 {
+			pairs = append(pairs, p)
+		}
+		count[p]++
+	}
+
+	bw := bufio.NewWriter(w)
+	text := func(x, y, size float64, fill, content string) {
+		_, _ = fmt.Fprintf(bw, `<text x="%f" y="%f" text-anchor="middle" fill="%s" font-size="%g" font-weight="bold"`, x, y, html.EscapeString(fill), size)
+		if o.FontFamily != "" {
+			_, _ = fmt.Fprintf(bw, ` font-family="%s"`, html.EscapeString(o.FontFamily))
+		}
+		_, _ = fmt.Fprintf(bw, ">%s</text>\n", html.EscapeString(content))
+	}
+	_, _ = fmt.Fprintf(bw, `<svg width="%d" height="%d" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg">`+"\n", int(math.Ceil(size)), int(math.Ceil(size)), int(math.Ceil(size)), int(math.Ceil(size)))
+	if o.Theme.Background != "" && o.Theme.Background != "none" {
+		_, _ = fmt.Fprintf(bw, `<rect x="0" y="0" width="%d" height="%d" fill="%s"/>`+"\n", int(math.Ceil(size)), int(math.Ceil(size)), html.EscapeString(o.Theme.Background))
+	}
+	for _, p := range pairs {
+		a, b := centers[p.a], centers[p.b]
+		_, _ = fmt.Fprintf(bw, `<line class="warp" data-from="%s" data-to="%s" x1="%f" y1="%f" x2="%f" y2="%f" stroke-width="%g" stroke="%s"/>`+"\n",
+			html.EscapeString(p.a), html.EscapeString(p.b), a[0], a[1], b[0], b[1], o.WarpWidth, html.EscapeString(o.Theme.WarpColor))
+		if count[p] > 1 {
+			text((a[0]+b[0])/2, (a[1]+b[1])/2-o.FontSize/2, o.FontSize, o.Theme.WarpColor, fmt.Sprintf("%d warps", count[p]))
+		}
+	}
+	for _, s := range g.Sectors {
+		c := centers[s.Name]
+		_, _ = fmt.Fprintf(bw, `<circle class="sector" data-sector="%s" cx="%f" cy="%f" r="%f" style="fill: %s; stroke: %s; stroke-width: %gpx" />`+"\n",
+			html.EscapeString(s.Name), c[0], c[1], radius, html.EscapeString(o.Theme.StarFill), html.EscapeString(o.Theme.StarStroke), o.StrokeWidth)
+		text(c[0], c[1]-o.FontSize*0.2, o.FontSize+2, o.Theme.TextColor, s.Name)
+		stars := fmt.Sprintf("%d stars", len(s.Board.Stars))
+		if len(s.Board.Stars) == 1 {
+			stars = "1 star"
+		}
+		text(c[0], c[1]+o.FontSize*1.2, o.FontSize, o.Theme.TextColor, stars)
+	}
+	_, _ = fmt.Fprint(bw, "</svg>")
+	return bw.Flush()
+}
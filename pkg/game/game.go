@@ -0,0 +1,468 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package game implements the turn engine for games hosted by the server.
+//
+// The rules are deliberately small. Each player starts with a home star.
+// Each turn a player may order "claim <star>" for any unclaimed star that
+// is one warp line away from a star they control. Claims on the same star
+// by two or more players in the same turn cancel each other out. Players
+// can see the stars they control and the stars one warp line away; all
+// other stars are hidden by the fog of war.
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/coords"
+	"github.com/mdhender/wow/pkg/store"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrGameFull        = errors.New("game is full")
+	ErrGameStarted     = errors.New("game has started")
+	ErrInvalidOrders   = errors.New("invalid orders")
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrTurnProcessed   = errors.New("turn already processed")
+)
+
+// DefaultSightRadius is how many hexes players can see from their stars
+// unless the engine is told otherwise.
+const DefaultSightRadius = 2
+
+// Engine runs games using the data in a store.
+type Engine struct {
+	sync.Mutex // serializes changes to games
+	store      store.Store
+	sight      int // hexes players can see from their stars
+}
+
+// New returns an engine that uses the given store.
+func New(st store.Store) *Engine {
+	return &Engine{store: st, sight: DefaultSightRadius}
+}
+
+// SetSightRadius sets how many hexes players can see from their stars.
+// Zero limits them to the stars they control and their warp neighbours.
+func (e *Engine) SetSightRadius(radius int) {
+	e.Lock()
+	defer e.Unlock()
+	e.sight = radius
+}
+
+// SightRadius returns how many hexes players can see from their stars.
+func (e *Engine) SightRadius() int {
+	e.Lock()
+	defer e.Unlock()
+	return e.sight
+}
+
+// mapData is the JSON format used for saved maps.
+type mapData struct {
+	Nodes []board.Node `json:"nodes"`
+}
+
+// DecodeMap returns the nodes from saved map data.
+func DecodeMap(data []byte) ([]board.Node, error) {
+	var md mapData
+	if err := json.Unmarshal(data, &md); err != nil {
+		return nil, err
+	}
+	return md.Nodes, nil
+}
+
+// EncodeMap returns nodes in the format used for saved map data.
+func EncodeMap(nodes []board.Node) ([]byte, error) {
+	return json.Marshal(mapData{Nodes: nodes})
+}
+
+// CreateGame creates a new game on a saved map.
+// The game starts on turn 1 and is open for players to join
+// until the first turn is processed.
+// The schedule, which may be empty, is saved with the game.
+// It returns the game master's token, which is not saved.
+func (e *Engine) CreateGame(name, mapID, schedule string, now time.Time) (*store.Game, string, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	sched, err := ParseSchedule(schedule)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	m, err := e.store.GetMap(mapID)
+	if err != nil {
+		return nil, "", fmt.Errorf("map %q: %w", mapID, err)
+	}
+	nodes, err := DecodeMap(m.Data)
+	if err != nil {
+		return nil, "", fmt.Errorf("map %q: %w", mapID, err)
+	} else if _, err := board.FromNodes(nodes); err != nil {
+		return nil, "", fmt.Errorf("map %q: %w", mapID, err)
+	}
+	token := NewToken()
+	g := &store.Game{Name: name, MapID: mapID, Turn: 1, GMToken: HashToken(token), Schedule: sched.String(), Deadline: sched.Next(now)}
+	if err := e.store.CreateGame(g); err != nil {
+		return nil, "", err
+	}
+	return g, token, nil
+}
+
+// Board returns the full board for a game.
+func (e *Engine) Board(g *store.Game) (*board.Board, error) {
+	m, err := e.store.GetMap(g.MapID)
+	if err != nil {
+		return nil, fmt.Errorf("map %q: %w", g.MapID, err)
+	}
+	nodes, err := DecodeMap(m.Data)
+	if err != nil {
+		return nil, fmt.Errorf("map %q: %w", g.MapID, err)
+	}
+	return board.FromNodes(nodes)
+}
+
+// Join adds a player to a game and assigns them a home star.
+// Players may only join before the first turn is processed.
+// It returns the player's token, which is not saved.
+func (e *Engine) Join(gameID, name, email string) (*store.Player, string, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return nil, "", err
+	} else if g.Turn != 1 {
+		return nil, "", ErrGameStarted
+	}
+	b, err := e.Board(g)
+	if err != nil {
+		return nil, "", err
+	}
+	players, err := e.store.ListPlayers(g.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	home := homeStar(b, players)
+	if home == "" {
+		return nil, "", ErrGameFull
+	}
+	token := NewToken()
+	p := &store.Player{GameID: g.ID, Name: name, Email: email, Home: home, Stars: []string{home}, Token: HashToken(token)}
+	if err := e.store.CreatePlayer(p); err != nil {
+		return nil, "", err
+	}
+	return p, token, nil
+}
+
+// homeStar picks the best unclaimed star that isn't one warp line away from
+// another player's star. The best star has the highest economic value, with
+// ties broken by name so that the choice is repeatable.
+func homeStar(b *board.Board, players []*store.Player) string {
+	taken := make(map[string]bool)
+	for _, p := range players {
+		for _, name := range p.Stars {
+			taken[name] = true
+			if star, ok := b.Stars[name]; ok {
+				for _, exit := range star.WormHoleExits {
+					taken[exit.Name] = true
+				}
+			}
+		}
+	}
+	var best *board.Hex
+	for _, star := range b.Stars {
+		if taken[star.Name] {
+			continue
+		} else if best == nil || star.EconValue > best.EconValue || (star.EconValue == best.EconValue && star.Name < best.Name) {
+			best = star
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.Name
+}
+
+// SubmitOrders saves a player's orders for the current turn,
+// replacing any orders already submitted.
+// It returns an error if any of the orders can't be parsed.
+func (e *Engine) SubmitOrders(gameID, playerID, text string) (*store.Orders, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ParseOrders(text); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOrders, err)
+	}
+	o := &store.Orders{GameID: g.ID, PlayerID: playerID, Turn: g.Turn, Text: text}
+	if err := e.store.PutOrders(o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// ProcessTurn resolves the orders for the current turn, saves a report
+// for every player, and advances the game to the next turn.
+// Players who didn't submit orders are treated as having no orders.
+// If the game has a schedule, the deadline moves to the next one after now.
+// The turn is the one the caller expects to process; if the game has
+// moved past it, ProcessTurn returns ErrTurnProcessed and does nothing.
+func (e *Engine) ProcessTurn(gameID string, turn int, now time.Time) (*store.Game, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	} else if g.Turn != turn {
+		return nil, ErrTurnProcessed
+	}
+	b, err := e.Board(g)
+	if err != nil {
+		return nil, err
+	}
+	players, err := e.store.ListPlayers(g.ID)
+	if err != nil {
+		return nil, err
+	}
+	submitted, err := e.store.ListOrders(g.ID, g.Turn)
+	if err != nil {
+		return nil, err
+	}
+
+	owner := make(map[string]*store.Player)
+	for _, p := range players {
+		for _, name := range p.Stars {
+			owner[name] = p
+		}
+	}
+
+	// collect the claims, noting any that are invalid
+	results := make(map[string][]string) // player id to result lines
+	claims := make(map[string][]*store.Player)
+	for _, p := range players {
+		var text string
+		for _, o := range submitted {
+			if o.PlayerID == p.ID {
+				text = o.Text
+			}
+		}
+		orders, _ := ParseOrders(text)
+		if len(orders) == 0 {
+			results[p.ID] = append(results[p.ID], "no orders received")
+		}
+		claimed := make(map[string]bool)
+		for _, order := range orders {
+			order.Star = starName(b, order.Star)
+			if claimed[order.Star] {
+				results[p.ID] = append(results[p.ID], fmt.Sprintf("%s: failed: duplicate order", order))
+				continue
+			}
+			claimed[order.Star] = true
+			if err := checkClaim(b, owner, p, order.Star); err != nil {
+				results[p.ID] = append(results[p.ID], fmt.Sprintf("%s: failed: %v", order, err))
+				continue
+			}
+			claims[order.Star] = append(claims[order.Star], p)
+		}
+	}
+
+	// resolve the claims, in order of star name so that reports are stable
+	var stars []string
+	for name := range claims {
+		stars = append(stars, name)
+	}
+	sort.Strings(stars)
+	for _, name := range stars {
+		claimants := claims[name]
+		order := Order{Verb: "claim", Star: name}
+		if len(claimants) > 1 {
+			for _, p := range claimants {
+				results[p.ID] = append(results[p.ID], fmt.Sprintf("%s: failed: contested by %d players", order, len(claimants)))
+			}
+			continue
+		}
+		p := claimants[0]
+		p.Stars = append(p.Stars, name)
+		owner[name] = p
+		results[p.ID] = append(results[p.ID], fmt.Sprintf("%s: ok", order))
+	}
+
+	for _, p := range players {
+		if err := e.store.UpdatePlayer(p); err != nil {
+			return nil, err
+		}
+		r := &store.Report{GameID: g.ID, PlayerID: p.ID, Turn: g.Turn, Text: report(g, b, owner, p, results[p.ID], e.sight)}
+		if err := e.store.PutReport(r); err != nil {
+			return nil, err
+		}
+	}
+
+	g.Turn++
+	if sched, err := ParseSchedule(g.Schedule); err == nil {
+		g.Deadline, g.Reminded = sched.Next(now), false
+	}
+	if err := e.store.UpdateGame(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// starName returns the name of the star that an order is for. Orders
+// name the star or give the coordinates of its hex in any notation that
+// coords.Parse reads, like "0606" or "F6". A star name wins over
+// coordinates that look the same.
+func starName(b *board.Board, text string) string {
+	if _, ok := b.Stars[text]; ok {
+		return text
+	}
+	c, err := coords.Parse(text)
+	if err != nil {
+		return text
+	}
+	for name, star := range b.Stars {
+		if star.Coords == c {
+			return name
+		}
+	}
+	return text
+}
+
+// checkClaim returns an error if the player can't claim the star.
+func checkClaim(b *board.Board, owner map[string]*store.Player, p *store.Player, name string) error {
+	if _, ok := b.Stars[name]; !ok {
+		return fmt.Errorf("no such star")
+	} else if o, ok := owner[name]; ok {
+		if o == p {
+			return fmt.Errorf("already controlled")
+		}
+		return fmt.Errorf("controlled by another player")
+	}
+	// a one-way warp only leads from the star it starts at
+	for from, o := range owner {
+		if o != p {
+			continue
+		} else if _, ok := b.Warp(from, name); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("not connected to a controlled star")
+}
+
+// Visible returns the names of the stars that the player can see:
+// the stars they control, the stars one warp line away from them, and
+// the stars within sight hexes of them that aren't hidden by features
+// that block sight (see board.Board.FieldOfView).
+func Visible(b *board.Board, p *store.Player, sight int) map[string]bool {
+	visible := make(map[string]bool)
+	for _, name := range p.Stars {
+		if star, ok := b.Stars[name]; ok {
+			visible[name] = true
+			for _, exit := range star.WormHoleExits {
+				visible[exit.Name] = true
+			}
+			// games are played on flat hexes with the even columns pushed down
+			for _, c := range b.FieldOfView(board.Geometry{}, star.Coords, sight) {
+				if hex := b.Hexes[c.Row][c.Col]; hex.Name != "" {
+					visible[hex.Name] = true
+				}
+			}
+		}
+	}
+	return visible
+}
+
+// FogOfWar returns a copy of the board that holds only the stars
+// and warp lines that the player can see.
+func FogOfWar(b *board.Board, p *store.Player, sight int) *board.Board {
+	visible := Visible(b, p, sight)
+	// NewBoard adds a border, so remove it to get a board of the same size.
+	fog := board.NewBoard(b.Rows-2, b.Cols-2)
+	fog.Mask, fog.Wrap = b.Mask, b.Wrap
+	// the terrain is on the printed map, so every player can see it
+	for row := range b.Hexes {
+		for col, hex := range b.Hexes[row] {
+			fog.Hexes[row][col].Features = hex.Features
+		}
+	}
+	for name := range visible {
+		star := b.Stars[name]
+		fog.AddStar(star.Name, star.Coords.Row, star.Coords.Col, star.EconValue)
+	}
+	for name := range visible {
+		for _, exit := range b.Stars[name].WormHoleExits {
+			if visible[exit.Name] {
+				w, _ := b.Warp(name, exit.Name)
+				_ = fog.AddWarp(w)
+			}
+		}
+	}
+	return fog
+}
+
+// report returns the text of the turn report for a player.
+func report(g *store.Game, b *board.Board, owner map[string]*store.Player, p *store.Player, results []string, sight int) string {
+	sb := &strings.Builder{}
+	_, _ = fmt.Fprintf(sb, "Wars of Warp: %s: turn %d report for %s\n", g.Name, g.Turn, p.Name)
+
+	_, _ = fmt.Fprintf(sb, "\nOrders:\n")
+	for _, line := range results {
+		_, _ = fmt.Fprintf(sb, "  %s\n", line)
+	}
+
+	_, _ = fmt.Fprintf(sb, "\nStars:\n")
+	var names []string
+	for name := range Visible(b, p, sight) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		star := b.Stars[name]
+		status := "unclaimed"
+		if o, ok := owner[name]; ok && o == p {
+			status = "controlled"
+		} else if ok {
+			status = "controlled by " + o.Name
+		}
+		var exits []string
+		for _, exit := range star.WormHoleExits {
+			exits = append(exits, exit.Name)
+		}
+		sort.Strings(exits)
+		for i, exit := range exits {
+			w, _ := b.Warp(name, exit)
+			exits[i] = w.Target()
+		}
+		_, _ = fmt.Fprintf(sb, "  %-12s %s  econ %d  %-24s warps: %s\n", star.Name, star.Coords, star.EconValue, status, strings.Join(exits, ", "))
+	}
+	return sb.String()
+}
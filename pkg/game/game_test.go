@@ -0,0 +1,201 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package game
+
+import (
+	"errors"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/store"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestGame returns an engine and a game on a small map.
+// The stars form a line, Ur - Adab - Kish - Susa, so there is room for two players.
+func newTestGame(t *testing.T) (*Engine, *store.Game) {
+	st, err := store.NewFileStore(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	} else if err := st.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := EncodeMap([]board.Node{
+		{Name: "Ur", Col: 1, Row: 1, EconValue: 4, Warps: []string{"Adab"}},
+		{Name: "Adab", Col: 3, Row: 2, EconValue: 1, Warps: []string{"Kish"}},
+		{Name: "Kish", Col: 5, Row: 3, EconValue: 0, Warps: []string{"Susa"}},
+		{Name: "Susa", Col: 7, Row: 4, EconValue: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &store.Map{Name: "line", Data: data}
+	if err := st.CreateMap(m); err != nil {
+		t.Fatal(err)
+	}
+	e := New(st)
+	g, _, err := e.CreateGame("test", m.ID, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, g
+}
+
+func TestTurn(t *testing.T) {
+	e, g := newTestGame(t)
+
+	alice, token, err := e.Join(g.ID, "Alice", "")
+	if err != nil {
+		t.Fatal(err)
+	} else if !CheckToken(token, alice.Token) || CheckToken("", alice.Token) {
+		t.Errorf("alice: token does not match hash")
+	} else if alice.Home != "Ur" {
+		t.Errorf("alice: home: want %q, got %q", "Ur", alice.Home)
+	}
+	bob, _, err := e.Join(g.ID, "Bob", "")
+	if err != nil {
+		t.Fatal(err)
+	} else if bob.Home != "Susa" {
+		t.Errorf("bob: home: want %q, got %q", "Susa", bob.Home)
+	}
+	if _, _, err := e.Join(g.ID, "Carol", ""); !errors.Is(err, ErrGameFull) {
+		t.Errorf("carol: want %v, got %v", ErrGameFull, err)
+	}
+
+	if _, err := e.SubmitOrders(g.ID, alice.ID, "bogus"); err == nil {
+		t.Errorf("alice: bad orders: want error, got nil")
+	}
+	if _, err := e.SubmitOrders(g.ID, alice.ID, "claim Adab\nclaim Kish"); err != nil {
+		t.Fatal(err)
+	}
+	if g, err = e.ProcessTurn(g.ID, g.Turn, time.Now()); err != nil {
+		t.Fatal(err)
+	} else if g.Turn != 2 {
+		t.Errorf("turn: want 2, got %d", g.Turn)
+	}
+	if _, _, err := e.Join(g.ID, "Dave", ""); !errors.Is(err, ErrGameStarted) {
+		t.Errorf("dave: want %v, got %v", ErrGameStarted, err)
+	}
+
+	// claims are resolved against the start of the turn, so Kish is not yet reachable
+	r, err := e.store.GetReport(g.ID, alice.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"claim Adab: ok", "claim Kish: failed: not connected"} {
+		if !strings.Contains(r.Text, want) {
+			t.Errorf("alice: report: want %q in\n%s", want, r.Text)
+		}
+	}
+	r, err = e.store.GetReport(g.ID, bob.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(r.Text, "no orders received") {
+		t.Errorf("bob: report: want missing orders in\n%s", r.Text)
+	}
+
+	// both players claim Kish, so neither gets it
+	_, _ = e.SubmitOrders(g.ID, alice.ID, "claim Kish")
+	_, _ = e.SubmitOrders(g.ID, bob.ID, "# bob\nclaim E3") // Kish, by its hex
+	if _, err = e.ProcessTurn(g.ID, g.Turn, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// processing the same turn again does nothing
+	if _, err = e.ProcessTurn(g.ID, g.Turn, time.Now()); !errors.Is(err, ErrTurnProcessed) {
+		t.Errorf("turn %d again: want %v, got %v", g.Turn, ErrTurnProcessed, err)
+	}
+	r, err = e.store.GetReport(g.ID, bob.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(r.Text, "claim Kish: failed: contested by 2 players") {
+		t.Errorf("bob: report: want contested claim in\n%s", r.Text)
+	}
+
+	b, err := e.Board(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _ = e.store.GetPlayer(g.ID, alice.ID)
+	visible := Visible(b, alice, DefaultSightRadius)
+	for name, want := range map[string]bool{"Ur": true, "Adab": true, "Kish": true, "Susa": false} {
+		if visible[name] != want {
+			t.Errorf("alice: visible %q: want %v, got %v", name, want, visible[name])
+		}
+	}
+	if fog := FogOfWar(b, alice, DefaultSightRadius); len(fog.Stars) != 3 || fog.Rows != b.Rows || fog.Cols != b.Cols {
+		t.Errorf("alice: fog: want 3 stars on a %dx%d board, got %d on %dx%d", b.Rows, b.Cols, len(fog.Stars), fog.Rows, fog.Cols)
+	}
+}
+
+func TestCheckClaimOneWay(t *testing.T) {
+	b, err := board.FromNodes([]board.Node{
+		{Name: "Ur", Col: 1, Row: 1, Warps: []string{">Adab;2"}},
+		{Name: "Adab", Col: 3, Row: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, bob := &store.Player{Name: "alice", Stars: []string{"Ur"}}, &store.Player{Name: "bob", Stars: []string{"Adab"}}
+	if err := checkClaim(b, map[string]*store.Player{"Ur": alice}, alice, "Adab"); err != nil {
+		t.Errorf("alice: claim Adab: %v", err)
+	}
+	if err := checkClaim(b, map[string]*store.Player{"Adab": bob}, bob, "Ur"); err == nil {
+		t.Errorf("bob: claim Ur: want an error against the warp, got nil")
+	}
+	fog := FogOfWar(b, alice, DefaultSightRadius)
+	if w, ok := fog.Warp("Ur", "Adab"); !ok || !w.OneWay || w.Cost != 2 {
+		t.Errorf("fog: want the one-way warp, got %+v", w)
+	} else if _, ok := fog.Warp("Adab", "Ur"); ok {
+		t.Errorf("fog: want no warp back")
+	}
+}
+
+func TestVisibleFieldOfView(t *testing.T) {
+	// Kish is two hexes down column 3 from Ur, with no warp between them
+	b, err := board.FromNodes([]board.Node{
+		{Name: "Ur", Col: 3, Row: 1},
+		{Name: "Kish", Col: 3, Row: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice := &store.Player{Name: "alice", Stars: []string{"Ur"}}
+	if !Visible(b, alice, 2)["Kish"] {
+		t.Errorf("open space: want Kish visible from Ur")
+	} else if Visible(b, alice, 1)["Kish"] {
+		t.Errorf("radius 1: want Kish out of sight")
+	}
+
+	// a nebula between them hides Kish
+	if err := b.AddFeature(3, 2, board.Nebula); err != nil {
+		t.Fatal(err)
+	}
+	if Visible(b, alice, 2)["Kish"] {
+		t.Errorf("nebula: want Kish hidden from Ur")
+	} else if fog := FogOfWar(b, alice, 2); len(fog.Stars) != 1 {
+		t.Errorf("nebula: fog: want only Ur, got %d stars", len(fog.Stars))
+	}
+}
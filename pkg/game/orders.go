@@ -0,0 +1,67 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package game
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Order is a single parsed order.
+type Order struct {
+	Verb string
+	Star string
+}
+
+func (o Order) String() string {
+	return o.Verb + " " + o.Star
+}
+
+// ParseOrders parses the text of a player's orders.
+// There is one order per line. Blank lines and lines starting with '#' are ignored.
+// Orders are not case-sensitive, but star names are.
+// A star can also be given by the coordinates of its hex, in any notation
+// that coords.Parse reads; they are matched to the star when the turn is run.
+func ParseOrders(text string) ([]Order, error) {
+	var orders []Order
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch strings.ToLower(fields[0]) {
+		case "claim":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: claim: missing star", n+1)
+			}
+			// star names may contain spaces
+			orders = append(orders, Order{Verb: "claim", Star: strings.Join(fields[1:], " ")})
+		default:
+			return nil, fmt.Errorf("line %d: unknown order %q", n+1, fields[0])
+		}
+	}
+	return orders, nil
+}
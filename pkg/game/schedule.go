@@ -0,0 +1,223 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package game
+
+import (
+	"fmt"
+	"github.com/mdhender/wow/pkg/store"
+	"strings"
+	"time"
+)
+
+// Schedule says when the turns of a game are processed.
+//
+// A schedule is written as one or more rules joined by "or":
+//
+//	mon 18:00                 every Monday at 18:00 UTC
+//	daily 06:30               every day at 06:30 UTC
+//	all-orders                as soon as every player has sent orders
+//	mon 18:00 or all-orders   whichever comes first
+//
+// An empty schedule means that turns are only processed by hand.
+type Schedule struct {
+	// At is set if the schedule has a fixed time.
+	At bool
+	// Daily is set for a daily schedule; otherwise the schedule is weekly.
+	Daily   bool
+	Weekday time.Weekday
+	Hour    int
+	Minute  int
+	// AllOrders is set if the turn runs once all the orders are in.
+	AllOrders bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseSchedule returns the schedule for a spec.
+func ParseSchedule(spec string) (Schedule, error) {
+	var s Schedule
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return s, nil
+	}
+	for _, rule := range strings.Split(spec, " or ") {
+		fields := strings.Fields(rule)
+		switch {
+		case len(fields) == 1 && fields[0] == "all-orders":
+			if s.AllOrders {
+				return Schedule{}, fmt.Errorf("schedule: %q: all-orders given twice", spec)
+			}
+			s.AllOrders = true
+		case len(fields) == 2:
+			if s.At {
+				return Schedule{}, fmt.Errorf("schedule: %q: only one time is allowed", spec)
+			}
+			if fields[0] == "daily" {
+				s.Daily = true
+			} else if day, ok := weekdays[fields[0]]; ok {
+				s.Weekday = day
+			} else {
+				return Schedule{}, fmt.Errorf("schedule: %q: unknown day %q", spec, fields[0])
+			}
+			t, err := time.Parse("15:04", fields[1])
+			if err != nil {
+				return Schedule{}, fmt.Errorf("schedule: %q: time must be HH:MM", spec)
+			}
+			s.At, s.Hour, s.Minute = true, t.Hour(), t.Minute()
+		default:
+			return Schedule{}, fmt.Errorf("schedule: %q: unknown rule %q", spec, strings.TrimSpace(rule))
+		}
+	}
+	return s, nil
+}
+
+// String implements the Stringer interface.
+// It returns the schedule in the canonical form accepted by ParseSchedule.
+func (s Schedule) String() string {
+	var rules []string
+	if s.At {
+		day := "daily"
+		if !s.Daily {
+			day = strings.ToLower(s.Weekday.String()[:3])
+		}
+		rules = append(rules, fmt.Sprintf("%s %02d:%02d", day, s.Hour, s.Minute))
+	}
+	if s.AllOrders {
+		rules = append(rules, "all-orders")
+	}
+	return strings.Join(rules, " or ")
+}
+
+// Next returns the first deadline after t,
+// or the zero time if the schedule doesn't have a fixed time.
+func (s Schedule) Next(t time.Time) time.Time {
+	if !s.At {
+		return time.Time{}
+	}
+	t = t.UTC()
+	next := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, s.Minute, 0, 0, time.UTC)
+	if !s.Daily {
+		next = next.AddDate(0, 0, (int(s.Weekday)-int(next.Weekday())+7)%7)
+	}
+	for !next.After(t) {
+		if s.Daily {
+			next = next.AddDate(0, 0, 1)
+		} else {
+			next = next.AddDate(0, 0, 7)
+		}
+	}
+	return next
+}
+
+// SetSchedule changes the schedule of a game and sets the deadline
+// for the current turn to the next one after now.
+func (e *Engine) SetSchedule(gameID, spec string, now time.Time) (*store.Game, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	g.Schedule, g.Deadline, g.Reminded = sched.String(), sched.Next(now), false
+	if err := e.store.UpdateGame(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Waiting returns the players who haven't sent orders for the current turn.
+func (e *Engine) Waiting(gameID string) ([]*store.Player, error) {
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+	players, err := e.store.ListPlayers(g.ID)
+	if err != nil {
+		return nil, err
+	}
+	submitted, err := e.store.ListOrders(g.ID, g.Turn)
+	if err != nil {
+		return nil, err
+	}
+	sent := make(map[string]bool)
+	for _, o := range submitted {
+		sent[o.PlayerID] = true
+	}
+	var waiting []*store.Player
+	for _, p := range players {
+		if !sent[p.ID] {
+			waiting = append(waiting, p)
+		}
+	}
+	return waiting, nil
+}
+
+// Due returns true if the current turn of a game should be processed now.
+// A game with an all-orders schedule is due once it has players
+// and all of them have sent orders.
+func (e *Engine) Due(g *store.Game, now time.Time) (bool, error) {
+	sched, err := ParseSchedule(g.Schedule)
+	if err != nil {
+		return false, err
+	}
+	if sched.At && !g.Deadline.IsZero() && !now.Before(g.Deadline) {
+		return true, nil
+	} else if !sched.AllOrders {
+		return false, nil
+	}
+	players, err := e.store.ListPlayers(g.ID)
+	if err != nil || len(players) == 0 {
+		return false, err
+	}
+	waiting, err := e.Waiting(g.ID)
+	if err != nil {
+		return false, err
+	}
+	return len(waiting) == 0, nil
+}
+
+// MarkReminded records that reminders have been sent for a deadline.
+// It does nothing if the deadline has changed in the meantime.
+func (e *Engine) MarkReminded(gameID string, deadline time.Time) error {
+	e.Lock()
+	defer e.Unlock()
+
+	g, err := e.store.GetGame(gameID)
+	if err != nil {
+		return err
+	} else if !g.Deadline.Equal(deadline) {
+		return nil
+	}
+	g.Reminded = true
+	return e.store.UpdateGame(g)
+}
@@ -0,0 +1,125 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// 2022-10-05 is a Wednesday
+	now := time.Date(2022, 10, 5, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		want string
+		next time.Time
+	}{
+		{"", "", time.Time{}},
+		{"all-orders", "all-orders", time.Time{}},
+		{"Mon 18:00", "mon 18:00", time.Date(2022, 10, 10, 18, 0, 0, 0, time.UTC)},
+		{"wed 18:00", "wed 18:00", time.Date(2022, 10, 5, 18, 0, 0, 0, time.UTC)},
+		{"wed 12:00", "wed 12:00", time.Date(2022, 10, 12, 12, 0, 0, 0, time.UTC)},
+		{"daily 06:30", "daily 06:30", time.Date(2022, 10, 6, 6, 30, 0, 0, time.UTC)},
+		{"all-orders or fri 9:05", "fri 09:05 or all-orders", time.Date(2022, 10, 7, 9, 5, 0, 0, time.UTC)},
+	} {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if got := s.String(); got != tc.want {
+			t.Errorf("%q: string: want %q, got %q", tc.spec, tc.want, got)
+		}
+		if got := s.Next(now); !got.Equal(tc.next) {
+			t.Errorf("%q: next: want %v, got %v", tc.spec, tc.next, got)
+		}
+	}
+	for _, spec := range []string{"monday 18:00", "mon 6pm", "mon 18:00 or tue 18:00", "sometimes"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: want error, got none", spec)
+		}
+	}
+}
+
+func TestDue(t *testing.T) {
+	e, g := newTestGame(t)
+	now := time.Date(2022, 10, 5, 12, 0, 0, 0, time.UTC)
+
+	if _, err := e.SetSchedule(g.ID, "every day", now); !errors.Is(err, ErrInvalidSchedule) {
+		t.Errorf("set schedule: want %v, got %v", ErrInvalidSchedule, err)
+	}
+	g, err := e.SetSchedule(g.ID, "thu 12:00 or all-orders", now)
+	if err != nil {
+		t.Fatal(err)
+	} else if want := now.Add(24 * time.Hour); !g.Deadline.Equal(want) {
+		t.Errorf("deadline: want %v, got %v", want, g.Deadline)
+	}
+	if due, err := e.Due(g, now); err != nil || due {
+		t.Errorf("no players: want not due, got %v %v", due, err)
+	}
+
+	alice, _, err := e.Join(g.ID, "Alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, _, err := e.Join(g.ID, "Bob", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.SubmitOrders(g.ID, alice.ID, "claim Adab"); err != nil {
+		t.Fatal(err)
+	}
+	if waiting, err := e.Waiting(g.ID); err != nil {
+		t.Fatal(err)
+	} else if len(waiting) != 1 || waiting[0].ID != bob.ID {
+		t.Errorf("waiting: want bob, got %v", waiting)
+	}
+	if due, err := e.Due(g, now); err != nil || due {
+		t.Errorf("waiting for bob: want not due, got %v %v", due, err)
+	}
+	if due, err := e.Due(g, g.Deadline); err != nil || !due {
+		t.Errorf("at deadline: want due, got %v %v", due, err)
+	}
+	if _, err := e.SubmitOrders(g.ID, bob.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if due, err := e.Due(g, now); err != nil || !due {
+		t.Errorf("all orders in: want due, got %v %v", due, err)
+	}
+
+	if err := e.MarkReminded(g.ID, g.Deadline); err != nil {
+		t.Fatal(err)
+	}
+	// the turn runs at the deadline, so the next one is a week later
+	deadline := g.Deadline
+	g, err = e.ProcessTurn(g.ID, g.Turn, deadline)
+	if err != nil {
+		t.Fatal(err)
+	} else if want := deadline.Add(7 * 24 * time.Hour); g.Reminded || !g.Deadline.Equal(want) {
+		t.Errorf("after turn: want deadline %v, got %v reminded %v", want, g.Deadline, g.Reminded)
+	}
+}
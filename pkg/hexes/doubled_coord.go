@@ -36,6 +36,32 @@ func (a DoubledCoord) Equals(b DoubledCoord) bool {
 	return a.col == b.col && a.row == b.row
 }
 
+func (a DoubledCoord) Coords() (col, row int) {
+	return a.col, a.row
+}
+
+// QDoubledFromCube converts cube coordinates to doubled coordinates for
+// flat hexes, where the rows are doubled.
+func QDoubledFromCube(h Hex) DoubledCoord {
+	return qdoubled_from_cube(h)
+}
+
+// QDoubledToCube converts the doubled coordinates of flat hexes to cube coordinates.
+func QDoubledToCube(col, row int) Hex {
+	return qdoubled_to_cube(DoubledCoord{col: col, row: row})
+}
+
+// RDoubledFromCube converts cube coordinates to doubled coordinates for
+// pointy hexes, where the columns are doubled.
+func RDoubledFromCube(h Hex) DoubledCoord {
+	return rdoubled_from_cube(h)
+}
+
+// RDoubledToCube converts the doubled coordinates of pointy hexes to cube coordinates.
+func RDoubledToCube(col, row int) Hex {
+	return rdoubled_to_cube(DoubledCoord{col: col, row: row})
+}
+
 func qdoubled_from_cube(h Hex) DoubledCoord {
 	col := h.q
 	row := 2*h.r + h.q
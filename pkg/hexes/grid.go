@@ -0,0 +1,182 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package hexes
+
+import "sort"
+
+// Grid is a set of hexes, keyed by their cube coordinates, each of which
+// can hold a value. Values are interface{}, so callers assert them back
+// to their own type. A hex can be in the grid with a nil value; use Has
+// to tell it from a hex that isn't there.
+type Grid struct {
+	cells map[Hex]interface{}
+}
+
+// NewGrid returns an empty grid.
+func NewGrid() *Grid {
+	return &Grid{cells: make(map[Hex]interface{})}
+}
+
+// HexagonGrid returns a grid of the hexes within radius steps of center.
+func HexagonGrid(center Hex, radius int) *Grid {
+	g := NewGrid()
+	for _, h := range center.Range(radius) {
+		g.Set(h, nil)
+	}
+	return g
+}
+
+// ParallelogramGrid returns a grid of the hexes with q from q1 to q2
+// and r from r1 to r2.
+func ParallelogramGrid(q1, q2, r1, r2 int) *Grid {
+	g := NewGrid()
+	for q := q1; q <= q2; q++ {
+		for r := r1; r <= r2; r++ {
+			g.Set(NewHex(q, r, -q-r), nil)
+		}
+	}
+	return g
+}
+
+// TriangleGrid returns a grid of the triangle with its corner at corner
+// and side hexes on each side. The other corners are side-1 steps away
+// in the directions of q and r.
+func TriangleGrid(corner Hex, side int) *Grid {
+	g := NewGrid()
+	for q := 0; q < side; q++ {
+		for r := 0; q+r < side; r++ {
+			g.Set(corner.Add(NewHex(q, r, -q-r)), nil)
+		}
+	}
+	return g
+}
+
+// RectangleGrid returns a grid of the hexes with offset coordinates from
+// 0, 0 to cols-1, rows-1. The layout says whether the offset is for flat
+// hexes (columns) or pointy hexes (rows).
+func RectangleGrid(cols, rows int, layout HEXLAYOUT, offset OFFSET) *Grid {
+	g := NewGrid()
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if layout == POINTYHEX {
+				g.Set(ROffsetToCube(col, row, offset), nil)
+			} else {
+				g.Set(QOffsetToCube(col, row, offset), nil)
+			}
+		}
+	}
+	return g
+}
+
+// Set adds a hex to the grid, or replaces its value.
+func (g *Grid) Set(h Hex, value interface{}) {
+	g.cells[h] = value
+}
+
+// Get returns the value of a hex, or nil if the hex isn't in the grid.
+func (g *Grid) Get(h Hex) interface{} {
+	return g.cells[h]
+}
+
+// Lookup returns the value of a hex and whether the hex is in the grid.
+func (g *Grid) Lookup(h Hex) (interface{}, bool) {
+	value, ok := g.cells[h]
+	return value, ok
+}
+
+// Has reports whether a hex is in the grid.
+func (g *Grid) Has(h Hex) bool {
+	_, ok := g.cells[h]
+	return ok
+}
+
+// Delete removes a hex from the grid.
+func (g *Grid) Delete(h Hex) {
+	delete(g.cells, h)
+}
+
+// Len returns the number of hexes in the grid.
+func (g *Grid) Len() int {
+	return len(g.cells)
+}
+
+// Hexes returns the hexes in the grid in the order of Range:
+// by q, then by r.
+func (g *Grid) Hexes() []Hex {
+	list := make([]Hex, 0, len(g.cells))
+	for h := range g.cells {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].q != list[j].q {
+			return list[i].q < list[j].q
+		}
+		return list[i].r < list[j].r
+	})
+	return list
+}
+
+// Neighbors returns the neighbors of a hex that are in the grid,
+// in the order of the directions. The hex itself doesn't have to be
+// in the grid.
+func (g *Grid) Neighbors(h Hex) []Hex {
+	var list []Hex
+	for direction := 0; direction < 6; direction++ {
+		if n := h.Neighbor(direction); g.Has(n) {
+			list = append(list, n)
+		}
+	}
+	return list
+}
+
+// Bounds is the smallest and largest q, r and s of the hexes in a grid.
+type Bounds struct {
+	QMin, QMax int
+	RMin, RMax int
+	SMin, SMax int
+}
+
+// Contains reports whether a hex is inside the bounds.
+func (b Bounds) Contains(h Hex) bool {
+	return b.QMin <= h.q && h.q <= b.QMax && b.RMin <= h.r && h.r <= b.RMax && b.SMin <= h.s && h.s <= b.SMax
+}
+
+// Bounds returns the bounds of the hexes in the grid.
+// The bounds of an empty grid are all zero.
+func (g *Grid) Bounds() Bounds {
+	var b Bounds
+	first := true
+	for h := range g.cells {
+		if first {
+			b = Bounds{QMin: h.q, QMax: h.q, RMin: h.r, RMax: h.r, SMin: h.s, SMax: h.s}
+			first = false
+			continue
+		}
+		b.QMin, b.QMax = min(b.QMin, h.q), max(b.QMax, h.q)
+		b.RMin, b.RMax = min(b.RMin, h.r), max(b.RMax, h.r)
+		b.SMin, b.SMax = min(b.SMin, h.s), max(b.SMax, h.s)
+	}
+	return b
+}
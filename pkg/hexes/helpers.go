@@ -32,6 +32,22 @@ func abs(x int) int {
 	return x
 }
 
+// max is a helper function to get the larger of two integers
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// min is a helper function to get the smaller of two integers
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
 // mod is a helper function to get the modulus of an integer
 // (as opposed to %, which is the remainder operator)
 func mod(a, b int) int {
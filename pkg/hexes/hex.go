@@ -89,6 +89,59 @@ func (h Hex) Neighbor(direction int) Hex {
 	return h.Add(hex_direction(direction))
 }
 
+// Range returns the hexes within n steps of h, including h.
+// They are in order of q, then r.
+func (h Hex) Range(n int) (results []Hex) {
+	for q := -n; q <= n; q++ {
+		for r := max(-n, -q-n); r <= min(n, -q+n); r++ {
+			results = append(results, h.Add(NewHex(q, r, -q-r)))
+		}
+	}
+	return results
+}
+
+// ReflectQ reflects h across the q axis, swapping r and s.
+func (h Hex) ReflectQ() Hex {
+	return NewHex(h.q, h.s, h.r)
+}
+
+// ReflectR reflects h across the r axis, swapping q and s.
+func (h Hex) ReflectR() Hex {
+	return NewHex(h.s, h.r, h.q)
+}
+
+// ReflectS reflects h across the s axis, swapping q and r.
+func (h Hex) ReflectS() Hex {
+	return NewHex(h.r, h.q, h.s)
+}
+
+// Ring returns the hexes that are exactly radius steps from h.
+// The ring starts radius steps in direction 4 from h and goes around
+// in the order of the directions. The ring of radius 0 is just h.
+func (h Hex) Ring(radius int) (results []Hex) {
+	if radius == 0 {
+		return []Hex{h}
+	}
+	hex := h.Add(hex_direction(4).Scale(radius))
+	for i := 0; i < 6; i++ {
+		for j := 0; j < radius; j++ {
+			results = append(results, hex)
+			hex = hex.Neighbor(i)
+		}
+	}
+	return results
+}
+
+// RotateAround rotates h about center by 60 degrees for each step.
+// Positive steps rotate right (like RotateRight) and negative steps left.
+func (h Hex) RotateAround(center Hex, steps int) Hex {
+	v := h.Subtract(center)
+	for i := mod(steps, 6); i > 0; i-- {
+		v = v.RotateRight()
+	}
+	return center.Add(v)
+}
+
 func (h Hex) RotateLeft() Hex {
 	return NewHex(-h.s, -h.q, -h.r)
 }
@@ -101,10 +154,33 @@ func (h Hex) Scale(k int) Hex {
 	return NewHex(h.q*k, h.r*k, h.s*k)
 }
 
+// Spiral returns the rings around h from radius 0 out to radius,
+// which are the same hexes as Range(radius) in a different order.
+func (h Hex) Spiral(radius int) (results []Hex) {
+	for k := 0; k <= radius; k++ {
+		results = append(results, h.Ring(k)...)
+	}
+	return results
+}
+
 func (h Hex) Subtract(b Hex) Hex {
 	return NewHex(h.q-b.q, h.r-b.r, h.s-b.s)
 }
 
+// Intersect returns the hexes within n steps of a that are also within
+// m steps of b, in order of q, then r.
+func Intersect(a Hex, n int, b Hex, m int) (results []Hex) {
+	qmin, qmax := max(a.q-n, b.q-m), min(a.q+n, b.q+m)
+	rmin, rmax := max(a.r-n, b.r-m), min(a.r+n, b.r+m)
+	smin, smax := max(a.s-n, b.s-m), min(a.s+n, b.s+m)
+	for q := qmin; q <= qmax; q++ {
+		for r := max(rmin, -q-smax); r <= min(rmax, -q-smin); r++ {
+			results = append(results, NewHex(q, r, -q-r))
+		}
+	}
+	return results
+}
+
 var hex_directions = []Hex{
 	NewHex(1, 0, -1),
 	NewHex(1, -1, 0),
@@ -26,7 +26,11 @@ package hexes
 
 // this file implements the tests from https://www.redblobgames.com/grids/hexagons/codegen/output/lib.cpp
 
-import "testing"
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
 
 func TestHexArithmetic(t *testing.T) {
 	a := NewHex(4, -10, 6)
@@ -114,6 +118,17 @@ func TestLayout(t *testing.T) {
 	if !h.Equals(pointy.PixelToHex(pointy.CenterPoint(h)).Round()) {
 		t.Error("layout")
 	}
+
+	// a point just inside a corner of the hex is still in the hex
+	for _, l := range []Layout{flat, pointy} {
+		center := l.CenterPoint(h)
+		for i, corner := range l.PolygonCorners(h) {
+			x, y := center.x+(corner.x-center.x)*0.9, center.y+(corner.y-center.y)*0.9
+			if !h.Equals(l.CoordToHex(int(math.Round(x)), int(math.Round(y)))) {
+				t.Errorf("coord_to_hex corner %d", i)
+			}
+		}
+	}
 }
 
 func TestOffsetRoundtrip(t *testing.T) {
@@ -212,6 +227,286 @@ func TestDoubledToCube(t *testing.T) {
 	}
 }
 
+func TestExportedConversions(t *testing.T) {
+	for _, h := range NewHex(0, 0, 0).Range(3) {
+		for _, offset := range []OFFSET{EVEN, ODD} {
+			if col, row := QOffsetFromCube(h, offset).Coords(); !h.Equals(QOffsetToCube(col, row, offset)) {
+				t.Error("conversion_from_to q-offset")
+			}
+			if col, row := ROffsetFromCube(h, offset).Coords(); !h.Equals(ROffsetToCube(col, row, offset)) {
+				t.Error("conversion_from_to r-offset")
+			}
+		}
+		if col, row := QDoubledFromCube(h).Coords(); !h.Equals(QDoubledToCube(col, row)) {
+			t.Error("conversion_from_to doubled-q")
+		}
+		if col, row := RDoubledFromCube(h).Coords(); !h.Equals(RDoubledToCube(col, row)) {
+			t.Error("conversion_from_to doubled-r")
+		}
+	}
+
+	if !NewOffsetCoord(1, 3).Equals(QOffsetFromCube(NewHex(1, 2, -3), EVEN)) {
+		t.Error("offset_from_cube even-q")
+	}
+	if !NewOffsetCoord(1, 2).Equals(QOffsetFromCube(NewHex(1, 2, -3), ODD)) {
+		t.Error("offset_from_cube odd-q")
+	}
+	if !NewOffsetCoord(3, 3).Equals(ROffsetFromCube(NewHex(1, 3, -4), EVEN)) {
+		t.Error("offset_from_cube even-r")
+	}
+	if !NewOffsetCoord(2, 3).Equals(ROffsetFromCube(NewHex(1, 3, -4), ODD)) {
+		t.Error("offset_from_cube odd-r")
+	}
+	equal_hex(t, "offset_to_cube even-r", NewHex(1, 3, -4), ROffsetToCube(3, 3, EVEN))
+	equal_hex(t, "offset_to_cube odd-r", NewHex(1, 3, -4), ROffsetToCube(2, 3, ODD))
+	if !NewDoubledCoord(1, 5).Equals(QDoubledFromCube(NewHex(1, 2, -3))) {
+		t.Error("doubled_from_cube doubled-q")
+	}
+	if !NewDoubledCoord(4, 2).Equals(RDoubledFromCube(NewHex(1, 2, -3))) {
+		t.Error("doubled_from_cube doubled-r")
+	}
+	equal_hex(t, "doubled_to_cube doubled-q", NewHex(1, 2, -3), QDoubledToCube(1, 5))
+	equal_hex(t, "doubled_to_cube doubled-r", NewHex(1, 2, -3), RDoubledToCube(4, 2))
+}
+
+func TestHexRange(t *testing.T) {
+	equal_hex_array(t, "hex_range 1", []Hex{
+		NewHex(-1, 0, 1),
+		NewHex(-1, 1, 0),
+		NewHex(0, -1, 1),
+		NewHex(0, 0, 0),
+		NewHex(0, 1, -1),
+		NewHex(1, -1, 0),
+		NewHex(1, 0, -1)}, NewHex(0, 0, 0).Range(1))
+
+	center := NewHex(1, -3, 2)
+	for n := 0; n <= 4; n++ {
+		results := center.Range(n)
+		if len(results) != 3*n*(n+1)+1 {
+			t.Error("hex_range count")
+		}
+		for _, h := range results {
+			if h.Distance(center) > n {
+				t.Error("hex_range distance")
+			}
+		}
+	}
+}
+
+func TestHexRing(t *testing.T) {
+	equal_hex_array(t, "hex_ring 1", []Hex{
+		NewHex(-1, 1, 0),
+		NewHex(0, 1, -1),
+		NewHex(1, 0, -1),
+		NewHex(1, -1, 0),
+		NewHex(0, -1, 1),
+		NewHex(-1, 0, 1)}, NewHex(0, 0, 0).Ring(1))
+	equal_hex_array(t, "hex_ring 0", []Hex{NewHex(1, -3, 2)}, NewHex(1, -3, 2).Ring(0))
+
+	center := NewHex(1, -3, 2)
+	results := center.Ring(3)
+	if len(results) != 18 {
+		t.Error("hex_ring count")
+	}
+	for i, h := range results {
+		if h.Distance(center) != 3 {
+			t.Error("hex_ring distance")
+		}
+		if h.Distance(results[(i+1)%len(results)]) != 1 {
+			t.Error("hex_ring neighbors")
+		}
+	}
+}
+
+func TestHexSpiral(t *testing.T) {
+	center := NewHex(1, -3, 2)
+	spiral, inRange := center.Spiral(3), make(map[Hex]bool)
+	for _, h := range center.Range(3) {
+		inRange[h] = true
+	}
+	if len(spiral) != len(inRange) {
+		t.Error("hex_spiral count")
+	}
+	for i, h := range spiral {
+		if !inRange[h] {
+			t.Error("hex_spiral range")
+		}
+		inRange[h] = false
+		if i > 0 && h.Distance(center) < spiral[i-1].Distance(center) {
+			t.Error("hex_spiral order")
+		}
+	}
+	equal_hex(t, "hex_spiral center", center, spiral[0])
+}
+
+func TestHexIntersect(t *testing.T) {
+	equal_hex_array(t, "hex_intersect 1", []Hex{NewHex(1, 0, -1)}, Intersect(NewHex(0, 0, 0), 1, NewHex(2, 0, -2), 1))
+	if len(Intersect(NewHex(0, 0, 0), 1, NewHex(3, 0, -3), 1)) != 0 {
+		t.Error("hex_intersect disjoint")
+	}
+
+	// the intersection is the hexes in the first range that are close enough to the second
+	a, b := NewHex(1, -3, 2), NewHex(3, -1, -2)
+	var want []Hex
+	for _, h := range a.Range(3) {
+		if h.Distance(b) <= 2 {
+			want = append(want, h)
+		}
+	}
+	equal_hex_array(t, "hex_intersect 2", want, Intersect(a, 3, b, 2))
+}
+
+func TestHexReflect(t *testing.T) {
+	a := NewHex(1, -3, 2)
+	equal_hex(t, "hex_reflect_q", NewHex(1, 2, -3), a.ReflectQ())
+	equal_hex(t, "hex_reflect_r", NewHex(2, -3, 1), a.ReflectR())
+	equal_hex(t, "hex_reflect_s", NewHex(-3, 1, 2), a.ReflectS())
+	equal_hex(t, "hex_reflect_q twice", a, a.ReflectQ().ReflectQ())
+}
+
+func TestHexRotateAround(t *testing.T) {
+	a, origin := NewHex(1, -3, 2), NewHex(0, 0, 0)
+	equal_hex(t, "hex_rotate_around right", a.RotateRight(), a.RotateAround(origin, 1))
+	equal_hex(t, "hex_rotate_around left", a.RotateLeft(), a.RotateAround(origin, -1))
+	equal_hex(t, "hex_rotate_around full", a, a.RotateAround(origin, 6))
+	equal_hex(t, "hex_rotate_around center", NewHex(1, 1, -2), NewHex(2, 0, -2).RotateAround(NewHex(1, 0, -1), 1))
+	equal_hex(t, "hex_rotate_around half", NewHex(-1, 1, 0), NewHex(3, -1, -2).RotateAround(NewHex(1, 0, -1), 3))
+}
+
+func TestLineOfSight(t *testing.T) {
+	origin := NewHex(0, 0, 0)
+	wall := map[Hex]bool{NewHex(1, 0, -1): true}
+	blocked := func(h Hex) bool { return wall[h] }
+	if origin.LineOfSight(NewHex(2, 0, -2), blocked) {
+		t.Error("line_of_sight behind wall")
+	}
+	if !origin.LineOfSight(NewHex(1, 0, -1), blocked) {
+		t.Error("line_of_sight wall")
+	}
+	// the line to (1, 1, -2) runs between (1, 0, -1) and (0, 1, -1)
+	if !origin.LineOfSight(NewHex(1, 1, -2), blocked) {
+		t.Error("line_of_sight past wall")
+	}
+	wall[NewHex(0, 1, -1)] = true
+	if origin.LineOfSight(NewHex(1, 1, -2), blocked) {
+		t.Error("line_of_sight between walls")
+	}
+
+	// property: sight is symmetric
+	rnd := rand.New(rand.NewSource(45))
+	for trial := 0; trial < 50; trial++ {
+		wall := randomWalls(rnd, origin, 6, 0.3)
+		blocked := func(h Hex) bool { return wall[h] }
+		hexes := origin.Range(6)
+		for i := 0; i < 100; i++ {
+			a, b := hexes[rnd.Intn(len(hexes))], hexes[rnd.Intn(len(hexes))]
+			if a.LineOfSight(b, blocked) != b.LineOfSight(a, blocked) {
+				t.Errorf("line_of_sight symmetry %v %v", a, b)
+			}
+		}
+	}
+}
+
+func TestFieldOfView(t *testing.T) {
+	origin := NewHex(0, 0, 0)
+	open := func(h Hex) bool { return false }
+	if fov := origin.FieldOfView(3, open); len(fov) != len(origin.Range(3)) {
+		t.Error("field_of_view open")
+	}
+	if fov := origin.FieldOfView(0, open); len(fov) != 1 || !fov[origin] {
+		t.Error("field_of_view radius 0")
+	}
+
+	wall := map[Hex]bool{NewHex(1, 0, -1): true}
+	blocked := func(h Hex) bool { return wall[h] }
+	fov := origin.FieldOfView(3, blocked)
+	if !fov[NewHex(1, 0, -1)] || fov[NewHex(2, 0, -2)] || fov[NewHex(3, 0, -3)] {
+		t.Error("field_of_view behind wall")
+	}
+	if !fov[NewHex(1, 1, -2)] {
+		t.Error("field_of_view past wall")
+	}
+	wall[NewHex(0, 1, -1)] = true
+	if fov := origin.FieldOfView(3, blocked); fov[NewHex(1, 1, -2)] {
+		t.Error("field_of_view between walls")
+	}
+
+	// properties: the field of view is in range, and sight is symmetric
+	rnd := rand.New(rand.NewSource(45))
+	for trial := 0; trial < 50; trial++ {
+		const radius = 5
+		wall := randomWalls(rnd, origin, 2*radius, 0.25)
+		blocked := func(h Hex) bool { return wall[h] }
+		fovs := make(map[Hex]map[Hex]bool)
+		for _, a := range origin.Range(radius) {
+			fovs[a] = a.FieldOfView(radius, blocked)
+			for b := range fovs[a] {
+				if a.Distance(b) > radius {
+					t.Errorf("field_of_view range %v %v", a, b)
+				}
+			}
+		}
+		for a, fov := range fovs {
+			for b, seen := range fovs {
+				if a.Distance(b) <= radius && fov[b] != seen[a] {
+					t.Errorf("field_of_view symmetry %v %v: %v %v", a, b, fov[b], seen[a])
+				}
+			}
+		}
+	}
+}
+
+func TestGrid(t *testing.T) {
+	g := NewGrid()
+	a := NewHex(1, -3, 2)
+	g.Set(a, "a")
+	g.Set(NewHex(0, 0, 0), nil)
+	if v, ok := g.Lookup(a); !ok || v != "a" || g.Get(a) != "a" {
+		t.Error("grid_get")
+	}
+	if !g.Has(NewHex(0, 0, 0)) || g.Get(NewHex(0, 0, 0)) != nil || g.Has(NewHex(1, 0, -1)) {
+		t.Error("grid_has")
+	}
+	g.Delete(a)
+	if g.Has(a) || g.Len() != 1 {
+		t.Error("grid_delete")
+	}
+
+	if HexagonGrid(a, 2).Len() != 19 || ParallelogramGrid(-1, 1, 0, 3).Len() != 12 || TriangleGrid(a, 4).Len() != 10 {
+		t.Error("grid_shapes")
+	}
+	for _, layout := range []HEXLAYOUT{FLATHEX, POINTYHEX} {
+		for _, offset := range []OFFSET{EVEN, ODD} {
+			if RectangleGrid(5, 3, layout, offset).Len() != 15 {
+				t.Error("grid_rectangle")
+			}
+		}
+	}
+
+	hexagon := HexagonGrid(NewHex(0, 0, 0), 1)
+	equal_hex_array(t, "grid_hexes", NewHex(0, 0, 0).Range(1), hexagon.Hexes())
+	equal_hex_array(t, "grid_neighbors center", []Hex{
+		NewHex(1, 0, -1),
+		NewHex(1, -1, 0),
+		NewHex(0, -1, 1),
+		NewHex(-1, 0, 1),
+		NewHex(-1, 1, 0),
+		NewHex(0, 1, -1)}, hexagon.Neighbors(NewHex(0, 0, 0)))
+	equal_hex_array(t, "grid_neighbors edge", []Hex{
+		NewHex(0, -1, 1),
+		NewHex(0, 0, 0),
+		NewHex(1, 0, -1)}, hexagon.Neighbors(NewHex(1, -1, 0)))
+
+	if b := TriangleGrid(a, 4).Bounds(); b != (Bounds{QMin: 1, QMax: 4, RMin: -3, RMax: 0, SMin: -1, SMax: 2}) {
+		t.Errorf("grid_bounds %+v", b)
+	} else if !b.Contains(a) || b.Contains(NewHex(0, 0, 0)) {
+		t.Error("grid_bounds contains")
+	}
+	if (NewGrid().Bounds() != Bounds{}) {
+		t.Error("grid_bounds empty")
+	}
+}
+
 ////////////////////////////////////////////////////
 // helper functions for testing
 ////////////////////////////////////////////////////
@@ -232,3 +527,14 @@ func equal_hex_array(t *testing.T, name string, a, b []Hex) {
 		}
 	}
 }
+
+// randomWalls blocks hexes within radius of center with probability p.
+func randomWalls(rnd *rand.Rand, center Hex, radius int, p float64) map[Hex]bool {
+	wall := make(map[Hex]bool)
+	for _, h := range center.Range(radius) {
+		if rnd.Float64() < p {
+			wall[h] = true
+		}
+	}
+	return wall
+}
@@ -61,19 +61,9 @@ func (l Layout) CenterPoint(h Hex) Point {
 	return NewPoint(x+origin.x, y+origin.y)
 }
 
-// CoordToHex converts an x, y coordinate to a fractional hex on the map.
+// CoordToHex returns the hex that holds an x, y coordinate on the screen.
 func (l Layout) CoordToHex(x, y int) Hex {
-	p := NewPoint(float64(x), float64(y))
-	M := l.orientation
-	size := NewPoint(1.0, 1.0)
-	origin := l.origin
-
-	pt := NewPoint((p.x-origin.x)/size.x, (p.y-origin.y)/size.y)
-
-	q := M.b0*pt.x + M.b1*pt.y
-	r := M.b2*pt.x + M.b3*pt.y
-
-	return NewFractionalHex(q, r, -q-r).Round()
+	return l.PixelToHex(NewPoint(float64(x), float64(y))).Round()
 }
 
 // HexCornerOffset returns the offset of a hex corner from the center of the hex.
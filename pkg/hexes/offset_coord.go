@@ -47,10 +47,28 @@ func (oc OffsetCoord) Coords() (x, y int) {
 	return oc.col, oc.row
 }
 
+// QOffsetFromCube converts cube coordinates to the offset coordinates of
+// flat hexes, where the EVEN or ODD columns are pushed down by half a hex.
+func QOffsetFromCube(h Hex, offset OFFSET) OffsetCoord {
+	return qoffset_from_cube(offset, h)
+}
+
+// QOffsetToCube converts the offset coordinates of flat hexes to cube coordinates.
 func QOffsetToCube(col, row int, offset OFFSET) Hex {
 	return qoffset_to_cube(offset, OffsetCoord{col: col, row: row})
 }
 
+// ROffsetFromCube converts cube coordinates to the offset coordinates of
+// pointy hexes, where the EVEN or ODD rows are pushed right by half a hex.
+func ROffsetFromCube(h Hex, offset OFFSET) OffsetCoord {
+	return roffset_from_cube(offset, h)
+}
+
+// ROffsetToCube converts the offset coordinates of pointy hexes to cube coordinates.
+func ROffsetToCube(col, row int, offset OFFSET) Hex {
+	return roffset_to_cube(offset, OffsetCoord{col: col, row: row})
+}
+
 func qoffset_from_cube(offset OFFSET, h Hex) OffsetCoord {
 	col := h.q
 	row := h.r + (h.q+int(offset)*(h.q&1))/2
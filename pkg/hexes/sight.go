@@ -0,0 +1,132 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package hexes
+
+import "math"
+
+// LineOfSight reports whether a can see b. The hexes between them are
+// found with LineDraw, nudged first to one side of the line and then to
+// the other; a can see b if either line has no blocked hexes between the
+// ends. The ends themselves can be blocked, so a wall can be seen.
+//
+// It is symmetric: a can see b exactly when b can see a.
+func (h Hex) LineOfSight(b Hex, blocked func(Hex) bool) bool {
+	for _, nudge := range []float64{1e-06, -1e-06} {
+		line, clear := h.lineDraw(b, nudge), true
+		for i := 1; clear && i < len(line)-1; i++ {
+			clear = !blocked(line[i])
+		}
+		if clear {
+			return true
+		}
+	}
+	return false
+}
+
+// lineDraw is LineDraw with the ends nudged by the given amount.
+// Nudging both ends the same way keeps the line the same in both directions,
+// and nudging q, r and s by different amounts keeps Round from meeting a tie
+// that rounding errors could break differently in each direction.
+func (h Hex) lineDraw(b Hex, nudge float64) (results []Hex) {
+	N := h.Distance(b)
+
+	a_nudge := NewFractionalHex(float64(h.q)+nudge, float64(h.r)+2*nudge, float64(h.s)-3*nudge)
+	b_nudge := NewFractionalHex(float64(b.q)+nudge, float64(b.r)+2*nudge, float64(b.s)-3*nudge)
+	step := 1.0 / math.Max(float64(N), 1.0)
+
+	for i := 0; i <= N; i++ {
+		results = append(results, a_nudge.Lerp(b_nudge, step*float64(i)).Round())
+	}
+
+	return results
+}
+
+// FieldOfView returns the hexes within radius steps of h that h can see,
+// including h itself.
+//
+// It casts shadows ring by ring, out from the center. Each blocked hex
+// casts a shadow as wide as the circle that fits inside it, and a hex is
+// hidden when the line to its center passes through a shadow. A line that
+// just touches a shadow isn't blocked unless it runs along the seam between
+// two shadows, so the center can't see between two blocked hexes that touch.
+// Blocked hexes can be seen, but nothing behind them, and the center can
+// see out even if it is blocked.
+//
+// It is symmetric: within the radius, a can see b exactly when b can see a.
+func (h Hex) FieldOfView(radius int, blocked func(Hex) bool) map[Hex]bool {
+	const epsilon = 1e-9
+	type shadow struct {
+		angle, width float64 // center and half width, in radians
+	}
+	var shadows []shadow
+
+	// angle returns the direction and distance from h to a hex,
+	// with the distance between the centers of neighbors being 1
+	angle := func(b Hex) (float64, float64) {
+		d := b.Subtract(h)
+		x, y := float64(d.q)+float64(d.r)/2, float64(d.r)*math.Sqrt(3)/2
+		return math.Atan2(y, x), math.Hypot(x, y)
+	}
+	// diff returns a-b in the range -pi to pi
+	diff := func(a, b float64) float64 {
+		d := math.Mod(a-b, 2*math.Pi)
+		if d > math.Pi {
+			d -= 2 * math.Pi
+		} else if d <= -math.Pi {
+			d += 2 * math.Pi
+		}
+		return d
+	}
+
+	visible := map[Hex]bool{h: true}
+	for k := 1; k <= radius; k++ {
+		var casts []shadow
+		for _, b := range h.Ring(k) {
+			theta, distance := angle(b)
+			hidden, lower, upper := false, false, false
+			for _, s := range shadows {
+				d := diff(theta, s.angle)
+				if math.Abs(d) < s.width-epsilon {
+					hidden = true
+					break
+				}
+				lower = lower || math.Abs(d+s.width) <= epsilon
+				upper = upper || math.Abs(d-s.width) <= epsilon
+			}
+			if !hidden && !(lower && upper) {
+				visible[b] = true
+			}
+			// hidden hexes cast shadows too; the line to a hex behind
+			// one might pass through it without passing through the
+			// shadow that hides it
+			if blocked(b) {
+				casts = append(casts, shadow{angle: theta, width: math.Asin(0.5 / distance)})
+			}
+		}
+		// shadows cast by a ring only fall on the rings behind it
+		shadows = append(shadows, casts...)
+	}
+	return visible
+}
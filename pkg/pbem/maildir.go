@@ -0,0 +1,119 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package pbem
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Maildir is a mailbox in the Maildir format.
+// New messages are read from new/ and moved to cur/ once they are handled.
+// See https://cr.yp.to/proto/maildir.html for details on the format.
+type Maildir struct {
+	path string
+}
+
+// NewMaildir returns a Maildir, creating the directories if needed.
+func NewMaildir(path string) (*Maildir, error) {
+	for _, dir := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0700); err != nil {
+			return nil, err
+		}
+	}
+	return &Maildir{path: path}, nil
+}
+
+// Message is a message read from a mailbox.
+type Message struct {
+	ID  string // file name in the mailbox
+	Msg *mail.Message
+}
+
+// Unread returns the messages in new/, oldest first.
+// Messages that can't be parsed are moved to cur/ and skipped.
+func (md *Maildir) Unread() ([]*Message, error) {
+	entries, err := os.ReadDir(filepath.Join(md.path, "new"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	// maildir names start with the delivery time, so sorting puts them in order
+	sort.Strings(names)
+
+	var messages []*Message
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(md.path, "new", name))
+		if err != nil {
+			return nil, err
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			if err := md.MarkRead(name); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		messages = append(messages, &Message{ID: name, Msg: msg})
+	}
+	return messages, nil
+}
+
+// MarkRead moves a message from new/ to cur/ and flags it as seen.
+func (md *Maildir) MarkRead(id string) error {
+	return os.Rename(filepath.Join(md.path, "new", id), filepath.Join(md.path, "cur", id+":2,S"))
+}
+
+// Deliver writes a message into new/ using the tmp/ then rename dance.
+func (md *Maildir) Deliver(data []byte) error {
+	name := uniqueName()
+	tmp := filepath.Join(md.path, "tmp", name)
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(md.path, "new", name))
+}
+
+var deliveries int64
+
+// uniqueName returns a file name for a new message.
+func uniqueName() string {
+	host, _ := os.Hostname()
+	host = strings.NewReplacer("/", "_", ":", "_").Replace(host)
+	now := time.Now()
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), atomic.AddInt64(&deliveries, 1), host)
+}
@@ -0,0 +1,296 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package pbem implements the play-by-email gateway.
+//
+// The gateway polls a Maildir for messages containing orders, matches the
+// sender's address and secret token to a player, saves the orders and
+// replies with an acknowledgement. It also sends turn reports to players.
+//
+// Reading directly from an IMAP server isn't supported. Use a tool like
+// fetchmail or getmail to deliver from the IMAP server into a Maildir.
+package pbem
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/mdhender/wow/pkg/game"
+	"github.com/mdhender/wow/pkg/store"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// errTemporary marks a message that couldn't be handled for a reason
+// that may pass, like a store error. It is tried again on the next poll.
+var errTemporary = errors.New("temporary failure")
+
+// Gateway connects a mailbox and a mail sender to the game engine.
+type Gateway struct {
+	store   store.Store
+	engine  *game.Engine
+	mailbox *Maildir
+	sender  Sender
+	from    string // address that replies and reports are sent from
+}
+
+// New returns a gateway.
+func New(st store.Store, engine *game.Engine, mailbox *Maildir, sender Sender, from string) *Gateway {
+	return &Gateway{store: st, engine: engine, mailbox: mailbox, sender: sender, from: from}
+}
+
+// Run polls the mailbox until the context is cancelled.
+func (gw *Gateway) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := gw.Poll(); err != nil {
+			log.Printf("[pbem] poll: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll handles all the unread messages in the mailbox.
+// Each message is marked as read once it has been handled or rejected.
+// Messages that fail for a reason that may pass are left unread.
+func (gw *Gateway) Poll() error {
+	messages, err := gw.mailbox.Unread()
+	if err != nil {
+		return err
+	}
+	for _, msg := range messages {
+		if err := gw.handle(msg.Msg); errors.Is(err, errTemporary) {
+			log.Printf("[pbem] %s: %v, will try again\n", msg.ID, err)
+			continue
+		} else if err != nil {
+			log.Printf("[pbem] %s: %v\n", msg.ID, err)
+		}
+		if err := gw.mailbox.MarkRead(msg.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle saves the orders in a message and sends the reply.
+// Messages from unknown senders are dropped without a reply
+// so that the gateway doesn't send mail to forged addresses.
+// The orders must carry the player's secret token, since the sender's
+// address is easy to forge; the token also picks the game for players
+// who are in more than one.
+func (gw *Gateway) handle(msg *mail.Message) error {
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	reply := "Re: " + strings.TrimPrefix(subject, "Re: ")
+
+	candidates, err := gw.playersFor(from.Address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errTemporary, err)
+	} else if len(candidates) == 0 {
+		return fmt.Errorf("%s: not a player", from.Address)
+	}
+
+	text, err := plainText(msg)
+	if err != nil {
+		return gw.sender.Send(gw.from, from.Address, reply, fmt.Sprintf("Sorry, but the orders could not be read: %v\n", err))
+	}
+	token, text := splitToken(text)
+	var g *store.Game
+	var p *store.Player
+	for _, c := range candidates {
+		if game.CheckToken(token, c.player.Token) {
+			g, p = c.game, c.player
+			break
+		}
+	}
+	if g == nil {
+		return gw.sender.Send(gw.from, from.Address, reply,
+			"Sorry, but the orders were not accepted: they must include a line \"token YOUR-TOKEN\" with the token you got when you joined the game.\n")
+	}
+
+	o, err := gw.engine.SubmitOrders(g.ID, p.ID, text)
+	if errors.Is(err, game.ErrInvalidOrders) {
+		return gw.sender.Send(gw.from, from.Address, reply, fmt.Sprintf("Sorry, but the orders were not accepted: %v\n\n%s\n", err, text))
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", errTemporary, err)
+	}
+	return gw.sender.Send(gw.from, from.Address, reply,
+		fmt.Sprintf("Orders for %s turn %d received. They replace any orders sent earlier this turn.\n\n%s\n", g.Name, o.Turn, o.Text))
+}
+
+// splitToken removes the line holding the player's token, like
+// "token 0123abcd" or "Token: 0123abcd", from the orders.
+func splitToken(text string) (token, orders string) {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if fields := strings.Fields(line); token == "" && len(fields) == 2 && strings.EqualFold(strings.TrimSuffix(fields[0], ":"), "token") {
+			token = fields[1]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return token, strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+type candidate struct {
+	game   *store.Game
+	player *store.Player
+}
+
+// playersFor returns the players with the given email address.
+func (gw *Gateway) playersFor(address string) ([]candidate, error) {
+	games, err := gw.store.ListGames()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []candidate
+	for _, g := range games {
+		players, err := gw.store.ListPlayers(g.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range players {
+			if p.Email != "" && strings.EqualFold(p.Email, address) {
+				candidates = append(candidates, candidate{game: g, player: p})
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// DeliverReports sends the reports for a turn to every player with an email address.
+func (gw *Gateway) DeliverReports(gameID string, turn int) error {
+	g, err := gw.store.GetGame(gameID)
+	if err != nil {
+		return err
+	}
+	players, err := gw.store.ListPlayers(g.ID)
+	if err != nil {
+		return err
+	}
+	for _, p := range players {
+		if p.Email == "" {
+			continue
+		}
+		r, err := gw.store.GetReport(g.ID, p.ID, turn)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.ID, err)
+		}
+		subject := fmt.Sprintf("%s (%s): turn %d report", g.Name, g.ID, turn)
+		if err := gw.sender.Send(gw.from, p.Email, subject, r.Text); err != nil {
+			return fmt.Errorf("%s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// Remind asks players who haven't sent orders yet to send them before the deadline.
+func (gw *Gateway) Remind(g *store.Game, players []*store.Player) error {
+	for _, p := range players {
+		if p.Email == "" {
+			continue
+		}
+		subject := fmt.Sprintf("%s (%s): turn %d orders due", g.Name, g.ID, g.Turn)
+		body := fmt.Sprintf("We haven't received your orders for turn %d yet.\nThe turn will be processed at %s.\nPlayers without orders will do nothing this turn.\n",
+			g.Turn, g.Deadline.UTC().Format("Mon Jan 2 15:04 MST"))
+		if err := gw.sender.Send(gw.from, p.Email, subject, body); err != nil {
+			return fmt.Errorf("%s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// plainText returns the orders from the body of the message.
+// It uses the first text/plain part, and drops quoted lines and signatures
+// so that players can reply to an acknowledgement to change their orders.
+func plainText(msg *mail.Message) (string, error) {
+	body, err := textPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		if line == "-- " || line == "--" {
+			break // signature
+		} else if strings.HasPrefix(line, ">") {
+			continue // quoted
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), nil
+}
+
+// textPart decodes the body, searching multipart messages for the first text/plain part.
+func textPart(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if contentType == "" || err != nil {
+		mediaType = "text/plain"
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return "", fmt.Errorf("no text/plain part")
+			} else if err != nil {
+				return "", err
+			}
+			if text, err := textPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part); err == nil {
+				return text, nil
+			}
+		}
+	case mediaType != "text/plain":
+		return "", fmt.Errorf("unsupported content type %q", mediaType)
+	}
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
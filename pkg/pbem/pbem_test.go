@@ -0,0 +1,203 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package pbem
+
+import (
+	"errors"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/game"
+	"github.com/mdhender/wow/pkg/store"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGateway(t *testing.T) {
+	tmp := t.TempDir()
+	st, err := store.NewFileStore(filepath.Join(tmp, "data"))
+	if err != nil {
+		t.Fatal(err)
+	} else if err := st.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := game.EncodeMap([]board.Node{
+		{Name: "Ur", Col: 1, Row: 1, EconValue: 4, Warps: []string{"Adab"}},
+		{Name: "Adab", Col: 3, Row: 2, EconValue: 1, Warps: []string{"Kish"}},
+		{Name: "Kish", Col: 5, Row: 3, EconValue: 0, Warps: []string{"Susa"}},
+		{Name: "Susa", Col: 7, Row: 4, EconValue: 3},
+	})
+	m := &store.Map{Name: "line", Data: data}
+	if err := st.CreateMap(m); err != nil {
+		t.Fatal(err)
+	}
+	engine := game.New(st)
+	g, _, err := engine.CreateGame("Sumer", m.ID, "", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, token, err := engine.Join(g.ID, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inbox, err := NewMaildir(filepath.Join(tmp, "inbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := NewMaildir(filepath.Join(tmp, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := New(st, engine, inbox, &MaildirSender{Maildir: outbox}, "gm@example.com")
+
+	// orders from a stranger are dropped without a reply, and orders
+	// from alice's address without her token are rejected
+	_ = inbox.Deliver([]byte("From: mallory@example.com\r\nSubject: orders\r\n\r\nclaim Adab\r\n"))
+	_ = inbox.Deliver([]byte("From: alice@example.com\r\nSubject: orders\r\n\r\ntoken 1234\r\nclaim Adab\r\n"))
+	if err := gw.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetOrders(g.ID, alice.ID, 1); err == nil {
+		t.Errorf("orders: want none without the token")
+	}
+	sent, err := outbox.Unread()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sent) != 1 || !strings.Contains(sent[0].Msg.Header.Get("Subject"), "orders") {
+		t.Fatalf("outbox: want 1 rejection, got %d", len(sent))
+	}
+	_ = outbox.MarkRead(sent[0].ID)
+
+	// alice sends quoted-printable orders with a quoted reply and a signature
+	_ = inbox.Deliver([]byte("From: Alice <ALICE@example.com>\r\n" +
+		"Subject: Sumer orders\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Token: " + token + "\r\n" +
+		"claim =\r\nAdab\r\n" +
+		"> claim Kish\r\n" +
+		"-- \r\n" +
+		"alice\r\n"))
+	if err := gw.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if unread, err := inbox.Unread(); err != nil {
+		t.Fatal(err)
+	} else if len(unread) != 0 {
+		t.Errorf("inbox: want 0 unread, got %d", len(unread))
+	}
+	o, err := st.GetOrders(g.ID, alice.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if o.Text != "claim Adab" {
+		t.Errorf("orders: want %q, got %q", "claim Adab", o.Text)
+	}
+	sent, err = outbox.Unread()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sent) != 1 {
+		t.Fatalf("outbox: want 1 message, got %d", len(sent))
+	} else if to := sent[0].Msg.Header.Get("To"); to != "ALICE@example.com" {
+		t.Errorf("ack: to: want %q, got %q", "ALICE@example.com", to)
+	}
+	for _, msg := range sent {
+		_ = outbox.MarkRead(msg.ID)
+	}
+
+	// process the turn and mail the reports
+	if _, err := engine.ProcessTurn(g.ID, 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.DeliverReports(g.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	sent, err = outbox.Unread()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(sent) != 1 {
+		t.Fatalf("outbox: want 1 report, got %d", len(sent))
+	}
+	body, err := plainText(sent[0].Msg)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(body, "claim Adab: ok") {
+		t.Errorf("report: want claim result in\n%s", body)
+	}
+}
+
+// failingStore is a store that can't list its games.
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) ListGames() ([]*store.Game, error) {
+	return nil, errors.New("database is locked")
+}
+
+func TestGatewayRetry(t *testing.T) {
+	tmp := t.TempDir()
+	st, err := store.NewFileStore(filepath.Join(tmp, "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inbox, err := NewMaildir(filepath.Join(tmp, "inbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := NewMaildir(filepath.Join(tmp, "outbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := New(failingStore{st}, game.New(st), inbox, &MaildirSender{Maildir: outbox}, "gm@example.com")
+	_ = inbox.Deliver([]byte("From: alice@example.com\r\nSubject: orders\r\n\r\nclaim Adab\r\n"))
+	if err := gw.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if unread, err := inbox.Unread(); err != nil {
+		t.Fatal(err)
+	} else if len(unread) != 1 {
+		t.Errorf("inbox: want the message left unread, got %d unread", len(unread))
+	}
+	if sent, _ := outbox.Unread(); len(sent) != 0 {
+		t.Errorf("outbox: want no reply, got %d", len(sent))
+	}
+}
+
+func TestComposeRejectsLineBreaks(t *testing.T) {
+	if _, err := compose("gm@example.com", "alice@example.com", "report", "hello\n"); err != nil {
+		t.Errorf("plain address: want nil, got %v", err)
+	}
+	for _, to := range []string{"alice@example.com\r\nBcc: eve@example.com", "alice@example.com\nBcc: eve@example.com"} {
+		if _, err := compose("gm@example.com", to, "report", "hello\n"); err == nil {
+			t.Errorf("%q: want error, got nil", to)
+		}
+	}
+	if err := (&MaildirSender{}).Send("gm@example.com\r\nBcc: eve@example.com", "alice@example.com", "report", "hello\n"); err == nil {
+		t.Errorf("maildir sender: want error, got nil")
+	}
+}
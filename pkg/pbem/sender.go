@@ -0,0 +1,102 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package pbem
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Sender sends a plain text message.
+type Sender interface {
+	Send(from, to, subject, body string) error
+}
+
+// SMTPSender sends messages through an SMTP server.
+type SMTPSender struct {
+	Addr     string // host:port of the server
+	Username string // optional; if set, PLAIN authentication is used
+	Password string
+}
+
+// Send implements the Sender interface.
+func (s *SMTPSender) Send(from, to, subject, body string) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	msg, err := compose(from, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, auth, from, []string{to}, msg)
+}
+
+// MaildirSender "sends" messages by delivering them to a Maildir.
+// It is a sink for testing, or for running without a mail server.
+type MaildirSender struct {
+	Maildir *Maildir
+}
+
+// Send implements the Sender interface.
+func (s *MaildirSender) Send(from, to, subject, body string) error {
+	msg, err := compose(from, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return s.Maildir.Deliver(msg)
+}
+
+// compose returns a plain text message with the headers needed by most mail servers.
+// The addresses are written as they are, so they must not hold line breaks
+// that would add headers of their own.
+func compose(from, to, subject, body string) ([]byte, error) {
+	if strings.ContainsAny(from, "\r\n") || strings.ContainsAny(to, "\r\n") {
+		return nil, fmt.Errorf("address contains a line break")
+	}
+	b := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(b, "From: %s\r\n", from)
+	_, _ = fmt.Fprintf(b, "To: %s\r\n", to)
+	_, _ = fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	_, _ = fmt.Fprintf(b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	_, _ = fmt.Fprintf(b, "Message-ID: <%s@wow>\r\n", uniqueName())
+	_, _ = fmt.Fprintf(b, "MIME-Version: 1.0\r\n")
+	_, _ = fmt.Fprintf(b, "Content-Type: text/plain; charset=utf-8\r\n")
+	_, _ = fmt.Fprintf(b, "Content-Transfer-Encoding: 8bit\r\n")
+	_, _ = fmt.Fprintf(b, "\r\n")
+	// normalize line endings to CRLF as required by SMTP
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	_, _ = b.WriteString(body)
+	return b.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"crypto/subtle"
+	"github.com/mdhender/wow/internal/way"
+	"github.com/mdhender/wow/pkg/game"
+	"net/http"
+	"strings"
+)
+
+// role is the part a caller plays in a request.
+type role int
+
+const (
+	// roleAdmin holds the admin token and may do anything.
+	roleAdmin role = iota
+	// roleGM holds the game master token for the game in the request path.
+	roleGM
+	// rolePlayer holds the token for the player in the request path.
+	rolePlayer
+)
+
+// WithAdminToken sets the token that grants the admin role.
+// If the token is empty, no caller can be an admin.
+func WithAdminToken(token string) Option {
+	return func(s *Server) error {
+		s.adminToken = token
+		return nil
+	}
+}
+
+// authorize wraps a handler so that it is only called if the caller
+// has one of the roles. Callers identify themselves with a bearer token.
+// It must be wrapped by the router so that the path parameters are set.
+func (s *Server) authorize(h http.HandlerFunc, roles ...role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wow"`)
+			jsonError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		for _, role := range roles {
+			if s.hasRole(r, token, role) {
+				h(w, r)
+				return
+			}
+		}
+		jsonError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+}
+
+// authorizeMap wraps a map handler so that a map used by a game is only
+// sent to the admin and to the game masters of the games that use it.
+// Players would otherwise see the whole map, past the fog of war.
+// Maps that no game uses are public.
+func (s *Server) authorizeMap(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		games, err := s.store.ListGames()
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		token, used := bearerToken(r), false
+		for _, g := range games {
+			if g.MapID == way.Param(r.Context(), "id") {
+				used = true
+				if token != "" && game.CheckToken(token, g.GMToken) {
+					h(w, r)
+					return
+				}
+			}
+		}
+		if !used || (token != "" && s.hasRole(r, token, roleAdmin)) {
+			h(w, r)
+			return
+		} else if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wow"`)
+			jsonError(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+			return
+		}
+		jsonError(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+	}
+}
+
+// hasRole reports whether the token grants the role for this request.
+func (s *Server) hasRole(r *http.Request, token string, role role) bool {
+	switch role {
+	case roleAdmin:
+		return s.adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) == 1
+	case roleGM:
+		g, err := s.store.GetGame(way.Param(r.Context(), "id"))
+		return err == nil && game.CheckToken(token, g.GMToken)
+	case rolePlayer:
+		p, err := s.store.GetPlayer(way.Param(r.Context(), "id"), way.Param(r.Context(), "pid"))
+		return err == nil && game.CheckToken(token, p.Token)
+	}
+	return false
+}
+
+// bearerToken returns the token from the Authorization header.
+func bearerToken(r *http.Request) string {
+	const prefix = "bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) < len(prefix) || strings.ToLower(header[:len(prefix)]) != prefix {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
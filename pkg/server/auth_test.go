@@ -0,0 +1,245 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mdhender/wow/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testAdminToken = "admin-secret"
+
+// newTestServer returns a server backed by a file store in a temporary directory.
+func newTestServer(t *testing.T) *Server {
+	st, err := store.NewFileStore(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	} else if err := st.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(WithStore(st), WithAdminToken(testAdminToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Routes(t.TempDir())
+	return s
+}
+
+// do sends a request to the server and returns the response.
+// If out is not nil, the data from an ok response is decoded into it.
+func do(t *testing.T, s *Server, method, path, token, contentType, body string, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), &okResponse{Data: out}); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return w
+}
+
+func TestAuthorize(t *testing.T) {
+	s := newTestServer(t)
+	mapData := `{"name": "line", "nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "econ-value": 4, "warps": ["Adab"]},
+		{"name": "Adab", "col": 3, "row": 2, "econ-value": 1, "warps": ["Kish"]},
+		{"name": "Kish", "col": 5, "row": 3, "econ-value": 0, "warps": ["Susa"]},
+		{"name": "Susa", "col": 7, "row": 4, "econ-value": 3}]}`
+
+	// only the admin may create maps and games
+	if w := do(t, s, "POST", "/wow/api/maps", "", "application/json", mapData, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("create map without token: want %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+	if w := do(t, s, "POST", "/wow/api/maps", "guess", "application/json", mapData, nil); w.Code != http.StatusForbidden {
+		t.Errorf("create map with bad token: want %d, got %d", http.StatusForbidden, w.Code)
+	}
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", mapData, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	var g gameResponse
+	if w := do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`"}`, &g); w.Code != http.StatusCreated {
+		t.Fatalf("create game: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	} else if g.GMToken == "" {
+		t.Fatalf("create game: want gm token, got none")
+	}
+
+	// anyone may join
+	var alice, bob playerResponse
+	if w := do(t, s, "POST", "/wow/api/games/"+g.ID+"/players", "", "application/json", `{"name": "Alice"}`, &alice); w.Code != http.StatusCreated {
+		t.Fatalf("join: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	if w := do(t, s, "POST", "/wow/api/games/"+g.ID+"/players", "", "application/json", `{"name": "Bob"}`, &bob); w.Code != http.StatusCreated {
+		t.Fatalf("join: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	// the email address goes into mail headers, so it must be an address
+	for _, email := range []string{"not an address", "carol@example.com\r\nBcc: eve@example.com"} {
+		body, _ := json.Marshal(map[string]string{"name": "Carol", "email": email})
+		if w := do(t, s, "POST", "/wow/api/games/"+g.ID+"/players", "", "application/json", string(body), nil); w.Code != http.StatusBadRequest {
+			t.Errorf("join as %q: want %d, got %d", email, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	// players may only submit their own orders
+	aliceOrders := "/wow/api/games/" + g.ID + "/players/" + alice.ID + "/orders"
+	for _, tc := range []struct {
+		who   string
+		token string
+		want  int
+	}{
+		{"bob", bob.Token, http.StatusForbidden},
+		{"gm", g.GMToken, http.StatusForbidden},
+		{"admin", testAdminToken, http.StatusForbidden},
+		{"alice", alice.Token, http.StatusOK},
+	} {
+		if w := do(t, s, "PUT", aliceOrders, tc.token, "text/plain", "claim Adab", nil); w.Code != tc.want {
+			t.Errorf("%s: put alice's orders: want %d, got %d", tc.who, tc.want, w.Code)
+		}
+	}
+	// content types may have parameters
+	for _, tc := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "claim Adab"},
+		{"application/json; charset=utf-8", `{"orders": "claim Adab"}`},
+	} {
+		if w := do(t, s, "PUT", aliceOrders, alice.Token, tc.contentType, tc.body, nil); w.Code != http.StatusOK {
+			t.Errorf("alice: put orders as %s: want %d, got %d: %s", tc.contentType, http.StatusOK, w.Code, w.Body)
+		}
+	}
+	if w := do(t, s, "PUT", aliceOrders, alice.Token, "text/html", "claim Adab", nil); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("alice: put orders as html: want %d, got %d", http.StatusUnsupportedMediaType, w.Code)
+	}
+	// but the game master may read them
+	if w := do(t, s, "GET", aliceOrders, g.GMToken, "", "", nil); w.Code != http.StatusOK {
+		t.Errorf("gm: get alice's orders: want %d, got %d", http.StatusOK, w.Code)
+	}
+
+	// only the game master or admin may run the turn
+	turn := "/wow/api/games/" + g.ID + "/turn"
+	if w := do(t, s, "POST", turn, alice.Token, "", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("alice: process turn: want %d, got %d", http.StatusForbidden, w.Code)
+	}
+	if w := do(t, s, "POST", turn, g.GMToken, "", "", &g); w.Code != http.StatusOK {
+		t.Errorf("gm: process turn: want %d, got %d", http.StatusOK, w.Code)
+	} else if g.Turn != 2 {
+		t.Errorf("gm: process turn: want turn 2, got %d", g.Turn)
+	}
+
+	// the game master token for one game doesn't work on another
+	var other gameResponse
+	do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "other", "map-id": "`+m.ID+`"}`, &other)
+	if w := do(t, s, "POST", "/wow/api/games/"+other.ID+"/turn", g.GMToken, "", "", nil); w.Code != http.StatusForbidden {
+		t.Errorf("gm: process other turn: want %d, got %d", http.StatusForbidden, w.Code)
+	}
+}
+
+func TestAuthorizeMap(t *testing.T) {
+	s := newTestServer(t)
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", `{"name": "line", "nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "econ-value": 4, "warps": ["Adab"]},
+		{"name": "Adab", "col": 3, "row": 2, "econ-value": 1}]}`, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	get, hit := "/wow/api/maps/"+m.ID, "/wow/api/maps/"+m.ID+"/hit?x=100&y=100"
+
+	// a map that no game uses is public
+	if w := do(t, s, "GET", get, "", "", "", nil); w.Code != http.StatusOK {
+		t.Errorf("unused map: want %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var g, other gameResponse
+	do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`"}`, &g)
+	do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "other", "map-id": "`+m.ID+`"}`, &other)
+	var alice playerResponse
+	do(t, s, "POST", "/wow/api/games/"+g.ID+"/players", "", "application/json", `{"name": "Alice"}`, &alice)
+	for _, tc := range []struct {
+		who   string
+		token string
+		want  int
+	}{
+		{"anyone", "", http.StatusUnauthorized},
+		{"alice", alice.Token, http.StatusForbidden},
+		{"gm", g.GMToken, http.StatusOK},
+		{"other gm", other.GMToken, http.StatusOK},
+		{"admin", testAdminToken, http.StatusOK},
+	} {
+		for _, path := range []string{get, hit} {
+			if w := do(t, s, "GET", path, tc.token, "", "", nil); w.Code != tc.want {
+				t.Errorf("%s: get %s: want %d, got %d", tc.who, path, tc.want, w.Code)
+			}
+		}
+	}
+}
+
+func TestCreateGameSchedule(t *testing.T) {
+	s := newTestServer(t)
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", `{"name": "pair", "nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "warps": ["Adab"]}, {"name": "Adab", "col": 3, "row": 2}]}`, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	// a bad schedule must not leave a game behind
+	if w := do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`", "schedule": "someday"}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("bad schedule: want %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body)
+	}
+	if games, err := s.store.ListGames(); err != nil {
+		t.Fatal(err)
+	} else if len(games) != 0 {
+		t.Errorf("bad schedule: want no games, got %d", len(games))
+	}
+
+	var g gameResponse
+	if w := do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`", "schedule": "mon 18:00"}`, &g); w.Code != http.StatusCreated {
+		t.Fatalf("create game: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	} else if g.Schedule != "mon 18:00" || g.Deadline == nil {
+		t.Errorf("create game: want the schedule and a deadline, got %q, %v", g.Schedule, g.Deadline)
+	}
+}
+
+func TestStoreErrorHidesDetails(t *testing.T) {
+	w := httptest.NewRecorder()
+	storeError(w, errors.New("open /var/lib/wow/games.db: permission denied"))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("want %d, got %d", http.StatusInternalServerError, w.Code)
+	} else if strings.Contains(w.Body.String(), "games.db") {
+		t.Errorf("want the error hidden, got %s", w.Body)
+	}
+}
@@ -0,0 +1,503 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mdhender/wow/internal/way"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/game"
+	"github.com/mdhender/wow/pkg/store"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// mapResponse is the JSON shape of a saved map.
+type mapResponse struct {
+	ID    string       `json:"id"`
+	Name  string       `json:"name"`
+	Nodes []board.Node `json:"nodes,omitempty"`
+}
+
+// handleCreateMap saves a map so that games can be played on it.
+func (s *Server) handleCreateMap() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Name  string       `json:"name"`
+			Nodes []board.Node `json:"nodes"`
+		}
+		if err := decodeJSON(w, r, 64*1024, &input); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if len(input.Nodes) == 0 {
+			jsonError(w, http.StatusBadRequest, "missing map data")
+			return
+		} else if _, err := board.FromNodes(input.Nodes); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		data, err := game.EncodeMap(input.Nodes)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		m := &store.Map{Name: input.Name, Data: data}
+		if err := s.store.CreateMap(m); err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusCreated, mapResponse{ID: m.ID, Name: m.Name, Nodes: input.Nodes})
+	}
+}
+
+// handleGetMap returns a saved map.
+func (s *Server) handleGetMap() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, err := s.store.GetMap(way.Param(r.Context(), "id"))
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		nodes, err := game.DecodeMap(m.Data)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		// the map is sent as JSON unless the client asks for an image
+		mediaType := negotiate(r, append([]string{"application/vnd.api+json", "application/json"}, mapMediaTypes...)...)
+		if wantsViewer(r) {
+			mediaType = mediaHTML
+		}
+		switch mediaType {
+		case "application/vnd.api+json", "application/json":
+			w.Header().Add("Vary", "Accept")
+			jsonOK(w, http.StatusOK, mapResponse{ID: m.ID, Name: m.Name, Nodes: nodes})
+		case "":
+			writeError(w, r, http.StatusNotAcceptable, "maps are available as json or as images")
+		default:
+			b, err := board.FromNodes(nodes)
+			if err != nil {
+				log.Printf("[server] map %s: %v\n", m.ID, err)
+				jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+				return
+			}
+			writeBoard(w, r, b, r.URL.Query().Get("mono") == "true", m.Name)
+		}
+	}
+}
+
+// hitResponse is the hex under a point on the image of a map.
+type hitResponse struct {
+	Col  int         `json:"col"`
+	Row  int         `json:"row"`
+	Hex  string      `json:"hex"` // the label of the hex, like "0605"
+	Star *board.Node `json:"star,omitempty"`
+}
+
+// handleHitMap returns the hex and star under the point x, y on an image
+// of a saved map. The image is drawn with the render options in the query,
+// and a PNG at the resolution of the "dpi" parameter.
+func (s *Server) handleHitMap() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var point [2]float64
+		for i, name := range []string{"x", "y"} {
+			v, err := strconv.ParseFloat(r.URL.Query().Get(name), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				writeError(w, r, http.StatusBadRequest, name+" must be a number")
+				return
+			}
+			point[i] = v
+		}
+		o, err := renderOptions(r, r.URL.Query().Get("mono") == "true")
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		dpi, err := queryDPI(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		m, err := s.store.GetMap(way.Param(r.Context(), "id"))
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		nodes, err := game.DecodeMap(m.Data)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		b, err := board.FromNodes(nodes)
+		if err != nil {
+			log.Printf("[server] map %s: %v\n", m.ID, err)
+			jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		// png pixels are scaled from the svg by dpi/96
+		c, star, ok := b.HitTest(o, point[0]*96/dpi, point[1]*96/dpi)
+		if !ok {
+			writeError(w, r, http.StatusNotFound, fmt.Sprintf("no hex at %g, %g", point[0], point[1]))
+			return
+		}
+		hit := hitResponse{Col: c.Col, Row: c.Row, Hex: c.String()}
+		if star != nil {
+			for i := range nodes {
+				if nodes[i].Name == star.Name {
+					hit.Star = &nodes[i]
+				}
+			}
+		}
+		jsonOK(w, http.StatusOK, hit)
+	}
+}
+
+// handleListMaps returns the names of the saved maps.
+func (s *Server) handleListMaps() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maps, err := s.store.ListMaps()
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		list := []mapResponse{}
+		for _, m := range maps {
+			list = append(list, mapResponse{ID: m.ID, Name: m.Name})
+		}
+		jsonOK(w, http.StatusOK, list)
+	}
+}
+
+// gameResponse is the JSON shape of a game.
+type gameResponse struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	MapID   string   `json:"map-id"`
+	Turn    int      `json:"turn"`
+	Players []string `json:"players"`
+	// Schedule and Deadline are only sent if the game has a schedule.
+	Schedule string     `json:"schedule,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// GMToken is only sent when the game is created.
+	GMToken string `json:"gm-token,omitempty"`
+}
+
+func (s *Server) gameResponse(g *store.Game) (gameResponse, error) {
+	players, err := s.store.ListPlayers(g.ID)
+	if err != nil {
+		return gameResponse{}, err
+	}
+	response := gameResponse{ID: g.ID, Name: g.Name, MapID: g.MapID, Turn: g.Turn, Players: []string{}, Schedule: g.Schedule}
+	if !g.Deadline.IsZero() {
+		response.Deadline = &g.Deadline
+	}
+	for _, p := range players {
+		response.Players = append(response.Players, p.Name)
+	}
+	return response, nil
+}
+
+// handleCreateGame creates a new game on a saved map.
+func (s *Server) handleCreateGame() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Name     string `json:"name"`
+			MapID    string `json:"map-id"`
+			Schedule string `json:"schedule"`
+		}
+		if err := decodeJSON(w, r, 10*1024, &input); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if input.Name = strings.TrimSpace(input.Name); input.Name == "" {
+			jsonError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+		// the schedule is checked and saved with the game, so a bad one can't leave a game behind
+		g, token, err := s.engine.CreateGame(input.Name, input.MapID, input.Schedule, time.Now())
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		response, err := s.gameResponse(g)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		response.GMToken = token
+		jsonOK(w, http.StatusCreated, response)
+	}
+}
+
+// handleGetGame returns the public details of a game.
+func (s *Server) handleGetGame() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.store.GetGame(way.Param(r.Context(), "id"))
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		response, err := s.gameResponse(g)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, response)
+	}
+}
+
+// playerResponse is the JSON shape of a player.
+type playerResponse struct {
+	GameID string   `json:"game-id"`
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email,omitempty"`
+	Home   string   `json:"home"`
+	Stars  []string `json:"stars"`
+	// Token is only sent when the player joins.
+	Token string `json:"token,omitempty"`
+}
+
+// handleJoinGame adds a player to a game.
+// The response holds the player's token; it is not shown again.
+func (s *Server) handleJoinGame() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		}
+		if err := decodeJSON(w, r, 10*1024, &input); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if input.Name = strings.TrimSpace(input.Name); input.Name == "" {
+			jsonError(w, http.StatusBadRequest, "missing name")
+			return
+		}
+		// the address goes into mail headers, so only the bare address is kept
+		if input.Email = strings.TrimSpace(input.Email); input.Email != "" {
+			addr, err := mail.ParseAddress(input.Email)
+			if err != nil {
+				jsonError(w, http.StatusBadRequest, "invalid email")
+				return
+			}
+			input.Email = addr.Address
+		}
+		p, token, err := s.engine.Join(way.Param(r.Context(), "id"), input.Name, input.Email)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusCreated, playerResponse{
+			GameID: p.GameID,
+			ID:     p.ID,
+			Name:   p.Name,
+			Email:  p.Email,
+			Home:   p.Home,
+			Stars:  p.Stars,
+			Token:  token,
+		})
+	}
+}
+
+// handleGetOrders returns the player's orders for the current turn.
+func (s *Server) handleGetOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, p, err := s.gameAndPlayer(r)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		o, err := s.store.GetOrders(g.ID, p.ID, g.Turn)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, o)
+	}
+}
+
+// handlePutOrders submits (or replaces) the player's orders for the current turn.
+// The orders may be sent as plain text or as a JSON object.
+func (s *Server) handlePutOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, p, err := s.gameAndPlayer(r)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		var input struct {
+			Orders string `json:"orders"`
+		}
+		switch mediaType(r) {
+		case "application/json":
+			if err := decodeJSON(w, r, 10*1024, &input); err != nil {
+				jsonError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		case "text/plain":
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024))
+			if err != nil || !utf8.Valid(data) {
+				jsonError(w, http.StatusBadRequest, "invalid orders")
+				return
+			}
+			input.Orders = string(data)
+		default:
+			jsonError(w, http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType))
+			return
+		}
+		o, err := s.engine.SubmitOrders(g.ID, p.ID, input.Orders)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, o)
+	}
+}
+
+// handleGetReport returns one of the player's turn reports.
+// It defaults to the report for the most recently processed turn.
+func (s *Server) handleGetReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, p, err := s.gameAndPlayer(r)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		turn := g.Turn - 1
+		if v := r.URL.Query().Get("turn"); v != "" {
+			if turn, err = strconv.Atoi(v); err != nil {
+				jsonError(w, http.StatusBadRequest, "invalid turn")
+				return
+			}
+		}
+		report, err := s.store.GetReport(g.ID, p.ID, turn)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, report)
+	}
+}
+
+// handleGetPlayerMap returns the player's map as an SVG.
+// Stars hidden by the fog of war are not shown.
+func (s *Server) handleGetPlayerMap() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, p, err := s.gameAndPlayer(r)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		b, err := s.engine.Board(g)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		writeBoard(w, r, game.FogOfWar(b, p, s.engine.SightRadius()), r.URL.Query().Get("mono") == "true", fmt.Sprintf("%s: %s, turn %d", g.Name, p.Name, g.Turn))
+	}
+}
+
+// handleSetSchedule changes the turn schedule of a game.
+// An empty schedule turns off automatic processing.
+func (s *Server) handleSetSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Schedule string `json:"schedule"`
+		}
+		if err := decodeJSON(w, r, 1024, &input); err != nil {
+			jsonError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		g, err := s.engine.SetSchedule(way.Param(r.Context(), "id"), input.Schedule, time.Now())
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		response, err := s.gameResponse(g)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, response)
+	}
+}
+
+// handleProcessTurn processes the current turn. If the scheduler gets
+// to the turn first, the response is a conflict.
+func (s *Server) handleProcessTurn() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := s.store.GetGame(way.Param(r.Context(), "id"))
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		if g, err = s.processTurn(g.ID, g.Turn, time.Now()); err != nil {
+			storeError(w, err)
+			return
+		}
+		response, err := s.gameResponse(g)
+		if err != nil {
+			storeError(w, err)
+			return
+		}
+		jsonOK(w, http.StatusOK, response)
+	}
+}
+
+// gameAndPlayer fetches the game and player named in the request path.
+func (s *Server) gameAndPlayer(r *http.Request) (*store.Game, *store.Player, error) {
+	g, err := s.store.GetGame(way.Param(r.Context(), "id"))
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := s.store.GetPlayer(g.ID, way.Param(r.Context(), "pid"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, p, nil
+}
+
+// storeError maps errors from the store and engine to a JSON error response.
+func storeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrInvalidID):
+		jsonError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, store.ErrDuplicate), errors.Is(err, game.ErrGameFull), errors.Is(err, game.ErrGameStarted), errors.Is(err, game.ErrTurnProcessed):
+		jsonError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, game.ErrInvalidOrders), errors.Is(err, game.ErrInvalidSchedule):
+		jsonError(w, http.StatusBadRequest, err.Error())
+	default:
+		// store errors can hold file names and sql, so they are only logged
+		log.Printf("[server] %v\n", err)
+		jsonError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
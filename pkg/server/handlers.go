@@ -25,12 +25,8 @@
 package server
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/mdhender/wow/pkg/board"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
@@ -38,7 +34,6 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 )
 
 // handleIndex does that
@@ -47,7 +42,7 @@ func (s *Server) handleIndex(public string, maxCols, maxRows int) http.HandlerFu
 	if err != nil {
 		log.Printf("[server] %+v\n", err)
 		return func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			writeError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 		}
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -57,222 +52,94 @@ func (s *Server) handleIndex(public string, maxCols, maxRows int) http.HandlerFu
 	}
 }
 
-// handlePostMapData accepts map data as CSV and returns an SVG or an error page.
-func (s *Server) handlePostMapData() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		type errorObject struct {
-			Code   int    `json:"code,omitempty"`
-			Detail string `json:"detail,omitempty"`
-		}
-		type errResponse struct {
-			Status string        `json:"status"`
-			Errors []errorObject `json:"errors,omitempty"`
-		}
-		type okResponse struct {
-			Status string      `json:"status"`
-			Data   interface{} `json:"data"`
-		}
-
-		type node struct {
-			Name      string   `json:"name"`
-			Col       int      `json:"col"`
-			Row       int      `json:"row"`
-			EconValue int      `json:"econ-value"` // non-zero only if hasStar
-			Warps     []string `json:"warps"`
-		}
-
-		var input struct {
-			Mono  bool   `json:"mono,omitempty"`
-			Nodes []node `json:"nodes,omitempty"`
+// handleEditor serves the map editor.
+func (s *Server) handleEditor(public string) http.HandlerFunc {
+	page, err := os.ReadFile(filepath.Join(public, "editor.html"))
+	if err != nil {
+		log.Printf("[server] %+v\n", err)
+		return func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(page)
+	}
+}
 
-		contentType := r.Header.Get("Content-type")
-		switch contentType {
-		case "application/json":
-			// enforce a maximum read of 10kb from the response body
-			r.Body = http.MaxBytesReader(w, r.Body, 10*1024)
-			// create a json decoder that will accept only our specific fields
-			dec := json.NewDecoder(r.Body)
-			dec.DisallowUnknownFields()
-			if err := dec.Decode(&input); err != nil {
-				response := errResponse{
-					Status: "error",
-					Errors: []errorObject{{
-						Code:   http.StatusBadRequest,
-						Detail: "invalid json object",
-					}},
-				}
-				w.Header().Set("Content-Type", "application/vnd.api+json")
-				w.WriteHeader(http.StatusOK)
-				_ = json.NewEncoder(w).Encode(response)
-				return
-			}
-			// call decode again to confirm that the request contained only a single JSON object
-			if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
-				response := errResponse{
-					Status: "error",
-					Errors: []errorObject{{
-						Code:   http.StatusBadRequest,
-						Detail: "request body must only contain a single json object",
-					}},
-				}
-				w.Header().Set("Content-Type", "application/vnd.api+json")
-				w.WriteHeader(http.StatusOK)
-				_ = json.NewEncoder(w).Encode(response)
-				return
-			}
-		case "application/x-www-form-urlencoded":
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-				return
-			}
-			for k, v := range r.Form {
-				switch k {
-				case "data":
-					if len(v) != 1 || !utf8.ValidString(v[0]) {
-						http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-						return
-					}
-					r := csv.NewReader(strings.NewReader(v[0]))
-					r.FieldsPerRecord = -1 // allow variable number of fields per line
-					for {
-						record, err := r.Read()
-						if err != nil {
-							break
-						} else if len(record) < 5 {
-							continue
-						}
-						n := node{
-							Name:      strings.TrimSpace(record[0]),
-							Col:       atoi(record[1]),
-							Row:       atoi(record[2]),
-							EconValue: atoi(record[3]),
-						}
-						for _, dest := range record[4:] {
-							if dest = strings.TrimSpace(dest); len(dest) != 0 {
-								n.Warps = append(n.Warps, dest)
-							}
-						}
-						input.Nodes = append(input.Nodes, n)
-					}
-				case "fill-type":
-					if len(v) != 1 || !utf8.ValidString(v[0]) {
-						http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-						return
-					}
-					input.Mono = v[0] == "mono"
-				}
-			}
-		case "text/html":
-			if err := r.ParseForm(); err != nil {
-				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-				return
-			}
-			for k, v := range r.Form {
-				switch k {
-				case "mono":
-					if len(v) != 1 || !utf8.ValidString(v[0]) {
-						http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-						return
-					}
-					input.Mono = v[0] == "true"
-				}
-			}
-		default:
-			http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+// handleValidateMapData checks map data without drawing it.
+// It accepts the same inputs as handlePostMapData and returns every
+// problem found, along with statistics for the warp graph.
+func (s *Server) handleValidateMapData() http.HandlerFunc {
+	type response struct {
+		Valid       bool               `json:"valid"`
+		Diagnostics []board.Diagnostic `json:"diagnostics"`
+		Stats       board.Stats        `json:"stats"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		input, status, err := readMapData(w, r)
+		if err != nil {
+			jsonError(w, status, err.Error())
 			return
 		}
-
-		if len(input.Nodes) == 0 {
-			response := errResponse{
-				Status: "error",
-				Errors: []errorObject{{
-					Code:   http.StatusBadRequest,
-					Detail: "missing map data",
-				}},
-			}
-			w.Header().Set("Content-Type", "application/vnd.api+json")
-			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(response)
-			return
-		} else if len(input.Nodes) > 40 {
-			response := errResponse{
-				Status: "error",
-				Errors: []errorObject{{
-					Code:   http.StatusBadRequest,
-					Detail: "maximum number of nodes is 40",
-				}},
-			}
-			w.Header().Set("Content-Type", "application/vnd.api+json")
-			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(response)
-			return
+		input.validate(r)
+		result := response{
+			Valid:       !board.HasErrors(input.Diagnostics),
+			Diagnostics: input.Diagnostics,
+			Stats:       board.NodeStats(input.Nodes),
 		}
-
-		// max row and col determine the size of the board
-		minRow, maxRow, minCol, maxCol := 0, 0, 0, 0
-		for i, h := range input.Nodes {
-			if h.Row < minRow || i == 0 {
-				minRow = h.Row
-			}
-			if h.Row > maxRow || i == 0 {
-				maxRow = h.Row
-			}
-			if h.Col < minCol || i == 0 {
-				minCol = h.Col
-			}
-			if h.Col > maxCol || i == 0 {
-				maxCol = h.Col
-			}
+		if result.Diagnostics == nil {
+			result.Diagnostics = []board.Diagnostic{}
 		}
+		jsonOK(w, http.StatusOK, result)
+	}
+}
 
-		// sanity and performance checks
-		if minCol < 1 || minRow < 1 {
-			response := errResponse{
-				Status: "error",
-				Errors: []errorObject{{
-					Code:   http.StatusBadRequest,
-					Detail: "col and row must be at least 1",
-				}},
-			}
-			w.Header().Set("Content-Type", "application/vnd.api+json")
-			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(response)
+// handlePostMapData accepts map data (see readMapData) and returns the map
+// in the format the client asked for. Invalid map data gets a 400 response
+// listing every error found, and an unsupported content type gets a 415.
+func (s *Server) handlePostMapData() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input, status, err := readMapData(w, r)
+		if err != nil {
+			writeError(w, r, status, err.Error())
 			return
-		} else if maxCol > 40 || maxRow > 40 {
-			response := errResponse{
-				Status: "error",
-				Errors: []errorObject{{
-					Code:   http.StatusBadRequest,
-					Detail: "col and row must be at least 1",
-				}},
+		}
+		input.validate(r)
+		if board.HasErrors(input.Diagnostics) {
+			var details []string
+			for _, d := range input.Diagnostics {
+				if d.Severity == board.SeverityError {
+					details = append(details, d.Error())
+				}
 			}
-			w.Header().Set("Content-Type", "application/vnd.api+json")
-			w.WriteHeader(http.StatusOK)
-			_ = json.NewEncoder(w).Encode(response)
+			writeError(w, r, http.StatusBadRequest, details...)
 			return
 		}
 
 		// create the board, add all the stars, then add the wormholes
-		gb := board.NewBoard(maxRow, maxCol)
-		for _, h := range input.Nodes {
-			gb.AddStar(h.Name, h.Row, h.Col, h.EconValue)
+		gb, err := board.FromNodesMasked(input.Nodes, input.Mask)
+		if err == nil {
+			err = gb.AddTerrain(input.Terrain)
 		}
-		for _, h := range input.Nodes {
-			for _, target := range h.Warps {
-				if err := gb.AddWormHole(h.Name, target); err != nil {
-					w.Header().Set("content-type", "text/html")
-					w.WriteHeader(http.StatusOK)
-					_, _ = w.Write([]byte(fmt.Sprintf(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"><title>Wars of Warp</title></head><body><p>Sorry, but there was an error with the input</p><pre><code>%+v</code></pre>`, err)))
-					return
-				}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		if input.Wrap != board.WrapNone {
+			o, err := renderOptions(r, input.Mono)
+			if err == nil {
+				err = gb.SetWrap(input.Wrap, o.Geometry())
+			}
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, err.Error())
+				return
 			}
 		}
 
-		// save the board as an SVG file
-		w.Header().Set("content-type", "image/svg+xml")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(gb.AsSVG(input.Mono))
+		// send the board in the format the client asked for
+		writeBoard(w, r, gb, input.Mono, "Map")
 	}
 }
 
@@ -288,6 +155,34 @@ func (s *Server) handleRandomMap() http.HandlerFunc {
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		// the "shape" parameter masks the board; stars are only placed inside the mask.
+		// the "wrap" parameter joins the edges of the board.
+		o, err := renderOptions(r, true)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		var mask board.Mask
+		if shape := r.URL.Query().Get("shape"); shape != "" {
+			if mask, err = board.ShapeMask(shape, 20, 20, o.Geometry()); err != nil {
+				writeError(w, r, http.StatusBadRequest, fmt.Sprintf("shape must be one of %s", strings.Join(board.Shapes, ", ")))
+				return
+			}
+		}
+		features, err := board.ParseFeatures(r.URL.Query().Get("features"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, strings.TrimPrefix(err.Error(), "board: "))
+			return
+		}
+		wrap, err := board.ParseWrap(r.URL.Query().Get("wrap"))
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, strings.TrimPrefix(err.Error(), "board: "))
+			return
+		} else if wrap != board.WrapNone && mask == nil {
+			// the edges wrap at the size of the board, so the exported map data needs it
+			mask, _ = board.ShapeMask("rectangle", 20, 20, o.Geometry())
+		}
+
 		// names returns a shuffled list of starry sounding names for stars.
 		var names = []string{
 			"Afak", "Agrab", "Akkad", "Al-Diniye", "Al-Esotam", "Al-Hafriyat", "Annah", "Arbela", "Arbīl", "Arrapkha",
@@ -304,28 +199,38 @@ func (s *Server) handleRandomMap() http.HandlerFunc {
 		})
 
 		var baseMap [22][22]*node
+		// at returns the node in a hex, wrapping around the edges of the board
+		at := func(col, row int) *node {
+			if wrap&board.WrapEastWest != 0 {
+				col = (col+19)%20 + 1
+			}
+			if wrap&board.WrapNorthSouth != 0 {
+				row = (row+19)%20 + 1
+			}
+			return baseMap[col][row]
+		}
 		for col := 1; col <= 20; col++ {
 			for row := 1; row <= 20; row++ {
 				// each hex has a 1 in 12 chance of containing a star
-				if rand.Intn(12) != 1 {
+				if !mask.Contains(board.Coords{Col: col, Row: row}) || rand.Intn(12) != 1 {
 					continue
 				}
-				// can't have a neighbor
-				if baseMap[col-1][row-1] != nil {
+				// can't have a neighbor, even across an edge that wraps
+				if at(col-1, row-1) != nil {
 					continue
-				} else if baseMap[col-1][row] != nil {
+				} else if at(col-1, row) != nil {
 					continue
-				} else if baseMap[col-1][row+1] != nil {
+				} else if at(col-1, row+1) != nil {
 					continue
-				} else if baseMap[col][row-1] != nil {
+				} else if at(col, row-1) != nil {
 					continue
-				} else if baseMap[col][row+1] != nil {
+				} else if at(col, row+1) != nil {
 					continue
-				} else if baseMap[col+1][row-1] != nil {
+				} else if at(col+1, row-1) != nil {
 					continue
-				} else if baseMap[col+1][row] != nil {
+				} else if at(col+1, row) != nil {
 					continue
-				} else if baseMap[col+1][row+1] != nil {
+				} else if at(col+1, row+1) != nil {
 					continue
 				}
 				// a good range for econ values is 0..5 with higher values being rarer
@@ -346,7 +251,7 @@ func (s *Server) handleRandomMap() http.HandlerFunc {
 				}
 				var name string
 				if len(names) == 0 {
-					name = fmt.Sprintf("N%02d%02d", col, row)
+					name = "N" + board.Coords{Col: col, Row: row}.String()
 				} else {
 					name, names = names[0], names[1:]
 				}
@@ -369,7 +274,14 @@ func (s *Server) handleRandomMap() http.HandlerFunc {
 			var neighbors []*node
 			for _, x := range nodes {
 				if x != n {
-					x.distance = (n.Col-x.Col)*(n.Col-x.Col) + (n.Row-x.Row)*(n.Row-x.Row)
+					dc, dr := abs(n.Col-x.Col), abs(n.Row-x.Row)
+					if wrap&board.WrapEastWest != 0 && dc > 10 {
+						dc = 20 - dc
+					}
+					if wrap&board.WrapNorthSouth != 0 && dr > 10 {
+						dr = 20 - dr
+					}
+					x.distance = dc*dc + dr*dr
 					neighbors = append(neighbors, x)
 				}
 			}
@@ -406,50 +318,59 @@ func (s *Server) handleRandomMap() http.HandlerFunc {
 
 		// board will always be 20 x 20
 		gb := board.NewBoard(20, 20)
+		gb.Mask = mask
+		if err := gb.SetWrap(wrap, o.Geometry()); err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
 
 		// add stars
 		for _, n := range nodes {
 			gb.AddStar(n.Name, n.Row, n.Col, n.EconValue)
 		}
 
+		// the "features" parameter sprinkles those features over 1 in 10 of the empty hexes
+		if kinds := features.List(); len(kinds) != 0 {
+			for col := 1; col <= 20; col++ {
+				for row := 1; row <= 20; row++ {
+					if baseMap[col][row] != nil || !mask.Contains(board.Coords{Col: col, Row: row}) || rand.Intn(10) != 0 {
+						continue
+					}
+					if err := gb.AddFeature(col, row, kinds[rand.Intn(len(kinds))]); err != nil {
+						log.Printf("[server] random map: %v\n", err)
+					}
+				}
+			}
+		}
+
 		// add wormholes
 		for _, n := range nodes {
 			for _, target := range n.Warps {
 				if err := gb.AddWormHole(n.Name, target); err != nil {
-					w.Header().Set("content-type", "text/html")
-					w.WriteHeader(http.StatusOK)
-					_, _ = w.Write([]byte(fmt.Sprintf(`<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8"><title>Wars of Warp</title></head><body><p>Sorry, but there was an error with the input</p><pre><code>%+v</code></pre>`, err)))
+					log.Printf("[server] random map: %v\n", err)
+					writeError(w, r, http.StatusInternalServerError, err.Error())
 					return
 				}
 			}
 		}
 
-		// save the board as an SVG file
-		w.Header().Set("content-type", "image/svg+xml")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(gb.AsSVG(true))
+		// send the board in the format the client asked for
+		writeBoard(w, r, gb, true, "Random map")
 	}
 }
 
-// handleStandardMap does that
-func (s *Server) handleStandardMap(static string, color bool) http.HandlerFunc {
-	var filename string
-	if color {
-		filename = filepath.Join(static, "svg-test.svg")
-	} else {
-		filename = filepath.Join(static, "svg-mono-test.svg")
-	}
-	index, err := os.ReadFile(filename)
-	if err != nil {
-		log.Printf("[server] %+v\n", err)
-		return func(w http.ResponseWriter, r *http.Request) {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		}
+func abs(n int) int {
+	if n < 0 {
+		return -n
 	}
+	return n
+}
+
+// handleStandardMap does that
+func (s *Server) handleStandardMap(color bool) http.HandlerFunc {
+	gb := board.NewStandardBoard()
 	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("content-type", "image/svg+xml")
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(index)
+		writeBoard(w, r, gb, !color, "Standard map")
 	}
 }
 
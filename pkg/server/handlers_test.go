@@ -0,0 +1,251 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateMapData(t *testing.T) {
+	s := newTestServer(t)
+	type result struct {
+		Valid       bool               `json:"valid"`
+		Diagnostics []board.Diagnostic `json:"diagnostics"`
+		Stats       board.Stats        `json:"stats"`
+	}
+
+	var got result
+	w := do(t, s, "POST", "/wow/api/map-data/validate", "", "application/json", `{"nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "econ-value": 4, "warps": ["Adab"]},
+		{"name": "Adab", "col": 3, "row": 2, "econ-value": 1}]}`, &got)
+	if w.Code != http.StatusOK || !got.Valid || len(got.Diagnostics) != 0 || got.Stats.Stars != 2 || got.Stats.Warps != 1 {
+		t.Errorf("json: want 200, valid with 2 stars and 1 warp, got %d %+v", w.Code, got)
+	}
+
+	// the bad row is only reported once, by the parser
+	got = result{}
+	w = do(t, s, "POST", "/wow/api/map-data/validate", "", "text/csv", "Ur, 1, 1, 4, Kish\nAdab, 3, x, 1, Ur\n", &got)
+	want := []board.Diagnostic{
+		{Severity: board.SeverityError, Record: 1, Line: 2, Field: "row", Message: `"x" is not a number`},
+		{Severity: board.SeverityError, Record: 0, Field: "warps", Message: `warp to unknown star "Kish"`},
+	}
+	if w.Code != http.StatusOK || got.Valid || len(got.Diagnostics) != len(want) {
+		t.Fatalf("csv: want 200 and %+v, got %d %+v", want, w.Code, got)
+	}
+	for i := range want {
+		if got.Diagnostics[i] != want[i] {
+			t.Errorf("csv: want %+v, got %+v", want[i], got.Diagnostics[i])
+		}
+	}
+
+	got = result{}
+	var csv strings.Builder
+	for _, n := range board.StandardNodes() {
+		_, _ = fmt.Fprintf(&csv, "%s, %d, %d, %d, %s\n", n.Name, n.Col, n.Row, n.EconValue, strings.Join(n.Warps, ", "))
+	}
+	form := url.Values{"data": {csv.String()}, "fill-type": {"mono"}}.Encode()
+	if w = do(t, s, "POST", "/wow/api/map-data/validate", "", "application/x-www-form-urlencoded", form, &got); w.Code != http.StatusOK || !got.Valid || got.Stats.Stars != 28 {
+		t.Errorf("form: want 200, valid with 28 stars, got %d %+v", w.Code, got)
+	}
+
+	for _, tc := range []struct {
+		contentType, body string
+		status            int
+	}{
+		{"application/json", `{"nodes": [`, http.StatusBadRequest},
+		{"application/json", `{"stars": []}`, http.StatusBadRequest},
+		{"application/xml", `<nodes/>`, http.StatusUnsupportedMediaType},
+	} {
+		if w := do(t, s, "POST", "/wow/api/map-data/validate", "", tc.contentType, tc.body, nil); w.Code != tc.status {
+			t.Errorf("%s %s: want %d, got %d", tc.contentType, tc.body, tc.status, w.Code)
+		}
+	}
+}
+
+func TestPostMapData(t *testing.T) {
+	s := newTestServer(t)
+	for _, tc := range []struct {
+		name                string
+		contentType, accept string
+		body                string
+		status              int
+		mediaType           string
+		want                string // in the body
+	}{
+		{"json", "application/json", "", `{"nodes": [{"name": "Ur", "col": 1, "row": 1, "warps": ["Adab"]}, {"name": "Adab", "col": 3, "row": 2}]}`,
+			http.StatusOK, mediaSVG, "<svg"},
+		{"json charset", "application/json; charset=utf-8", "image/png", `{"mono": true, "nodes": [{"name": "Ur", "col": 1, "row": 1}]}`,
+			http.StatusOK, mediaPNG, "PNG"},
+		{"form", "application/x-www-form-urlencoded", "text/html,*/*;q=0.8", "fill-type=mono&data=Ur%2C+1%2C+1%2C+4%2C+Adab%0AAdab%2C+3%2C+2%2C+1",
+			http.StatusOK, mediaSVG, "Adab"},
+		{"csv", "text/csv", "", "Ur, 1, 1, 4\n", http.StatusOK, mediaSVG, "Ur"},
+		{"viewer", "text/csv", "", "Ur, 1, 1, 4\n", http.StatusOK, "text/html; charset=utf-8", "board-data"},
+		{"bad json", "application/json", "", `{"nodes": [`, http.StatusBadRequest, "application/vnd.api+json", "invalid json object"},
+		{"no nodes", "application/json", "", `{"nodes": []}`, http.StatusBadRequest, "application/vnd.api+json", "missing map data"},
+		{"too big", "application/json", "", `{"nodes": [{"name": "Ur", "col": 41, "row": 1}]}`,
+			http.StatusBadRequest, "application/vnd.api+json", "record 1: col: col must be 1 to 40"},
+		{"bad warp", "text/csv", "application/json", "Ur, 1, 1, 4, Kish\n",
+			http.StatusBadRequest, "application/vnd.api+json", `record 1: warps: warp to unknown star \"Kish\"`},
+		{"browser", "application/x-www-form-urlencoded", "text/html,application/xhtml+xml,*/*;q=0.8", "data=%3Cb%3E%2C+x%2C+1%2C+4",
+			http.StatusBadRequest, "text/html; charset=utf-8", "record 1: col: &#34;x&#34; is not a number"},
+		{"browser escapes", "application/x-www-form-urlencoded", "text/html", "data=%3Cb%3E%2C+1%2C+1%2C+4%2C+%3Ci%3E",
+			http.StatusBadRequest, "text/html; charset=utf-8", "unknown star &#34;&lt;i&gt;&#34;"},
+		{"mask", "application/json", "", `{"nodes": [{"name": "Ur", "col": 2, "row": 1}], "mask": [[1, 1], [2, 1]]}`,
+			http.StatusOK, mediaSVG, "Ur"},
+		{"outside mask", "application/json", "", `{"nodes": [{"name": "Ur", "col": 2, "row": 2}], "mask": ".."}`,
+			http.StatusBadRequest, "application/vnd.api+json", "record 1: hex 0202 is outside the mask"},
+		{"bad mask", "application/x-www-form-urlencoded", "", "data=Ur%2C+1%2C+1%2C+4&mask=.%3F",
+			http.StatusBadRequest, "application/vnd.api+json", `mask: line 1: unexpected '?'`},
+		{"wrap", "application/json", "", `{"nodes": [{"name": "Ur", "col": 1, "row": 1, "warps": ["Adab"]}, {"name": "Adab", "col": 4, "row": 1}], "wrap": "east-west"}`,
+			http.StatusOK, mediaSVG, "Adab"},
+		{"odd wrap", "application/json", "", `{"nodes": [{"name": "Ur", "col": 3, "row": 1}], "wrap": "east-west"}`,
+			http.StatusBadRequest, "application/vnd.api+json", "even number of columns"},
+		{"bad wrap", "text/csv", "", "Ur, 1, 1, 4\n", http.StatusBadRequest, "application/vnd.api+json", "wrap: must be none"},
+		{"bad utf-8", "text/csv", "", "Ad\xffab, 1, 1, 4\n", http.StatusBadRequest, "application/vnd.api+json", "data: must be valid UTF-8"},
+		{"bad utf-8 form", "application/x-www-form-urlencoded", "", "data=Ad%FFab%2C+1%2C+1%2C+4",
+			http.StatusBadRequest, "application/vnd.api+json", "data: must be valid UTF-8"},
+		{"features", "application/json", "", `{"nodes": [{"name": "Ur", "col": 1, "row": 1}], "features": [{"feature": "nebula", "col": 2, "row": 1}]}`,
+			http.StatusOK, mediaSVG, "url(#feature-nebula)"},
+		{"blocked star", "text/csv", "", "Ur, 1, 1, 4\n@blocked, 1, 1\n",
+			http.StatusBadRequest, "application/vnd.api+json", `features: blocked at 0101: star \"Ur\" can't be in an impassable hex`},
+		{"unsupported", "application/xml", "", "<nodes/>", http.StatusUnsupportedMediaType, "application/vnd.api+json", "map data must be"},
+		{"unsupported browser", "text/html", "text/html", "<nodes/>", http.StatusUnsupportedMediaType, "text/html; charset=utf-8", "415 Unsupported Media Type"},
+	} {
+		target := "/wow/api/map-data"
+		if tc.name == "viewer" {
+			target += "?format=html"
+		} else if tc.name == "bad wrap" {
+			target += "?wrap=sphere"
+		}
+		r := httptest.NewRequest("POST", target, strings.NewReader(tc.body))
+		r.Header.Set("Content-Type", tc.contentType)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.status || w.Header().Get("Content-Type") != tc.mediaType {
+			t.Errorf("%s: want %d %s, got %d %s", tc.name, tc.status, tc.mediaType, w.Code, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s: want %q in\n%s", tc.name, tc.want, w.Body.String())
+		}
+	}
+}
+
+func TestRandomMap(t *testing.T) {
+	s := newTestServer(t)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+	for _, tc := range []struct {
+		format, mediaType, want string
+	}{
+		{"", mediaSVG, "<svg"},
+		{"json", "application/json", `"nodes": [`},
+		{"csv", "text/csv; charset=utf-8", ", "},
+		{"dot", "text/vnd.graphviz; charset=utf-8", "graph wow {"},
+		{"graphml", "application/graphml+xml", "<graphml"},
+	} {
+		w := get("/wow/map/random?format=" + tc.format)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != tc.mediaType || !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%q: want %d %s with %q, got %d %s\n%s", tc.format, http.StatusOK, tc.mediaType, tc.want, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+
+	// the exported data can be sent back to draw the same map
+	for _, tc := range []struct {
+		format, contentType string
+	}{
+		{"json", "application/json"},
+		{"csv", "text/csv"},
+	} {
+		data := get("/wow/map/random?format=" + tc.format).Body.String()
+		r := httptest.NewRequest("POST", "/wow/api/map-data?format="+tc.format, strings.NewReader(data))
+		r.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || w.Body.String() != data {
+			t.Errorf("%s: round trip: want %d and the same data, got %d\n%s\n%s", tc.format, http.StatusOK, w.Code, data, w.Body.String())
+		}
+	}
+
+	// shaped maps only have stars inside the mask, and the mask is exported
+	for _, shape := range board.Shapes {
+		var got struct {
+			Nodes []board.Node `json:"nodes"`
+			Mask  board.Mask   `json:"mask"`
+		}
+		w := get("/wow/map/random?format=json&orientation=pointy&shape=" + shape)
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: %v", shape, err)
+			continue
+		}
+		want, _ := board.ShapeMask(shape, 20, 20, board.Geometry{Orientation: "pointy"})
+		if !reflect.DeepEqual(got.Mask, want) {
+			t.Errorf("%s: mask: got\n%s\nwant\n%s", shape, got.Mask, want)
+		}
+		if d := board.ValidateMask(got.Nodes, got.Mask); len(d) != 0 {
+			t.Errorf("%s: %v", shape, d)
+		}
+	}
+	if w := get("/wow/map/random?shape=circle"); w.Code != http.StatusBadRequest {
+		t.Errorf("circle: want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// wrapped maps say so in the export
+	if w := get("/wow/map/random?format=json&wrap=torus"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"wrap": "both"`) {
+		t.Errorf("torus: want %d with the wrap, got %d\n%s", http.StatusOK, w.Code, w.Body.String())
+	}
+	data := get("/wow/map/random?format=json&wrap=north-south").Body.String()
+	r := httptest.NewRequest("POST", "/wow/api/map-data?format=json", strings.NewReader(data))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != data {
+		t.Errorf("north-south: round trip: want %d and the same data, got %d\n%s\n%s", http.StatusOK, w.Code, data, w.Body.String())
+	}
+	if w := get("/wow/map/random?wrap=sphere"); w.Code != http.StatusBadRequest {
+		t.Errorf("sphere: want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// features are sprinkled over the empty hexes
+	if w := get("/wow/map/random?format=json&features=all"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"features": [`) {
+		t.Errorf("features: want %d with the features, got %d\n%s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := get("/wow/map/random?features=lava"); w.Code != http.StatusBadRequest {
+		t.Errorf("lava: want %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMapData is the largest request body accepted by the map-data endpoints.
+const maxMapData = 10 * 1024
+
+// mapData is the map data sent to the map-data endpoints.
+type mapData struct {
+	Mono        bool
+	Nodes       []board.Node
+	Terrain     []board.Terrain    // features in the hexes
+	Mask        board.Mask         // nil for a rectangular board
+	Wrap        board.Wrap         // edges of the board that wrap around
+	Diagnostics []board.Diagnostic // problems found while reading the data
+}
+
+// readMapData reads map data from the request body, which may be
+//   - JSON in the format described in the README,
+//   - a form with CSV in the "data" field, "mono" in the "fill-type" field
+//     and an optional ASCII mask in the "mask" field, or
+//   - plain CSV, with "mono=true" in the query parameters for a mono map.
+//
+// The wrap is in the "wrap" field of the JSON or form, or the "wrap"
+// query parameter for CSV.
+//
+// Problems with the map data itself are returned in the diagnostics.
+// The error is only set if the body can't be read at all, along with the
+// status code to send.
+func readMapData(w http.ResponseWriter, r *http.Request) (mapData, int, error) {
+	var input mapData
+	switch mediaType(r) {
+	case "application/json":
+		var body struct {
+			Mono     bool            `json:"mono,omitempty"`
+			Nodes    []board.Node    `json:"nodes,omitempty"`
+			Features []board.Terrain `json:"features,omitempty"`
+			Mask     json.RawMessage `json:"mask,omitempty"`
+			Wrap     string          `json:"wrap,omitempty"`
+		}
+		if err := decodeJSON(w, r, maxMapData, &body); err != nil {
+			return input, http.StatusBadRequest, err
+		}
+		input.Mono, input.Nodes, input.Terrain = body.Mono, body.Nodes, body.Features
+		if body.Mask != nil {
+			if err := json.Unmarshal(body.Mask, &input.Mask); err != nil {
+				input.maskError(err)
+			}
+		}
+		input.parseWrap(body.Wrap)
+	case "application/x-www-form-urlencoded":
+		r.Body = http.MaxBytesReader(w, r.Body, maxMapData)
+		if err := r.ParseForm(); err != nil {
+			return input, http.StatusBadRequest, fmt.Errorf("invalid form data")
+		}
+		for _, field := range []string{"data", "mask", "fill-type", "wrap"} {
+			input.checkUTF8(field, r.PostForm.Get(field))
+		}
+		if input.Diagnostics != nil {
+			return input, 0, nil
+		}
+		input.Mono = r.PostForm.Get("fill-type") == "mono"
+		input.Nodes, input.Terrain, input.Diagnostics = board.ParseMapCSV(strings.NewReader(r.PostForm.Get("data")))
+		if mask := r.PostForm.Get("mask"); strings.TrimSpace(mask) != "" {
+			var err error
+			if input.Mask, err = board.ParseMask(strings.NewReader(mask)); err != nil {
+				input.maskError(err)
+			}
+		}
+		input.parseWrap(r.PostForm.Get("wrap"))
+	case "text/csv", "text/plain":
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMapData))
+		if err != nil {
+			return input, http.StatusBadRequest, fmt.Errorf("map data must not be larger than %d bytes", maxMapData)
+		} else if !input.checkUTF8("data", string(data)) {
+			return input, 0, nil
+		}
+		input.Mono = r.URL.Query().Get("mono") == "true"
+		input.Nodes, input.Terrain, input.Diagnostics = board.ParseMapCSV(strings.NewReader(string(data)))
+		input.parseWrap(r.URL.Query().Get("wrap"))
+	default:
+		return input, http.StatusUnsupportedMediaType, fmt.Errorf("map data must be application/json, text/csv or a form")
+	}
+	return input, 0, nil
+}
+
+// checkUTF8 reports a field that isn't valid UTF-8, which can't be drawn.
+// It returns false if the field was reported.
+func (input *mapData) checkUTF8(field, value string) bool {
+	if utf8.ValidString(value) {
+		return true
+	}
+	input.Diagnostics = append(input.Diagnostics, board.Diagnostic{
+		Severity: board.SeverityError,
+		Record:   -1,
+		Field:    field,
+		Message:  "must be valid UTF-8",
+	})
+	return false
+}
+
+// maskError reports a mask that can't be read.
+func (input *mapData) maskError(err error) {
+	input.Diagnostics = append(input.Diagnostics, board.Diagnostic{
+		Severity: board.SeverityError,
+		Record:   -1,
+		Field:    "mask",
+		Message:  strings.TrimPrefix(strings.TrimPrefix(err.Error(), "board: "), "mask: "),
+	})
+}
+
+// parseWrap sets the wrap, or reports a name that isn't one.
+func (input *mapData) parseWrap(name string) {
+	var err error
+	if input.Wrap, err = board.ParseWrap(name); err != nil {
+		input.Diagnostics = append(input.Diagnostics, board.Diagnostic{
+			Severity: board.SeverityError,
+			Record:   -1,
+			Field:    "wrap",
+			Message:  strings.TrimPrefix(err.Error(), "board: wrap "),
+		})
+	}
+}
+
+// validate adds the problems found by board.ValidateNodes,
+// board.ValidateMask, board.ValidateTerrain and board.ValidateCrossings
+// to the diagnostics. Crossings depend on how the map is drawn, so they
+// are checked with the orientation and offset in the query parameters.
+// Fields that couldn't be read are only reported once.
+func (input *mapData) validate(r *http.Request) {
+	reported := make(map[string]bool)
+	for _, d := range input.Diagnostics {
+		reported[fmt.Sprintf("%d/%s", d.Record, d.Field)] = true
+	}
+	found := board.ValidateNodes(input.Nodes)
+	found = append(found, board.ValidateMask(input.Nodes, input.Mask)...)
+	found = append(found, board.ValidateTerrain(input.Nodes, input.Terrain, input.Mask)...)
+	if !board.HasErrors(found) && !board.HasErrors(input.Diagnostics) {
+		q := r.URL.Query()
+		g := board.Geometry{Orientation: q.Get("orientation"), Offset: q.Get("offset")}
+		found = append(found, board.ValidateCrossings(input.Nodes, input.Mask, input.Wrap, g)...)
+	}
+	for _, d := range found {
+		if d.Field == "" || !reported[fmt.Sprintf("%d/%s", d.Record, d.Field)] {
+			input.Diagnostics = append(input.Diagnostics, d)
+		}
+	}
+}
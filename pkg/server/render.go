@@ -0,0 +1,256 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// map image media types, in order of preference
+const (
+	mediaSVG = "image/svg+xml"
+	mediaPNG = "image/png"
+	mediaPDF = "application/pdf"
+
+	// mediaHTML is the interactive viewer. Browsers ask for HTML first,
+	// so it is only sent when the "format" parameter asks for it.
+	mediaHTML = "text/html"
+)
+
+var mapMediaTypes = []string{mediaSVG, mediaPNG, mediaPDF}
+
+// formats lets the "format" query parameter override the Accept header,
+// which is handy for links in email and in the browser.
+var formats = map[string]string{
+	"json": "application/vnd.api+json",
+	"svg":  mediaSVG,
+	"png":  mediaPNG,
+	"pdf":  mediaPDF,
+}
+
+// exports are the map data formats, keyed by the "format" query parameter.
+// Unlike the images, they are only sent when the parameter asks for them.
+var exports = map[string]struct {
+	mediaType string
+	write     func(b *board.Board, w io.Writer) error
+}{
+	"json":    {"application/json", (*board.Board).WriteJSON},
+	"csv":     {"text/csv; charset=utf-8", (*board.Board).WriteCSV},
+	"dot":     {"text/vnd.graphviz; charset=utf-8", (*board.Board).WriteDOT},
+	"graphml": {"application/graphml+xml", (*board.Board).WriteGraphML},
+}
+
+// negotiate returns the offered media type that the client prefers.
+// Ties go to the earlier offer. If the request doesn't have an Accept
+// header, it returns the first offer; if the client doesn't accept any
+// of the offers, it returns an empty string.
+func negotiate(r *http.Request, offers ...string) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		for _, offer := range offers {
+			if formats[strings.ToLower(format)] == offer {
+				return offer
+			}
+		}
+		return ""
+	}
+	return negotiateAccept(r.Header.Get("Accept"), offers...)
+}
+
+// negotiateAccept returns the offered media type that the Accept header prefers.
+func negotiateAccept(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		// the most specific matching range sets the quality of the offer
+		q, specificity := 0.0, 0
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			var s int
+			switch {
+			case mediaType == offer:
+				s = 3
+			case strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaType, "*")):
+				s = 2
+			case mediaType == "*/*":
+				s = 1
+			default:
+				continue
+			}
+			if s > specificity {
+				q, specificity = 1, s
+				if v, err := strconv.ParseFloat(params["q"], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// renderOptions returns the options for drawing a map from the query parameters.
+// The theme is "mono" or "color" unless the "theme" parameter names another.
+func renderOptions(r *http.Request, mono bool) (board.RenderOptions, error) {
+	q := r.URL.Query()
+	o := board.DefaultRenderOptions(mono)
+	if name := q.Get("theme"); name != "" {
+		t, err := board.LookupTheme(name)
+		if err != nil {
+			return o, err
+		}
+		o.Theme = t
+	}
+	for _, p := range []struct {
+		name     string
+		value    *float64
+		min, max float64
+	}{
+		{"hex-size", &o.HexSize, 10, 200},
+		{"font-size", &o.FontSize, 4, 72},
+		{"stroke-width", &o.StrokeWidth, 0.5, 20},
+		{"warp-width", &o.WarpWidth, 0.5, 20},
+		{"margin", &o.Margin, 0, 400},
+	} {
+		if value := q.Get(p.name); value != "" {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(v) || v < p.min || v > p.max {
+				return o, fmt.Errorf("%s must be between %g and %g", p.name, p.min, p.max)
+			}
+			*p.value = v
+		}
+	}
+	if value := q.Get("orientation"); value != "" {
+		o.Orientation = value
+	}
+	if value := q.Get("offset"); value != "" {
+		o.Offset = value
+	}
+	if value := q.Get("font-family"); value != "" {
+		o.FontFamily = value
+	}
+	return o, o.Validate()
+}
+
+// wantsViewer reports whether the request asks for the interactive viewer.
+func wantsViewer(r *http.Request) bool {
+	return strings.EqualFold(r.URL.Query().Get("format"), "html")
+}
+
+// queryDPI returns the resolution of PNG images from the "dpi" query
+// parameter. The default is 96, which is the size of the SVG.
+func queryDPI(r *http.Request) (float64, error) {
+	value := r.URL.Query().Get("dpi")
+	if value == "" {
+		return 96, nil
+	}
+	dpi, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(dpi) || dpi < 24 || dpi > 300 {
+		return 0, fmt.Errorf("dpi must be between 24 and 300")
+	}
+	return dpi, nil
+}
+
+// writeBoard sends a board as an SVG, PNG or PDF image, as the
+// interactive viewer with the given title, or as map data.
+// The query parameters set the render options (see renderOptions).
+// PNG images take their resolution from the "dpi" query parameter,
+// and PDF files their page size from the "paper" parameter.
+func writeBoard(w http.ResponseWriter, r *http.Request, b *board.Board, mono bool, title string) {
+	w.Header().Add("Vary", "Accept")
+	if export, ok := exports[strings.ToLower(r.URL.Query().Get("format"))]; ok {
+		w.Header().Set("Content-Type", export.mediaType)
+		w.WriteHeader(http.StatusOK)
+		if err := export.write(b, w); err != nil {
+			log.Printf("[server] %s: %v\n", r.URL.Path, err)
+		}
+		return
+	}
+	mediaType := negotiate(r, mapMediaTypes...)
+	if wantsViewer(r) {
+		mediaType = mediaHTML
+	}
+	o, err := renderOptions(r, mono)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data []byte
+	switch mediaType {
+	case mediaSVG:
+		// svg is streamed; the options have been checked, so only the connection can fail
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(http.StatusOK)
+		if err := b.WriteSVG(w, o); err != nil {
+			log.Printf("[server] %s: %v\n", r.URL.Path, err)
+		}
+		return
+	case mediaPNG:
+		dpi, err := queryDPI(r)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		data, err = b.RenderPNG(o, dpi)
+	case mediaHTML:
+		data, err = b.RenderViewer(o, title)
+		mediaType += "; charset=utf-8"
+	case mediaPDF:
+		paper := r.URL.Query().Get("paper")
+		if paper == "" {
+			paper = "letter"
+		}
+		if data, err = b.RenderPDF(o, paper); err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		writeError(w, r, http.StatusNotAcceptable, fmt.Sprintf("maps are available as %s", strings.Join(mapMediaTypes, ", ")))
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
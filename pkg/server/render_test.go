@@ -0,0 +1,156 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		target, accept string
+		want           string
+	}{
+		{"/", "", mediaSVG},
+		{"/", "*/*", mediaSVG},
+		{"/", "image/png", mediaPNG},
+		{"/", "image/*;q=0.5, application/pdf", mediaPDF},
+		{"/", "image/png;q=0.5, image/*", mediaSVG},
+		{"/", "image/svg+xml;q=0, image/*", mediaPNG},
+		{"/", "text/html", ""},
+		{"/?format=pdf", "image/png", mediaPDF},
+		{"/?format=json", "", ""},
+	} {
+		r := httptest.NewRequest("GET", tc.target, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		if got := negotiate(r, mapMediaTypes...); got != tc.want {
+			t.Errorf("%s %q: want %q, got %q", tc.target, tc.accept, tc.want, got)
+		}
+	}
+}
+
+func TestStandardMapFormats(t *testing.T) {
+	s := newTestServer(t)
+	for _, tc := range []struct {
+		target, accept string
+		status         int
+		contentType    string
+	}{
+		{"/wow/map/color", "", http.StatusOK, mediaSVG},
+		{"/wow/map/color", "image/png", http.StatusOK, mediaPNG},
+		{"/wow/map/mono?format=pdf&paper=a4", "", http.StatusOK, mediaPDF},
+		{"/wow/map/mono?format=png&dpi=1000", "", http.StatusBadRequest, "application/vnd.api+json"},
+		{"/wow/map/mono", "text/plain", http.StatusNotAcceptable, "application/vnd.api+json"},
+		{"/wow/map/color?theme=dark&orientation=pointy&offset=odd&hex-size=30", "", http.StatusOK, mediaSVG},
+		{"/wow/map/color?theme=plaid", "", http.StatusBadRequest, "application/vnd.api+json"},
+		{"/wow/map/color?orientation=sideways", "", http.StatusBadRequest, "application/vnd.api+json"},
+		{"/wow/map/color?hex-size=5000", "", http.StatusBadRequest, "application/vnd.api+json"},
+		{"/wow/map/color", "text/html,*/*;q=0.8", http.StatusOK, mediaSVG},
+		{"/wow/map/color?format=html&theme=dark", "", http.StatusOK, "text/html; charset=utf-8"},
+		{"/wow/map/color?format=html&theme=plaid", "", http.StatusBadRequest, "application/vnd.api+json"},
+	} {
+		r := httptest.NewRequest("GET", tc.target, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != tc.status || w.Header().Get("Content-Type") != tc.contentType {
+			t.Errorf("%s %q: want %d %s, got %d %s", tc.target, tc.accept, tc.status, tc.contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestRenderOptionsNotFinite(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"hex-size", "font-size", "stroke-width", "warp-width", "margin", "dpi"} {
+		for _, value := range []string{"NaN", "Inf", "-Inf"} {
+			for _, format := range []string{"svg", "png", "pdf", "html"} {
+				if name == "dpi" && format != "png" {
+					continue // only png images use the resolution
+				}
+				target := "/wow/map/color?format=" + format + "&" + name + "=" + value
+				w := httptest.NewRecorder()
+				s.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("%s: want %d, got %d", target, http.StatusBadRequest, w.Code)
+				}
+			}
+		}
+	}
+}
+
+func TestHitMap(t *testing.T) {
+	s := newTestServer(t)
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", `{"name": "hit", "nodes": [{"name": "Ur", "col": 2, "row": 3, "econ-value": 4}]}`, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	// find the star on the svg of the map
+	r := httptest.NewRequest("GET", "/wow/api/maps/"+m.ID+"?hex-size=30", nil)
+	r.Header.Set("Accept", mediaSVG)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	match := regexp.MustCompile(`<circle cx="([0-9.]+)" cy="([0-9.]+)"`).FindStringSubmatch(w.Body.String())
+	if match == nil {
+		t.Fatalf("svg: no star in\n%s", w.Body)
+	}
+	x, _ := strconv.ParseFloat(match[1], 64)
+	y, _ := strconv.ParseFloat(match[2], 64)
+
+	for _, tc := range []struct {
+		name   string
+		query  string
+		status int
+		hex    string
+		star   string
+	}{
+		{"star", fmt.Sprintf("x=%g&y=%g&hex-size=30", x, y), http.StatusOK, "0203", "Ur"},
+		{"png", fmt.Sprintf("x=%g&y=%g&hex-size=30&dpi=192", 2*x, 2*y), http.StatusOK, "0203", "Ur"},
+		{"next hex", fmt.Sprintf("x=%g&y=%g&hex-size=30", x, y+52), http.StatusOK, "0204", ""},
+		{"margin", "x=1&y=1", http.StatusNotFound, "", ""},
+		{"missing y", "x=1", http.StatusBadRequest, "", ""},
+		{"bad dpi", "x=1&y=1&dpi=1", http.StatusBadRequest, "", ""},
+	} {
+		var hit hitResponse
+		w := do(t, s, "GET", "/wow/api/maps/"+m.ID+"/hit?"+tc.query, "", "", "", &hit)
+		if w.Code != tc.status {
+			t.Errorf("%s: want %d, got %d: %s", tc.name, tc.status, w.Code, w.Body)
+		} else if w.Code == http.StatusOK && (hit.Hex != tc.hex || (hit.Star == nil) != (tc.star == "") || (hit.Star != nil && hit.Star.Name != tc.star)) {
+			t.Errorf("%s: want %s %q, got %+v", tc.name, tc.hex, tc.star, hit)
+		}
+	}
+	if w := do(t, s, "GET", "/wow/api/maps/nope/hit?x=1&y=1", "", "", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown map: want %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// errorObject, errResponse, and okResponse are the envelope for all JSON responses.
+type errorObject struct {
+	Code   int    `json:"code,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+type errResponse struct {
+	Status string        `json:"status"`
+	Errors []errorObject `json:"errors,omitempty"`
+}
+type okResponse struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data"`
+}
+
+// jsonOK writes the data wrapped in an okResponse.
+func jsonOK(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(okResponse{Status: "ok", Data: data})
+}
+
+// jsonError writes the detail wrapped in an errResponse.
+func jsonError(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errResponse{
+		Status: "error",
+		Errors: []errorObject{{Code: status, Detail: detail}},
+	})
+}
+
+// errorPage is the HTML version of an error response, for browsers.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Wars of Warp</title></head>
+<body>
+<h1>{{.Status}}</h1>
+<p>Sorry, but there was a problem with the request.</p>
+<ul>{{range .Details}}
+    <li>{{.}}</li>{{end}}
+</ul>
+<p><a href="/wow">Back to the map generator</a></p>
+</body>
+</html>
+`))
+
+// writeError sends an error response in the format the client prefers.
+// Browsers get an HTML page; everyone else gets an errResponse with an
+// error object for each detail.
+func writeError(w http.ResponseWriter, r *http.Request, status int, details ...string) {
+	if negotiateAccept(r.Header.Get("Accept"), "application/vnd.api+json", "application/json", "text/html") != "text/html" {
+		response := errResponse{Status: "error"}
+		for _, detail := range details {
+			response.Errors = append(response.Errors, errorObject{Code: status, Detail: detail})
+		}
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(response)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = errorPage.Execute(w, struct {
+		Status  string
+		Details []string
+	}{Status: fmt.Sprintf("%d %s", status, http.StatusText(status)), Details: details})
+}
+
+// decodeJSON reads a single JSON object from the request body.
+// It rejects unknown fields and bodies larger than maxBytes.
+func decodeJSON(w http.ResponseWriter, r *http.Request, maxBytes int64, v interface{}) error {
+	if mediaType(r) != "application/json" {
+		return fmt.Errorf("content type must be application/json")
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid json object")
+	}
+	// call decode again to confirm that the request contained only a single JSON object
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return fmt.Errorf("request body must only contain a single json object")
+	}
+	return nil
+}
+
+// mediaType returns the media type of the request body, without any parameters.
+func mediaType(r *http.Request) string {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mt
+}
@@ -34,9 +34,32 @@ import (
 func (s *Server) Routes(public string) http.Handler {
 	s.router = way.NewRouter()
 	s.router.Handle("GET", "/wow", s.handleIndex(public, 40, 40))
-	s.router.Handle("GET", "/wow/map/color", s.handleStandardMap(public, true))
-	s.router.Handle("GET", "/wow/map/mono", s.handleStandardMap(public, false))
+	s.router.Handle("GET", "/wow/map/color", s.handleStandardMap(true))
+	s.router.Handle("GET", "/wow/map/mono", s.handleStandardMap(false))
 	s.router.HandleFunc("GET", "/wow/map/random", s.handleRandomMap())
 	s.router.HandleFunc("POST", "/wow/api/map-data", s.handlePostMapData())
+	s.router.HandleFunc("GET", "/wow/editor", s.handleEditor(public))
+	s.router.HandleFunc("POST", "/wow/api/map-data/validate", s.handleValidateMapData())
+
+	// the game api needs a store to keep the maps and games in.
+	// creating maps and games is restricted to the admin; game masters
+	// run their games and see their maps; and players may only see and
+	// change their own data.
+	if s.store != nil {
+		s.router.HandleFunc("GET", "/wow/api/maps", s.handleListMaps())
+		s.router.HandleFunc("POST", "/wow/api/maps", s.authorize(s.handleCreateMap(), roleAdmin))
+		s.router.HandleFunc("GET", "/wow/api/maps/:id", s.authorizeMap(s.handleGetMap()))
+		s.router.HandleFunc("GET", "/wow/api/maps/:id/hit", s.authorizeMap(s.handleHitMap()))
+		s.router.HandleFunc("POST", "/wow/api/games", s.authorize(s.handleCreateGame(), roleAdmin))
+		s.router.HandleFunc("GET", "/wow/api/games/:id", s.handleGetGame())
+		s.router.HandleFunc("POST", "/wow/api/games/:id/players", s.handleJoinGame())
+		s.router.HandleFunc("GET", "/wow/api/games/:id/players/:pid/orders", s.authorize(s.handleGetOrders(), rolePlayer, roleGM, roleAdmin))
+		s.router.HandleFunc("PUT", "/wow/api/games/:id/players/:pid/orders", s.authorize(s.handlePutOrders(), rolePlayer))
+		s.router.HandleFunc("GET", "/wow/api/games/:id/players/:pid/report", s.authorize(s.handleGetReport(), rolePlayer, roleGM, roleAdmin))
+		s.router.HandleFunc("GET", "/wow/api/games/:id/players/:pid/map", s.authorize(s.handleGetPlayerMap(), rolePlayer, roleGM, roleAdmin))
+		s.router.HandleFunc("PUT", "/wow/api/games/:id/schedule", s.authorize(s.handleSetSchedule(), roleGM, roleAdmin))
+		s.router.HandleFunc("POST", "/wow/api/games/:id/turn", s.authorize(s.handleProcessTurn(), roleGM, roleAdmin))
+	}
+
 	return s.router
 }
@@ -0,0 +1,93 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/mdhender/wow/pkg/game"
+	"log"
+	"time"
+)
+
+// RunScheduler processes turns when they are due and sends reminders
+// to players who haven't sent orders by remind before the deadline.
+// It checks the games every interval until the context is cancelled.
+// The schedule is kept with the game, so a restarted server picks up
+// where it left off; turns that came due while it was down run at once.
+func (s *Server) RunScheduler(ctx context.Context, interval, remind time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.checkSchedules(time.Now(), remind)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSchedules runs one pass of the scheduler.
+func (s *Server) checkSchedules(now time.Time, remind time.Duration) {
+	games, err := s.store.ListGames()
+	if err != nil {
+		log.Printf("[scheduler] %v\n", err)
+		return
+	}
+	for _, g := range games {
+		if g.Schedule == "" {
+			continue
+		}
+		if due, err := s.engine.Due(g, now); err != nil {
+			log.Printf("[scheduler] game %s: %v\n", g.ID, err)
+			continue
+		} else if due {
+			// a game master may have run the turn since the games were listed
+			if _, err := s.processTurn(g.ID, g.Turn, now); errors.Is(err, game.ErrTurnProcessed) {
+				continue
+			} else if err != nil {
+				log.Printf("[scheduler] game %s: turn %d: %v\n", g.ID, g.Turn, err)
+			} else {
+				log.Printf("[scheduler] game %s: processed turn %d\n", g.ID, g.Turn)
+			}
+			continue
+		}
+		if s.mail == nil || g.Reminded || g.Deadline.IsZero() || now.Before(g.Deadline.Add(-remind)) {
+			continue
+		}
+		waiting, err := s.engine.Waiting(g.ID)
+		if err != nil {
+			log.Printf("[scheduler] game %s: %v\n", g.ID, err)
+			continue
+		}
+		if err := s.mail.Remind(g, waiting); err != nil {
+			log.Printf("[scheduler] game %s: reminders: %v\n", g.ID, err)
+		}
+		if err := s.engine.MarkReminded(g.ID, g.Deadline); err != nil {
+			log.Printf("[scheduler] game %s: %v\n", g.ID, err)
+		}
+	}
+}
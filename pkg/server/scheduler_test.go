@@ -0,0 +1,92 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package server
+
+import (
+	"github.com/mdhender/wow/pkg/store"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// listHook is a store that calls after each time the games are listed.
+type listHook struct {
+	store.Store
+	after func()
+}
+
+func (l listHook) ListGames() ([]*store.Game, error) {
+	games, err := l.Store.ListGames()
+	l.after()
+	return games, err
+}
+
+func TestSchedulerProcessesDueTurn(t *testing.T) {
+	s := newTestServer(t)
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", `{"name": "pair", "nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "warps": ["Adab"]}, {"name": "Adab", "col": 3, "row": 2}]}`, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	var g gameResponse
+	if w := do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`", "schedule": "mon 18:00"}`, &g); w.Code != http.StatusCreated {
+		t.Fatalf("create game: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	// the next deadline is set from the time the scheduler runs
+	s.checkSchedules(g.Deadline.Add(time.Minute), time.Hour)
+	if got, err := s.store.GetGame(g.ID); err != nil {
+		t.Fatal(err)
+	} else if want := g.Deadline.Add(7 * 24 * time.Hour); got.Turn != 2 || !got.Deadline.Equal(want) {
+		t.Errorf("want turn 2 due %v, got turn %d due %v", want, got.Turn, got.Deadline)
+	}
+}
+
+func TestSchedulerRacesGM(t *testing.T) {
+	s := newTestServer(t)
+	var m mapResponse
+	if w := do(t, s, "POST", "/wow/api/maps", testAdminToken, "application/json", `{"name": "pair", "nodes": [
+		{"name": "Ur", "col": 1, "row": 1, "warps": ["Adab"]}, {"name": "Adab", "col": 3, "row": 2}]}`, &m); w.Code != http.StatusCreated {
+		t.Fatalf("create map: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+	var g gameResponse
+	if w := do(t, s, "POST", "/wow/api/games", testAdminToken, "application/json", `{"name": "test", "map-id": "`+m.ID+`", "schedule": "mon 18:00"}`, &g); w.Code != http.StatusCreated {
+		t.Fatalf("create game: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+	}
+
+	// the game master runs the turn after the scheduler has listed the games
+	s.store = listHook{Store: s.store, after: func() {
+		if w := do(t, s, "POST", "/wow/api/games/"+g.ID+"/turn", g.GMToken, "", "", nil); w.Code != http.StatusOK {
+			t.Errorf("gm: process turn: want %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+		}
+	}}
+	s.checkSchedules(g.Deadline.Add(time.Minute), time.Hour)
+
+	if got, err := s.store.GetGame(g.ID); err != nil {
+		t.Fatal(err)
+	} else if got.Turn != 2 {
+		t.Errorf("want turn 2, got %d", got.Turn)
+	}
+}
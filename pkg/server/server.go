@@ -27,17 +27,79 @@ package server
 
 import (
 	"github.com/mdhender/wow/internal/way"
+	"github.com/mdhender/wow/pkg/game"
+	"github.com/mdhender/wow/pkg/pbem"
+	"github.com/mdhender/wow/pkg/store"
+	"log"
 	"net/http"
+	"time"
 )
 
 // Server is our server data.
 type Server struct {
-	router *way.Router
+	router     *way.Router
+	store      store.Store
+	engine     *game.Engine
+	adminToken string
+	mail       *pbem.Gateway
 }
 
+// Option is a function that configures the server.
+type Option func(*Server) error
+
 // New returns an initialized server.
-func New() (*Server, error) {
-	return &Server{}, nil
+func New(options ...Option) (*Server, error) {
+	s := &Server{}
+	for _, option := range options {
+		if err := option(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WithStore sets the store used to persist maps and games.
+// The caller is responsible for migrating and closing the store.
+func WithStore(st store.Store) Option {
+	return func(s *Server) error {
+		s.store = st
+		s.engine = game.New(st)
+		return nil
+	}
+}
+
+// WithEngine sets the game engine.
+// It must come after WithStore, which creates a default engine.
+// Use it when something else, like the mail gateway, shares the engine.
+func WithEngine(e *game.Engine) Option {
+	return func(s *Server) error {
+		s.engine = e
+		return nil
+	}
+}
+
+// WithMailGateway sets the gateway used to mail turn reports to players.
+func WithMailGateway(gw *pbem.Gateway) Option {
+	return func(s *Server) error {
+		s.mail = gw
+		return nil
+	}
+}
+
+// processTurn processes a turn of a game (see game.Engine.ProcessTurn)
+// and mails out the reports.
+// Problems sending mail are logged; they don't undo the turn.
+func (s *Server) processTurn(gameID string, turn int, now time.Time) (*store.Game, error) {
+	g, err := s.engine.ProcessTurn(gameID, turn, now)
+	if err != nil {
+		return nil, err
+	}
+	if s.mail != nil {
+		if err := s.mail.DeliverReports(g.ID, g.Turn-1); err != nil {
+			log.Printf("[server] game %s: turn %d: reports: %v\n", g.ID, g.Turn-1, err)
+		}
+	}
+	return g, nil
 }
 
 // ServeHTTP implements the http.Handler interface.
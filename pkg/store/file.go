@@ -0,0 +1,420 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore keeps each record as a JSON file.
+// The layout of the directory tree is
+//
+//	root/version
+//	root/maps/{map}.json
+//	root/games/{game}/game.json
+//	root/games/{game}/players/{player}.json
+//	root/games/{game}/orders/{turn}/{player}.json
+//	root/games/{game}/reports/{turn}/{player}.json
+type FileStore struct {
+	sync.Mutex
+	root string
+}
+
+// NewFileStore returns a store rooted at the given directory.
+func NewFileStore(root string) (*FileStore, error) {
+	if root == "" {
+		return nil, errors.New("store: missing root directory")
+	}
+	return &FileStore{root: filepath.Clean(root)}, nil
+}
+
+// fileMigrations are applied in order. The version file records
+// the number of migrations that have been applied.
+var fileMigrations = []func(root string) error{
+	func(root string) error {
+		for _, dir := range []string{"maps", "games"} {
+			if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// Migrate implements the Store interface.
+func (f *FileStore) Migrate() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := os.MkdirAll(f.root, 0755); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	version := 0
+	if data, err := os.ReadFile(filepath.Join(f.root, "version")); err == nil {
+		if version, err = strconv.Atoi(strings.TrimSpace(string(data))); err != nil {
+			return fmt.Errorf("store: migrate: version: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	for ; version < len(fileMigrations); version++ {
+		if err := fileMigrations[version](f.root); err != nil {
+			return fmt.Errorf("store: migrate: %d: %w", version+1, err)
+		}
+		if err := os.WriteFile(filepath.Join(f.root, "version"), []byte(fmt.Sprintf("%d\n", version+1)), 0644); err != nil {
+			return fmt.Errorf("store: migrate: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close implements the Store interface.
+func (f *FileStore) Close() error {
+	return nil
+}
+
+func (f *FileStore) CreateMap(m *Map) error {
+	f.Lock()
+	defer f.Unlock()
+	if m.ID == "" {
+		m.ID = NewID()
+	}
+	if err := checkID(m.ID); err != nil {
+		return err
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now().UTC()
+	}
+	return f.create(m, "maps", m.ID+".json")
+}
+
+func (f *FileStore) GetMap(id string) (*Map, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	var m Map
+	if err := f.read(&m, "maps", id+".json"); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (f *FileStore) ListMaps() ([]*Map, error) {
+	f.Lock()
+	defer f.Unlock()
+	names, err := f.list("maps")
+	if err != nil {
+		return nil, err
+	}
+	var maps []*Map
+	for _, name := range names {
+		var m Map
+		if err := f.read(&m, "maps", name); err != nil {
+			return nil, err
+		}
+		maps = append(maps, &m)
+	}
+	return maps, nil
+}
+
+func (f *FileStore) CreateGame(g *Game) error {
+	f.Lock()
+	defer f.Unlock()
+	if g.ID == "" {
+		g.ID = NewID()
+	}
+	if err := checkID(g.ID, g.MapID); err != nil {
+		return err
+	}
+	if g.Created.IsZero() {
+		g.Created = time.Now().UTC()
+	}
+	if _, err := os.Stat(f.path("maps", g.MapID+".json")); err != nil {
+		return fmt.Errorf("map %q: %w", g.MapID, ErrNotFound)
+	}
+	return f.create(g, "games", g.ID, "game.json")
+}
+
+func (f *FileStore) GetGame(id string) (*Game, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	var g Game
+	if err := f.read(&g, "games", id, "game.json"); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (f *FileStore) ListGames() ([]*Game, error) {
+	f.Lock()
+	defer f.Unlock()
+	entries, err := os.ReadDir(f.path("games"))
+	if err != nil {
+		return nil, err
+	}
+	var games []*Game
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		var g Game
+		if err := f.read(&g, "games", entry.Name(), "game.json"); err != nil {
+			return nil, err
+		}
+		games = append(games, &g)
+	}
+	return games, nil
+}
+
+func (f *FileStore) UpdateGame(g *Game) error {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(g.ID); err != nil {
+		return err
+	}
+	return f.update(g, "games", g.ID, "game.json")
+}
+
+func (f *FileStore) CreatePlayer(p *Player) error {
+	f.Lock()
+	defer f.Unlock()
+	if p.ID == "" {
+		p.ID = NewID()
+	}
+	if err := checkID(p.GameID, p.ID); err != nil {
+		return err
+	}
+	if p.Joined.IsZero() {
+		p.Joined = time.Now().UTC()
+	}
+	if _, err := os.Stat(f.path("games", p.GameID, "game.json")); err != nil {
+		return fmt.Errorf("game %q: %w", p.GameID, ErrNotFound)
+	}
+	return f.create(p, "games", p.GameID, "players", p.ID+".json")
+}
+
+func (f *FileStore) GetPlayer(gameID, playerID string) (*Player, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(gameID, playerID); err != nil {
+		return nil, err
+	}
+	var p Player
+	if err := f.read(&p, "games", gameID, "players", playerID+".json"); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (f *FileStore) ListPlayers(gameID string) ([]*Player, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(gameID); err != nil {
+		return nil, err
+	}
+	names, err := f.list("games", gameID, "players")
+	if err != nil {
+		return nil, err
+	}
+	var players []*Player
+	for _, name := range names {
+		var p Player
+		if err := f.read(&p, "games", gameID, "players", name); err != nil {
+			return nil, err
+		}
+		players = append(players, &p)
+	}
+	return players, nil
+}
+
+func (f *FileStore) UpdatePlayer(p *Player) error {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(p.GameID, p.ID); err != nil {
+		return err
+	}
+	return f.update(p, "games", p.GameID, "players", p.ID+".json")
+}
+
+func (f *FileStore) PutOrders(o *Orders) error {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(o.GameID, o.PlayerID); err != nil {
+		return err
+	}
+	if o.Submitted.IsZero() {
+		o.Submitted = time.Now().UTC()
+	}
+	if _, err := os.Stat(f.path("games", o.GameID, "players", o.PlayerID+".json")); err != nil {
+		return fmt.Errorf("player %q: %w", o.PlayerID, ErrNotFound)
+	}
+	return f.write(o, "games", o.GameID, "orders", strconv.Itoa(o.Turn), o.PlayerID+".json")
+}
+
+func (f *FileStore) GetOrders(gameID, playerID string, turn int) (*Orders, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(gameID, playerID); err != nil {
+		return nil, err
+	}
+	var o Orders
+	if err := f.read(&o, "games", gameID, "orders", strconv.Itoa(turn), playerID+".json"); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (f *FileStore) ListOrders(gameID string, turn int) ([]*Orders, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(gameID); err != nil {
+		return nil, err
+	}
+	names, err := f.list("games", gameID, "orders", strconv.Itoa(turn))
+	if err != nil {
+		return nil, err
+	}
+	var orders []*Orders
+	for _, name := range names {
+		var o Orders
+		if err := f.read(&o, "games", gameID, "orders", strconv.Itoa(turn), name); err != nil {
+			return nil, err
+		}
+		orders = append(orders, &o)
+	}
+	return orders, nil
+}
+
+func (f *FileStore) PutReport(r *Report) error {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(r.GameID, r.PlayerID); err != nil {
+		return err
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now().UTC()
+	}
+	if _, err := os.Stat(f.path("games", r.GameID, "players", r.PlayerID+".json")); err != nil {
+		return fmt.Errorf("player %q: %w", r.PlayerID, ErrNotFound)
+	}
+	return f.write(r, "games", r.GameID, "reports", strconv.Itoa(r.Turn), r.PlayerID+".json")
+}
+
+func (f *FileStore) GetReport(gameID, playerID string, turn int) (*Report, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err := checkID(gameID, playerID); err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := f.read(&r, "games", gameID, "reports", strconv.Itoa(turn), playerID+".json"); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (f *FileStore) path(elem ...string) string {
+	return filepath.Join(append([]string{f.root}, elem...)...)
+}
+
+// create writes a new file, returning ErrDuplicate if it already exists.
+func (f *FileStore) create(v interface{}, elem ...string) error {
+	if _, err := os.Stat(f.path(elem...)); err == nil {
+		return ErrDuplicate
+	}
+	return f.write(v, elem...)
+}
+
+// update replaces an existing file, returning ErrNotFound if it doesn't exist.
+func (f *FileStore) update(v interface{}, elem ...string) error {
+	if _, err := os.Stat(f.path(elem...)); err != nil {
+		return ErrNotFound
+	}
+	return f.write(v, elem...)
+}
+
+// write saves the value as JSON. It writes to a temporary file and
+// renames it so that readers never see a partially written record.
+func (f *FileStore) write(v interface{}, elem ...string) error {
+	name := f.path(elem...)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (f *FileStore) read(v interface{}, elem ...string) error {
+	data, err := os.ReadFile(f.path(elem...))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// list returns the sorted names of the JSON files in a directory.
+// A missing directory is treated as empty.
+func (f *FileStore) list(elem ...string) ([]string, error) {
+	entries, err := os.ReadDir(f.path(elem...))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// compile-time check that the file store implements the interface
+var _ Store = (*FileStore)(nil)
@@ -0,0 +1,452 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// pure-go sqlite driver, so no cgo is needed
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps all the records in an embedded SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database file.
+func NewSQLiteStore(filename string) (*SQLiteStore, error) {
+	if filename == "" {
+		return nil, errors.New("store: missing database file")
+	}
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, fmt.Errorf("store: %w", err)
+	}
+	// sqlite allows only one writer, so serialize access through a single connection.
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{"PRAGMA foreign_keys = ON", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("store: %w", err)
+		}
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// sqliteMigrations are applied in order, each in its own transaction.
+// The schema_migrations table records the ones that have been applied.
+// Never change a migration that has been released; add a new one instead.
+var sqliteMigrations = []string{
+	// 1: initial schema
+	`CREATE TABLE maps (
+		id      TEXT PRIMARY KEY,
+		name    TEXT NOT NULL,
+		data    BLOB NOT NULL,
+		created TEXT NOT NULL
+	);
+	CREATE TABLE games (
+		id      TEXT PRIMARY KEY,
+		name    TEXT NOT NULL,
+		map_id  TEXT NOT NULL REFERENCES maps(id),
+		turn    INTEGER NOT NULL,
+		created TEXT NOT NULL
+	);
+	CREATE TABLE players (
+		game_id TEXT NOT NULL REFERENCES games(id),
+		id      TEXT NOT NULL,
+		name    TEXT NOT NULL,
+		email   TEXT NOT NULL,
+		joined  TEXT NOT NULL,
+		PRIMARY KEY (game_id, id)
+	);
+	CREATE TABLE orders (
+		game_id   TEXT NOT NULL,
+		player_id TEXT NOT NULL,
+		turn      INTEGER NOT NULL,
+		text      TEXT NOT NULL,
+		submitted TEXT NOT NULL,
+		PRIMARY KEY (game_id, player_id, turn),
+		FOREIGN KEY (game_id, player_id) REFERENCES players(game_id, id)
+	);
+	CREATE TABLE reports (
+		game_id   TEXT NOT NULL,
+		player_id TEXT NOT NULL,
+		turn      INTEGER NOT NULL,
+		text      TEXT NOT NULL,
+		created   TEXT NOT NULL,
+		PRIMARY KEY (game_id, player_id, turn),
+		FOREIGN KEY (game_id, player_id) REFERENCES players(game_id, id)
+	);`,
+	// 2: track the stars controlled by each player
+	`ALTER TABLE players ADD COLUMN home TEXT NOT NULL DEFAULT '';
+	ALTER TABLE players ADD COLUMN stars TEXT NOT NULL DEFAULT '[]';`,
+	// 3: hashed tokens for players and game masters
+	`ALTER TABLE games ADD COLUMN gm_token TEXT NOT NULL DEFAULT '';
+	ALTER TABLE players ADD COLUMN token TEXT NOT NULL DEFAULT '';`,
+	// 4: turn schedules
+	`ALTER TABLE games ADD COLUMN schedule TEXT NOT NULL DEFAULT '';
+	ALTER TABLE games ADD COLUMN deadline TEXT NOT NULL DEFAULT '';
+	ALTER TABLE games ADD COLUMN reminded INTEGER NOT NULL DEFAULT 0;`,
+}
+
+// Migrate implements the Store interface.
+func (s *SQLiteStore) Migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied TEXT NOT NULL)`); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	var version int
+	if err := s.db.QueryRow(`SELECT IFNULL(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("store: migrate: %w", err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("store: migrate: %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`, version+1, formatTime(time.Now())); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("store: migrate: %d: %w", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("store: migrate: %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// Close implements the Store interface.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) CreateMap(m *Map) error {
+	if m.ID == "" {
+		m.ID = NewID()
+	}
+	if err := checkID(m.ID); err != nil {
+		return err
+	}
+	if m.Created.IsZero() {
+		m.Created = time.Now().UTC()
+	}
+	_, err := s.db.Exec(`INSERT INTO maps (id, name, data, created) VALUES (?, ?, ?, ?)`,
+		m.ID, m.Name, m.Data, formatTime(m.Created))
+	return sqliteError(err)
+}
+
+func (s *SQLiteStore) GetMap(id string) (*Map, error) {
+	maps, err := s.queryMaps(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	} else if len(maps) == 0 {
+		return nil, ErrNotFound
+	}
+	return maps[0], nil
+}
+
+func (s *SQLiteStore) ListMaps() ([]*Map, error) {
+	return s.queryMaps(``)
+}
+
+func (s *SQLiteStore) queryMaps(where string, args ...interface{}) ([]*Map, error) {
+	rows, err := s.db.Query(`SELECT id, name, data, created FROM maps `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var maps []*Map
+	for rows.Next() {
+		var m Map
+		var created string
+		if err := rows.Scan(&m.ID, &m.Name, &m.Data, &created); err != nil {
+			return nil, err
+		}
+		m.Created = parseTime(created)
+		maps = append(maps, &m)
+	}
+	return maps, rows.Err()
+}
+
+func (s *SQLiteStore) CreateGame(g *Game) error {
+	if g.ID == "" {
+		g.ID = NewID()
+	}
+	if err := checkID(g.ID, g.MapID); err != nil {
+		return err
+	}
+	if g.Created.IsZero() {
+		g.Created = time.Now().UTC()
+	}
+	if _, err := s.GetMap(g.MapID); err != nil {
+		return fmt.Errorf("map %q: %w", g.MapID, err)
+	}
+	_, err := s.db.Exec(`INSERT INTO games (id, name, map_id, turn, created, gm_token, schedule, deadline, reminded) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		g.ID, g.Name, g.MapID, g.Turn, formatTime(g.Created), g.GMToken, g.Schedule, formatDeadline(g.Deadline), g.Reminded)
+	return sqliteError(err)
+}
+
+func (s *SQLiteStore) GetGame(id string) (*Game, error) {
+	games, err := s.queryGames(`WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	} else if len(games) == 0 {
+		return nil, ErrNotFound
+	}
+	return games[0], nil
+}
+
+func (s *SQLiteStore) ListGames() ([]*Game, error) {
+	return s.queryGames(``)
+}
+
+func (s *SQLiteStore) queryGames(where string, args ...interface{}) ([]*Game, error) {
+	rows, err := s.db.Query(`SELECT id, name, map_id, turn, created, gm_token, schedule, deadline, reminded FROM games `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var games []*Game
+	for rows.Next() {
+		var g Game
+		var created, deadline string
+		if err := rows.Scan(&g.ID, &g.Name, &g.MapID, &g.Turn, &created, &g.GMToken, &g.Schedule, &deadline, &g.Reminded); err != nil {
+			return nil, err
+		}
+		g.Created, g.Deadline = parseTime(created), parseTime(deadline)
+		games = append(games, &g)
+	}
+	return games, rows.Err()
+}
+
+func (s *SQLiteStore) UpdateGame(g *Game) error {
+	result, err := s.db.Exec(`UPDATE games SET name = ?, turn = ?, gm_token = ?, schedule = ?, deadline = ?, reminded = ? WHERE id = ?`,
+		g.Name, g.Turn, g.GMToken, g.Schedule, formatDeadline(g.Deadline), g.Reminded, g.ID)
+	return sqliteUpdated(result, err)
+}
+
+func (s *SQLiteStore) CreatePlayer(p *Player) error {
+	if p.ID == "" {
+		p.ID = NewID()
+	}
+	if err := checkID(p.GameID, p.ID); err != nil {
+		return err
+	}
+	if p.Joined.IsZero() {
+		p.Joined = time.Now().UTC()
+	}
+	if _, err := s.GetGame(p.GameID); err != nil {
+		return fmt.Errorf("game %q: %w", p.GameID, err)
+	}
+	stars, err := json.Marshal(nonNil(p.Stars))
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO players (game_id, id, name, email, joined, home, stars, token) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.GameID, p.ID, p.Name, p.Email, formatTime(p.Joined), p.Home, string(stars), p.Token)
+	return sqliteError(err)
+}
+
+func (s *SQLiteStore) GetPlayer(gameID, playerID string) (*Player, error) {
+	players, err := s.queryPlayers(`WHERE game_id = ? AND id = ?`, gameID, playerID)
+	if err != nil {
+		return nil, err
+	} else if len(players) == 0 {
+		return nil, ErrNotFound
+	}
+	return players[0], nil
+}
+
+func (s *SQLiteStore) ListPlayers(gameID string) ([]*Player, error) {
+	return s.queryPlayers(`WHERE game_id = ?`, gameID)
+}
+
+func (s *SQLiteStore) queryPlayers(where string, args ...interface{}) ([]*Player, error) {
+	rows, err := s.db.Query(`SELECT game_id, id, name, email, joined, home, stars, token FROM players `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var players []*Player
+	for rows.Next() {
+		var p Player
+		var joined, stars string
+		if err := rows.Scan(&p.GameID, &p.ID, &p.Name, &p.Email, &joined, &p.Home, &stars, &p.Token); err != nil {
+			return nil, err
+		}
+		p.Joined = parseTime(joined)
+		if err := json.Unmarshal([]byte(stars), &p.Stars); err != nil {
+			return nil, err
+		}
+		players = append(players, &p)
+	}
+	return players, rows.Err()
+}
+
+func (s *SQLiteStore) UpdatePlayer(p *Player) error {
+	stars, err := json.Marshal(nonNil(p.Stars))
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`UPDATE players SET name = ?, email = ?, home = ?, stars = ?, token = ? WHERE game_id = ? AND id = ?`,
+		p.Name, p.Email, p.Home, string(stars), p.Token, p.GameID, p.ID)
+	return sqliteUpdated(result, err)
+}
+
+func (s *SQLiteStore) PutOrders(o *Orders) error {
+	if err := checkID(o.GameID, o.PlayerID); err != nil {
+		return err
+	}
+	if o.Submitted.IsZero() {
+		o.Submitted = time.Now().UTC()
+	}
+	if _, err := s.GetPlayer(o.GameID, o.PlayerID); err != nil {
+		return fmt.Errorf("player %q: %w", o.PlayerID, err)
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO orders (game_id, player_id, turn, text, submitted) VALUES (?, ?, ?, ?, ?)`,
+		o.GameID, o.PlayerID, o.Turn, o.Text, formatTime(o.Submitted))
+	return sqliteError(err)
+}
+
+func (s *SQLiteStore) GetOrders(gameID, playerID string, turn int) (*Orders, error) {
+	orders, err := s.queryOrders(`WHERE game_id = ? AND player_id = ? AND turn = ?`, gameID, playerID, turn)
+	if err != nil {
+		return nil, err
+	} else if len(orders) == 0 {
+		return nil, ErrNotFound
+	}
+	return orders[0], nil
+}
+
+func (s *SQLiteStore) ListOrders(gameID string, turn int) ([]*Orders, error) {
+	return s.queryOrders(`WHERE game_id = ? AND turn = ?`, gameID, turn)
+}
+
+func (s *SQLiteStore) queryOrders(where string, args ...interface{}) ([]*Orders, error) {
+	rows, err := s.db.Query(`SELECT game_id, player_id, turn, text, submitted FROM orders `+where+` ORDER BY player_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var orders []*Orders
+	for rows.Next() {
+		var o Orders
+		var submitted string
+		if err := rows.Scan(&o.GameID, &o.PlayerID, &o.Turn, &o.Text, &submitted); err != nil {
+			return nil, err
+		}
+		o.Submitted = parseTime(submitted)
+		orders = append(orders, &o)
+	}
+	return orders, rows.Err()
+}
+
+func (s *SQLiteStore) PutReport(r *Report) error {
+	if err := checkID(r.GameID, r.PlayerID); err != nil {
+		return err
+	}
+	if r.Created.IsZero() {
+		r.Created = time.Now().UTC()
+	}
+	if _, err := s.GetPlayer(r.GameID, r.PlayerID); err != nil {
+		return fmt.Errorf("player %q: %w", r.PlayerID, err)
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO reports (game_id, player_id, turn, text, created) VALUES (?, ?, ?, ?, ?)`,
+		r.GameID, r.PlayerID, r.Turn, r.Text, formatTime(r.Created))
+	return sqliteError(err)
+}
+
+func (s *SQLiteStore) GetReport(gameID, playerID string, turn int) (*Report, error) {
+	row := s.db.QueryRow(`SELECT game_id, player_id, turn, text, created FROM reports WHERE game_id = ? AND player_id = ? AND turn = ?`,
+		gameID, playerID, turn)
+	var r Report
+	var created string
+	if err := row.Scan(&r.GameID, &r.PlayerID, &r.Turn, &r.Text, &created); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	r.Created = parseTime(created)
+	return &r, nil
+}
+
+// formatTime and parseTime store times as sortable UTC text.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// formatDeadline stores a missing deadline as an empty string.
+func formatDeadline(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatTime(t)
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// nonNil makes sure that an empty list is stored as [] rather than null.
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// sqliteError maps constraint violations to ErrDuplicate.
+func sqliteError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// sqliteUpdated returns ErrNotFound if the update didn't change any rows.
+func sqliteUpdated(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// compile-time check that the sqlite store implements the interface
+var _ Store = (*SQLiteStore)(nil)
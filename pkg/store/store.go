@@ -0,0 +1,172 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package store implements persistent storage for maps, games, players,
+// orders and reports.
+//
+// There are two backends. The file backend keeps one JSON file per record
+// in a directory tree. The sqlite backend keeps everything in a single
+// embedded database. Both must have Migrate called before they are used.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrDuplicate = errors.New("duplicate")
+	ErrInvalidID = errors.New("invalid id")
+	ErrNotFound  = errors.New("not found")
+)
+
+// Store is the interface implemented by all the storage backends.
+type Store interface {
+	// Migrate applies any pending schema changes.
+	Migrate() error
+	// Close releases any resources held by the store.
+	Close() error
+
+	CreateMap(m *Map) error
+	GetMap(id string) (*Map, error)
+	ListMaps() ([]*Map, error)
+
+	CreateGame(g *Game) error
+	GetGame(id string) (*Game, error)
+	ListGames() ([]*Game, error)
+	UpdateGame(g *Game) error
+
+	CreatePlayer(p *Player) error
+	GetPlayer(gameID, playerID string) (*Player, error)
+	ListPlayers(gameID string) ([]*Player, error)
+	UpdatePlayer(p *Player) error
+
+	// PutOrders creates or replaces the orders for a player and turn.
+	PutOrders(o *Orders) error
+	GetOrders(gameID, playerID string, turn int) (*Orders, error)
+	ListOrders(gameID string, turn int) ([]*Orders, error)
+
+	// PutReport creates or replaces the report for a player and turn.
+	PutReport(r *Report) error
+	GetReport(gameID, playerID string, turn int) (*Report, error)
+}
+
+// Map is a saved map.
+// Data holds the nodes in the JSON format described in the README.
+type Map struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Data    []byte    `json:"data"`
+	Created time.Time `json:"created"`
+}
+
+// Game is a game played on a saved map.
+type Game struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	MapID   string    `json:"map-id"`
+	Turn    int       `json:"turn"`
+	Created time.Time `json:"created"`
+	// GMToken is the hash of the token that lets the game master run the game.
+	GMToken string `json:"gm-token"`
+	// Schedule says when turns are processed; see game.ParseSchedule.
+	// An empty schedule means turns are only processed by hand.
+	Schedule string `json:"schedule,omitempty"`
+	// Deadline is when the current turn will be processed.
+	// It is zero if the schedule doesn't have a fixed time.
+	Deadline time.Time `json:"deadline"`
+	// Reminded is set once reminders have been sent for the current deadline.
+	Reminded bool `json:"reminded,omitempty"`
+}
+
+// Player is a player in a game.
+type Player struct {
+	GameID string    `json:"game-id"`
+	ID     string    `json:"id"`
+	Name   string    `json:"name"`
+	Email  string    `json:"email,omitempty"`
+	Joined time.Time `json:"joined"`
+	// Home is the name of the player's home star.
+	Home string `json:"home"`
+	// Stars are the names of the stars controlled by the player.
+	Stars []string `json:"stars,omitempty"`
+	// Token is the hash of the player's secret token.
+	Token string `json:"token"`
+}
+
+// Orders are the orders submitted by a player for a single turn.
+type Orders struct {
+	GameID    string    `json:"game-id"`
+	PlayerID  string    `json:"player-id"`
+	Turn      int       `json:"turn"`
+	Text      string    `json:"text"`
+	Submitted time.Time `json:"submitted"`
+}
+
+// Report is the report generated for a player at the end of a turn.
+type Report struct {
+	GameID   string    `json:"game-id"`
+	PlayerID string    `json:"player-id"`
+	Turn     int       `json:"turn"`
+	Text     string    `json:"text"`
+	Created  time.Time `json:"created"`
+}
+
+// Open returns a store for the named backend.
+// For the file backend, path is the root directory of the data.
+// For the sqlite backend, path is the name of the database file.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "file":
+		return NewFileStore(path)
+	case "sqlite":
+		return NewSQLiteStore(path)
+	}
+	return nil, fmt.Errorf("store: unknown backend %q", backend)
+}
+
+// NewID returns a new random identifier.
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// validID restricts identifiers to characters that are safe to use in file names.
+var validID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func checkID(ids ...string) error {
+	for _, id := range ids {
+		if !validID.MatchString(id) {
+			return fmt.Errorf("%q: %w", id, ErrInvalidID)
+		}
+	}
+	return nil
+}
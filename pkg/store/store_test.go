@@ -0,0 +1,160 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(filepath.Join(t.TempDir(), "data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := NewSQLiteStore(filepath.Join(t.TempDir(), "wow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+// testStore runs the same checks against every backend.
+func testStore(t *testing.T, s Store) {
+	defer s.Close()
+
+	// migrations must be safe to apply more than once
+	for i := 0; i < 2; i++ {
+		if err := s.Migrate(); err != nil {
+			t.Fatalf("migrate %d: %v", i, err)
+		}
+	}
+
+	m := &Map{Name: "standard", Data: []byte(`{"nodes":[]}`)}
+	if err := s.CreateMap(m); err != nil {
+		t.Fatalf("create map: %v", err)
+	} else if m.ID == "" {
+		t.Fatalf("create map: want id, got none")
+	}
+	if err := s.CreateMap(m); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("create duplicate map: want %v, got %v", ErrDuplicate, err)
+	}
+	if got, err := s.GetMap(m.ID); err != nil {
+		t.Fatalf("get map: %v", err)
+	} else if got.Name != m.Name || string(got.Data) != string(m.Data) {
+		t.Errorf("get map: want %+v, got %+v", m, got)
+	}
+	if _, err := s.GetMap("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get missing map: want %v, got %v", ErrNotFound, err)
+	}
+
+	if err := s.CreateGame(&Game{Name: "orphan", MapID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("create game without map: want %v, got %v", ErrNotFound, err)
+	}
+	g := &Game{Name: "alpha", MapID: m.ID, Turn: 1, GMToken: "hash"}
+	if err := s.CreateGame(g); err != nil {
+		t.Fatalf("create game: %v", err)
+	}
+	if got, err := s.GetGame(g.ID); err != nil {
+		t.Fatalf("get game: %v", err)
+	} else if !got.Deadline.IsZero() || got.Schedule != "" {
+		t.Errorf("get game: want no schedule, got %q %v", got.Schedule, got.Deadline)
+	}
+	deadline := time.Date(2022, 10, 3, 18, 0, 0, 0, time.UTC)
+	g.Turn, g.Schedule, g.Deadline, g.Reminded = 2, "mon 18:00", deadline, true
+	if err := s.UpdateGame(g); err != nil {
+		t.Fatalf("update game: %v", err)
+	}
+	if got, err := s.GetGame(g.ID); err != nil {
+		t.Fatalf("get game: %v", err)
+	} else if got.Turn != 2 || got.MapID != m.ID || got.GMToken != "hash" || got.Schedule != "mon 18:00" || !got.Deadline.Equal(deadline) || !got.Reminded {
+		t.Errorf("get game: want %+v, got %+v", g, got)
+	}
+	if games, err := s.ListGames(); err != nil {
+		t.Fatalf("list games: %v", err)
+	} else if len(games) != 1 {
+		t.Errorf("list games: want 1, got %d", len(games))
+	}
+
+	p := &Player{GameID: g.ID, ID: "p1", Name: "Alice", Email: "alice@example.com", Home: "Ur", Stars: []string{"Ur"}, Token: "hash"}
+	if err := s.CreatePlayer(p); err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	p.Stars = append(p.Stars, "Erech")
+	if err := s.UpdatePlayer(p); err != nil {
+		t.Fatalf("update player: %v", err)
+	}
+	if got, err := s.GetPlayer(g.ID, p.ID); err != nil {
+		t.Fatalf("get player: %v", err)
+	} else if got.Home != "Ur" || len(got.Stars) != 2 || got.Stars[1] != "Erech" || got.Token != "hash" {
+		t.Errorf("get player: want %+v, got %+v", p, got)
+	}
+	if err := s.CreatePlayer(&Player{GameID: g.ID, ID: "p2", Name: "Bob"}); err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	if players, err := s.ListPlayers(g.ID); err != nil {
+		t.Fatalf("list players: %v", err)
+	} else if len(players) != 2 || players[0].ID != "p1" || players[1].ID != "p2" {
+		t.Errorf("list players: got %+v", players)
+	}
+	if err := s.CreatePlayer(&Player{GameID: g.ID, ID: "../p3"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("create player with bad id: want %v, got %v", ErrInvalidID, err)
+	}
+
+	if err := s.PutOrders(&Orders{GameID: g.ID, PlayerID: p.ID, Turn: 2, Text: "first"}); err != nil {
+		t.Fatalf("put orders: %v", err)
+	}
+	if err := s.PutOrders(&Orders{GameID: g.ID, PlayerID: p.ID, Turn: 2, Text: "second"}); err != nil {
+		t.Fatalf("replace orders: %v", err)
+	}
+	if got, err := s.GetOrders(g.ID, p.ID, 2); err != nil {
+		t.Fatalf("get orders: %v", err)
+	} else if got.Text != "second" {
+		t.Errorf("get orders: want %q, got %q", "second", got.Text)
+	}
+	if orders, err := s.ListOrders(g.ID, 2); err != nil {
+		t.Fatalf("list orders: %v", err)
+	} else if len(orders) != 1 {
+		t.Errorf("list orders: want 1, got %d", len(orders))
+	}
+	if _, err := s.GetOrders(g.ID, "p2", 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get missing orders: want %v, got %v", ErrNotFound, err)
+	}
+
+	if err := s.PutReport(&Report{GameID: g.ID, PlayerID: p.ID, Turn: 1, Text: "report"}); err != nil {
+		t.Fatalf("put report: %v", err)
+	}
+	if got, err := s.GetReport(g.ID, p.ID, 1); err != nil {
+		t.Fatalf("get report: %v", err)
+	} else if got.Text != "report" {
+		t.Errorf("get report: want %q, got %q", "report", got.Text)
+	}
+}
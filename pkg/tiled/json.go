@@ -0,0 +1,183 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type jsonMap struct {
+	Orientation   string        `json:"orientation"`
+	StaggerAxis   string        `json:"staggeraxis"`
+	StaggerIndex  string        `json:"staggerindex"`
+	Width         int           `json:"width"`
+	Height        int           `json:"height"`
+	TileWidth     int           `json:"tilewidth"`
+	TileHeight    int           `json:"tileheight"`
+	HexSideLength int           `json:"hexsidelength"`
+	Infinite      bool          `json:"infinite"`
+	Tilesets      []jsonTileset `json:"tilesets"`
+	Layers        []jsonLayer   `json:"layers"`
+}
+
+type jsonTileset struct {
+	FirstGID int    `json:"firstgid"`
+	Source   string `json:"source"`
+	Tiles    []struct {
+		ID         int            `json:"id"`
+		Properties []jsonProperty `json:"properties"`
+	} `json:"tiles"`
+}
+
+type jsonLayer struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name"`
+	Encoding    string          `json:"encoding"`
+	Compression string          `json:"compression"`
+	Data        json.RawMessage `json:"data"` // array of tiles or a base64 string
+	Objects     []struct {
+		Name     string  `json:"name"`
+		Type     string  `json:"type"`
+		Class    string  `json:"class"`
+		X        float64 `json:"x"`
+		Y        float64 `json:"y"`
+		Width    float64 `json:"width"`
+		Height   float64 `json:"height"`
+		GID      uint32  `json:"gid"`
+		Point    bool    `json:"point"`
+		Polyline []struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"polyline"`
+		Properties []jsonProperty `json:"properties"`
+	} `json:"objects"`
+	Layers []jsonLayer `json:"layers"`
+}
+
+// jsonProperty is a custom property. The value is a string, number or boolean.
+type jsonProperty struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+func jsonProperties(list []jsonProperty) map[string]string {
+	props := make(map[string]string)
+	for _, p := range list {
+		var s string
+		if err := json.Unmarshal(p.Value, &s); err != nil {
+			s = string(p.Value)
+		}
+		props[strings.ToLower(p.Name)] = s
+	}
+	return props
+}
+
+func decodeJSON(data []byte) (*tmap, error) {
+	var j jsonMap
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("tiled: %w", err)
+	}
+	m := &tmap{
+		orientation:   j.Orientation,
+		staggerAxis:   j.StaggerAxis,
+		staggerIndex:  j.StaggerIndex,
+		width:         j.Width,
+		height:        j.Height,
+		tileWidth:     j.TileWidth,
+		tileHeight:    j.TileHeight,
+		hexSideLength: j.HexSideLength,
+		infinite:      j.Infinite,
+	}
+	for _, ts := range j.Tilesets {
+		m.tilesets = append(m.tilesets, ts.tileset())
+	}
+	var err error
+	if m.layers, err = jsonLayers(j.Layers); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (j jsonTileset) tileset() tileset {
+	ts := tileset{firstGID: j.FirstGID, source: j.Source, tiles: make(map[int]map[string]string)}
+	for _, t := range j.Tiles {
+		ts.tiles[t.ID] = jsonProperties(t.Properties)
+	}
+	return ts
+}
+
+func jsonLayers(list []jsonLayer) ([]layer, error) {
+	var layers []layer
+	for _, j := range list {
+		l := layer{kind: j.Type, name: j.Name}
+		switch j.Type {
+		case "tilelayer":
+			var err error
+			if j.Encoding == "base64" {
+				var text string
+				if err = json.Unmarshal(j.Data, &text); err == nil {
+					l.data, err = decodeData(j.Encoding, j.Compression, text)
+				}
+			} else {
+				err = json.Unmarshal(j.Data, &l.data)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("tiled: layer %q: %w", j.Name, err)
+			}
+		case "objectgroup":
+			for _, jo := range j.Objects {
+				o := object{
+					name:       jo.Name,
+					class:      jo.Class,
+					x:          jo.X,
+					y:          jo.Y,
+					width:      jo.Width,
+					height:     jo.Height,
+					gid:        jo.GID,
+					point:      jo.Point,
+					properties: jsonProperties(jo.Properties),
+				}
+				if o.class == "" {
+					o.class = jo.Type
+				}
+				for _, p := range jo.Polyline {
+					o.polyline = append(o.polyline, [2]float64{p.X, p.Y})
+				}
+				l.objects = append(l.objects, o)
+			}
+		case "group":
+			var err error
+			if l.layers, err = jsonLayers(j.Layers); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		layers = append(layers, l)
+	}
+	return layers, nil
+}
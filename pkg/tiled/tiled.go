@@ -0,0 +1,431 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+// Package tiled imports hexagonal maps made with the Tiled map editor
+// (https://www.mapeditor.org/), saved as TMX or as JSON.
+//
+// The map must be hexagonal and finite. Stars are found in two ways:
+//   - Every non-empty cell of a tile layer named "stars" is a star. Its
+//     economic value is the "econ" property of the tile, if the tileset
+//     gives it one.
+//   - Every object of class "star", and every object in an object layer
+//     named "stars", is a star in the hex that holds its center. Objects
+//     can set the star's hex with "col" and "row" properties instead.
+//
+// A star's name is the name of its object, its "name" property, or the
+// coordinates of its hex. The "econ" (or "econ-value") property sets the
+// economic value and the "warps" property lists the names of the stars
+// it has warp lines to, separated by commas. Polylines of class "warp",
+// or in an object layer named "warps", add a warp line between the stars
+// at their two ends. A star object in a tile layer's star hex adds the
+// name, economic value and warps to that star.
+//
+// Tiled numbers columns and rows from 0, the board from 1, so the
+// stagger index flips: Tiled's "odd" is hexes.EVEN and "even" is hexes.ODD.
+package tiled
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/hexes"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Map is a star map imported from Tiled.
+type Map struct {
+	Nodes []board.Node
+	// Orientation is "flat" when Tiled staggers the columns (stagger axis x)
+	// and "pointy" when it staggers the rows (stagger axis y).
+	Orientation string
+	// Offset is the offset of the board's coordinates that keeps the hexes
+	// where they were in Tiled.
+	Offset hexes.OFFSET
+	// Cols and Rows are the size of the Tiled map.
+	Cols, Rows int
+}
+
+// Board returns the board for the map.
+func (m *Map) Board() (*board.Board, error) {
+	return board.FromNodes(m.Nodes)
+}
+
+// RenderOptions returns the default render options with the orientation
+// and offset of the map, so that the board is drawn the way it looks in Tiled.
+func (m *Map) RenderOptions(mono bool) board.RenderOptions {
+	o := board.DefaultRenderOptions(mono)
+	o.Orientation = m.Orientation
+	o.Offset = "even"
+	if m.Offset == hexes.ODD {
+		o.Offset = "odd"
+	}
+	return o
+}
+
+// ReadFile imports a map from a TMX or JSON file.
+// External tilesets are read from paths relative to the file.
+func ReadFile(name string) (*Map, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(name)
+	return Decode(data, func(source string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, filepath.FromSlash(source)))
+	})
+}
+
+// Decode imports a map from TMX or JSON data.
+// The open function reads external tilesets; if it is nil, maps that use
+// external tilesets can't be imported.
+func Decode(data []byte, open func(source string) ([]byte, error)) (*Map, error) {
+	var m *tmap
+	var err error
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		m, err = decodeTMX(data)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		m, err = decodeJSON(data)
+	default:
+		return nil, fmt.Errorf("tiled: map must be TMX or JSON")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, ts := range m.tilesets {
+		if ts.source == "" {
+			continue
+		} else if open == nil {
+			return nil, fmt.Errorf("tiled: can't read external tileset %q", ts.source)
+		}
+		data, err := open(ts.source)
+		if err != nil {
+			return nil, fmt.Errorf("tiled: tileset %q: %w", ts.source, err)
+		}
+		external, err := decodeTileset(data)
+		if err != nil {
+			return nil, fmt.Errorf("tiled: tileset %q: %w", ts.source, err)
+		}
+		external.firstGID = ts.firstGID
+		m.tilesets[i] = external
+	}
+	return m.convert()
+}
+
+// tmap is a Tiled map, decoded from either TMX or JSON.
+type tmap struct {
+	orientation   string
+	staggerAxis   string // x or y
+	staggerIndex  string // odd or even
+	width, height int    // in tiles
+	tileWidth     int
+	tileHeight    int
+	hexSideLength int
+	infinite      bool
+	tilesets      []tileset
+	layers        []layer
+}
+
+type tileset struct {
+	firstGID int
+	source   string                    // file name of an external tileset
+	tiles    map[int]map[string]string // properties of the tiles, by local id
+}
+
+// layer is a tile layer, object layer or group layer.
+type layer struct {
+	kind    string // tilelayer, objectgroup or group
+	name    string
+	data    []uint32 // global tile ids of a tile layer
+	objects []object
+	layers  []layer // of a group
+}
+
+type object struct {
+	name          string
+	class         string
+	x, y          float64
+	width, height float64
+	gid           uint32
+	point         bool
+	polyline      [][2]float64 // relative to x, y
+	properties    map[string]string
+}
+
+// flipMask clears the flags that Tiled keeps in the high bits of a global tile id.
+const flipMask = 0x0fffffff
+
+// properties returns the properties of a tile.
+func (m *tmap) properties(gid uint32) map[string]string {
+	gid &= flipMask
+	var best *tileset
+	for i := range m.tilesets {
+		if ts := &m.tilesets[i]; ts.firstGID <= int(gid) && (best == nil || ts.firstGID > best.firstGID) {
+			best = ts
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.tiles[int(gid)-best.firstGID]
+}
+
+// center returns the center of a tile in pixels.
+// It follows Tiled's hexagonal renderer.
+func (m *tmap) center(x, y int) (float64, float64) {
+	staggerX, staggerEven := m.staggerAxis == "x", m.staggerIndex == "even"
+	sideLengthX, sideLengthY := 0, 0
+	if staggerX {
+		sideLengthX = m.hexSideLength
+	} else {
+		sideLengthY = m.hexSideLength
+	}
+	columnWidth := (m.tileWidth-sideLengthX)/2 + sideLengthX
+	rowHeight := (m.tileHeight-sideLengthY)/2 + sideLengthY
+	var px, py int
+	if staggerX {
+		px, py = x*columnWidth, y*(m.tileHeight+sideLengthY)
+		if (x&1 == 1) != staggerEven {
+			py += rowHeight
+		}
+	} else {
+		px, py = x*(m.tileWidth+sideLengthX), y*rowHeight
+		if (y&1 == 1) != staggerEven {
+			px += columnWidth
+		}
+	}
+	return float64(px) + float64(m.tileWidth)/2, float64(py) + float64(m.tileHeight)/2
+}
+
+// tileAt returns the tile whose center is nearest to a point.
+// The hexes tile the plane, so that is the tile that holds the point.
+func (m *tmap) tileAt(px, py float64) (x, y int, ok bool) {
+	best := math.Inf(1)
+	for ty := 0; ty < m.height; ty++ {
+		for tx := 0; tx < m.width; tx++ {
+			cx, cy := m.center(tx, ty)
+			if d := (cx-px)*(cx-px) + (cy-py)*(cy-py); d < best {
+				best, x, y = d, tx, ty
+			}
+		}
+	}
+	// a point more than a tile away from every center is off the map
+	limit := float64(m.tileWidth*m.tileWidth + m.tileHeight*m.tileHeight)
+	return x, y, best <= limit
+}
+
+// star is a star being imported, in Tiled's coordinates.
+type star struct {
+	x, y  int
+	name  string
+	econ  int
+	warps []string
+}
+
+// convert finds the stars and warps in the map.
+func (m *tmap) convert() (*Map, error) {
+	if m.orientation != "hexagonal" {
+		return nil, fmt.Errorf("tiled: map must be hexagonal, not %q", m.orientation)
+	} else if m.infinite {
+		return nil, fmt.Errorf("tiled: infinite maps are not supported")
+	} else if m.width < 1 || m.height < 1 || m.tileWidth < 1 || m.tileHeight < 1 {
+		return nil, fmt.Errorf("tiled: map has no tiles")
+	}
+	out := &Map{Cols: m.width, Rows: m.height}
+	switch m.staggerAxis {
+	case "x":
+		out.Orientation = "flat"
+	case "y":
+		out.Orientation = "pointy"
+	default:
+		return nil, fmt.Errorf("tiled: stagger axis must be x or y, not %q", m.staggerAxis)
+	}
+	switch m.staggerIndex {
+	case "odd":
+		out.Offset = hexes.EVEN
+	case "even":
+		out.Offset = hexes.ODD
+	default:
+		return nil, fmt.Errorf("tiled: stagger index must be odd or even, not %q", m.staggerIndex)
+	}
+
+	stars := make(map[[2]int]*star)
+	starAt := func(x, y int) *star {
+		s, ok := stars[[2]int{x, y}]
+		if !ok {
+			s = &star{x: x, y: y}
+			stars[[2]int{x, y}] = s
+		}
+		return s
+	}
+	type line struct{ x1, y1, x2, y2 float64 }
+	var lines []line
+
+	var walk func(layers []layer) error
+	walk = func(layers []layer) error {
+		for _, l := range layers {
+			inStars, inWarps := strings.EqualFold(l.name, "stars"), strings.EqualFold(l.name, "warps")
+			switch l.kind {
+			case "group":
+				if err := walk(l.layers); err != nil {
+					return err
+				}
+			case "tilelayer":
+				if !inStars {
+					continue
+				} else if len(l.data) != m.width*m.height {
+					return fmt.Errorf("tiled: layer %q: want %d tiles, got %d", l.name, m.width*m.height, len(l.data))
+				}
+				for i, gid := range l.data {
+					if gid&flipMask == 0 {
+						continue
+					}
+					s := starAt(i%m.width, i/m.width)
+					props := m.properties(gid)
+					if v, ok := props["econ"]; ok {
+						n, err := strconv.Atoi(v)
+						if err != nil {
+							return fmt.Errorf("tiled: layer %q: tile %d: econ: %q is not a number", l.name, gid&flipMask, v)
+						}
+						s.econ = n
+					}
+				}
+			case "objectgroup":
+				for _, o := range l.objects {
+					class := strings.ToLower(o.class)
+					if len(o.polyline) >= 2 && (class == "warp" || inWarps) {
+						first, last := o.polyline[0], o.polyline[len(o.polyline)-1]
+						lines = append(lines, line{o.x + first[0], o.y + first[1], o.x + last[0], o.y + last[1]})
+						continue
+					} else if len(o.polyline) != 0 || !(class == "star" || inStars) {
+						continue
+					}
+					if err := m.objectStar(o, starAt); err != nil {
+						return fmt.Errorf("tiled: layer %q: %w", l.name, err)
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(m.layers); err != nil {
+		return nil, err
+	}
+
+	// name the stars, then turn the polylines into warps
+	for _, s := range stars {
+		if s.name == "" {
+			s.name = board.Coords{Col: s.x + 1, Row: s.y + 1}.String()
+		}
+	}
+	for _, l := range lines {
+		var ends [2]*star
+		for i, p := range [2][2]float64{{l.x1, l.y1}, {l.x2, l.y2}} {
+			x, y, ok := m.tileAt(p[0], p[1])
+			if ends[i], _ = stars[[2]int{x, y}]; !ok || ends[i] == nil {
+				return nil, fmt.Errorf("tiled: warp line at %g, %g doesn't end on a star", p[0], p[1])
+			}
+		}
+		ends[0].warps = append(ends[0].warps, ends[1].name)
+	}
+
+	for _, s := range stars {
+		out.Nodes = append(out.Nodes, board.Node{Name: s.name, Col: s.x + 1, Row: s.y + 1, EconValue: s.econ, Warps: s.warps})
+	}
+	sort.Slice(out.Nodes, func(i, j int) bool {
+		if out.Nodes[i].Row != out.Nodes[j].Row {
+			return out.Nodes[i].Row < out.Nodes[j].Row
+		}
+		return out.Nodes[i].Col < out.Nodes[j].Col
+	})
+	return out, nil
+}
+
+// objectStar adds the star for an object.
+func (m *tmap) objectStar(o object, starAt func(x, y int) *star) error {
+	props := make(map[string]string)
+	if o.gid != 0 {
+		for k, v := range m.properties(o.gid) {
+			props[k] = v
+		}
+	}
+	for k, v := range o.properties {
+		props[k] = v
+	}
+	name := o.name
+	if name == "" {
+		name = props["name"]
+	}
+	describe := name
+	if describe == "" {
+		describe = fmt.Sprintf("object at %g, %g", o.x, o.y)
+	}
+
+	// the center of the object: tile objects are anchored at the bottom left
+	cx, cy := o.x+o.width/2, o.y+o.height/2
+	if o.point {
+		cx, cy = o.x, o.y
+	} else if o.gid != 0 {
+		cy = o.y - o.height/2
+	}
+	x, y, ok := m.tileAt(cx, cy)
+	col, hasCol := props["col"]
+	row, hasRow := props["row"]
+	if hasCol || hasRow {
+		c, err1 := strconv.Atoi(col)
+		r, err2 := strconv.Atoi(row)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("%s: col and row must both be numbers", describe)
+		}
+		x, y, ok = c-1, r-1, c >= 1 && c <= m.width && r >= 1 && r <= m.height
+	}
+	if !ok {
+		return fmt.Errorf("%s: not on the map", describe)
+	}
+
+	s := starAt(x, y)
+	if name != "" {
+		s.name = name
+	}
+	for _, key := range []string{"econ", "econ-value"} {
+		if v, ok := props[key]; ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return fmt.Errorf("%s: %s: %q is not a number", describe, key, v)
+			}
+			s.econ = n
+		}
+	}
+	for _, target := range strings.Split(props["warps"], ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			s.warps = append(s.warps, target)
+		}
+	}
+	return nil
+}
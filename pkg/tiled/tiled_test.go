@@ -0,0 +1,232 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package tiled
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/mdhender/wow/pkg/board"
+	"github.com/mdhender/wow/pkg/hexes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testTMX is a 4x3 map with flat hexes. The star tiles are at 0,0 and
+// 2,1; the objects name the first, add a third star and a warp line.
+const testTMX = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="hexagonal" renderorder="right-down" width="4" height="3"
+     tilewidth="32" tileheight="28" infinite="0" hexsidelength="16" staggeraxis="x" staggerindex="odd">
+ <tileset firstgid="1" name="stars" tilewidth="32" tileheight="28" tilecount="2" columns="2">
+  <tile id="1">
+   <properties>
+    <property name="econ" type="int" value="3"/>
+   </properties>
+  </tile>
+ </tileset>
+ <group id="4" name="map">
+  <layer id="1" name="Stars" width="4" height="3">
+   <data encoding="csv">
+1,0,0,0,
+0,0,2,0,
+0,0,0,0
+</data>
+  </layer>
+ </group>
+ <objectgroup id="2" name="stars">
+  <object id="1" name="Alpha" x="16" y="14">
+   <point/>
+  </object>
+  <object id="2" x="0" y="0">
+   <properties>
+    <property name="name" value="Cor"/>
+    <property name="col" type="int" value="4"/>
+    <property name="row" type="int" value="3"/>
+    <property name="econ-value" type="int" value="2"/>
+    <property name="warps" value="Alpha"/>
+   </properties>
+  </object>
+ </objectgroup>
+ <objectgroup id="3" name="notes">
+  <object id="3" class="warp" x="16" y="14">
+   <polyline points="0,0 20,10 48,28"/>
+  </object>
+  <object id="4" name="ignored" x="40" y="28"/>
+ </objectgroup>
+</map>
+`
+
+// testJSON returns the same map as testTMX, as compressed JSON.
+func testJSON(t *testing.T) string {
+	var raw, compressed bytes.Buffer
+	for _, gid := range []uint32{1, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0} {
+		_ = binary.Write(&raw, binary.LittleEndian, gid)
+	}
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(raw.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	_ = zw.Close()
+	return fmt.Sprintf(`{
+ "orientation": "hexagonal", "width": 4, "height": 3, "tilewidth": 32, "tileheight": 28,
+ "hexsidelength": 16, "staggeraxis": "x", "staggerindex": "odd", "infinite": false,
+ "tilesets": [{"firstgid": 1, "tiles": [{"id": 1, "properties": [{"name": "econ", "type": "int", "value": 3}]}]}],
+ "layers": [
+  {"type": "group", "name": "map", "layers": [
+   {"type": "tilelayer", "name": "stars", "encoding": "base64", "compression": "zlib", "data": %q}
+  ]},
+  {"type": "objectgroup", "name": "stars", "objects": [
+   {"name": "Alpha", "x": 16, "y": 14, "point": true},
+   {"name": "", "x": 0, "y": 0, "properties": [
+    {"name": "name", "type": "string", "value": "Cor"},
+    {"name": "col", "type": "int", "value": 4},
+    {"name": "row", "type": "int", "value": 3},
+    {"name": "econ-value", "type": "int", "value": 2},
+    {"name": "warps", "type": "string", "value": "Alpha"}
+   ]}
+  ]},
+  {"type": "objectgroup", "name": "notes", "objects": [
+   {"class": "warp", "x": 16, "y": 14, "polyline": [{"x": 0, "y": 0}, {"x": 20, "y": 10}, {"x": 48, "y": 28}]},
+   {"name": "ignored", "x": 40, "y": 28}
+  ]}
+ ]
+}`, base64.StdEncoding.EncodeToString(compressed.Bytes()))
+}
+
+func TestDecode(t *testing.T) {
+	want := &Map{
+		Nodes: []board.Node{
+			{Name: "Alpha", Col: 1, Row: 1, EconValue: 0, Warps: []string{"0302"}},
+			{Name: "0302", Col: 3, Row: 2, EconValue: 3},
+			{Name: "Cor", Col: 4, Row: 3, EconValue: 2, Warps: []string{"Alpha"}},
+		},
+		Orientation: "flat",
+		Offset:      hexes.EVEN,
+		Cols:        4,
+		Rows:        3,
+	}
+	for _, tc := range []struct {
+		name string
+		data string
+	}{
+		{"tmx", testTMX},
+		{"json", testJSON(t)},
+	} {
+		m, err := Decode([]byte(tc.data), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, m, want)
+		}
+		b, err := m.Board()
+		if err != nil {
+			t.Errorf("%s: board: %v", tc.name, err)
+			continue
+		}
+		if _, err := b.Route("Cor", "0302"); err != nil {
+			t.Errorf("%s: route: %v", tc.name, err)
+		}
+		if o := m.RenderOptions(false); o.Orientation != "flat" || o.Offset != "even" {
+			t.Errorf("%s: render options %s/%s, want flat/even", tc.name, o.Orientation, o.Offset)
+		}
+	}
+}
+
+func TestExternalTileset(t *testing.T) {
+	tmx := strings.Replace(testTMX, `<tileset firstgid="1" name="stars"`, `<tileset firstgid="1" source="tiles/stars.tsx"/><unused`, 1)
+	tmx = strings.Replace(tmx, "</tileset>", "</unused>", 1)
+	tsx := `<tileset name="stars"><tile id="1"><properties><property name="econ" value="5"/></properties></tile></tileset>`
+	if _, err := Decode([]byte(tmx), nil); err == nil {
+		t.Errorf("without a loader: want error")
+	}
+	m, err := Decode([]byte(tmx), func(source string) ([]byte, error) {
+		if source != "tiles/stars.tsx" {
+			return nil, fmt.Errorf("unexpected source %q", source)
+		}
+		return []byte(tsx), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Nodes[1].EconValue; got != 5 {
+		t.Errorf("econ: got %d, want 5", got)
+	}
+}
+
+// TestStagger checks that every tile center is found in its own tile,
+// and that the stagger settings map to the board's orientation and offset.
+func TestStagger(t *testing.T) {
+	for _, tc := range []struct {
+		axis, index string
+		orientation string
+		offset      hexes.OFFSET
+	}{
+		{"x", "odd", "flat", hexes.EVEN},
+		{"x", "even", "flat", hexes.ODD},
+		{"y", "odd", "pointy", hexes.EVEN},
+		{"y", "even", "pointy", hexes.ODD},
+	} {
+		m := &tmap{orientation: "hexagonal", staggerAxis: tc.axis, staggerIndex: tc.index,
+			width: 5, height: 4, tileWidth: 32, tileHeight: 28, hexSideLength: 16}
+		for y := 0; y < m.height; y++ {
+			for x := 0; x < m.width; x++ {
+				cx, cy := m.center(x, y)
+				if gx, gy, ok := m.tileAt(cx+3, cy-3); !ok || gx != x || gy != y {
+					t.Errorf("%s/%s: tile %d,%d: got %d,%d", tc.axis, tc.index, x, y, gx, gy)
+				}
+			}
+		}
+		out, err := m.convert()
+		if err != nil {
+			t.Errorf("%s/%s: %v", tc.axis, tc.index, err)
+		} else if out.Orientation != tc.orientation || out.Offset != tc.offset {
+			t.Errorf("%s/%s: got %s/%d, want %s/%d", tc.axis, tc.index, out.Orientation, out.Offset, tc.orientation, tc.offset)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, old, new, want string
+	}{
+		{"orthogonal", `orientation="hexagonal"`, `orientation="orthogonal"`, "must be hexagonal"},
+		{"infinite", `infinite="0"`, `infinite="1"`, "infinite maps"},
+		{"econ", `value="3"`, `value="lots"`, `"lots" is not a number`},
+		{"off the map", `name="Alpha" x="16"`, `name="Alpha" x="1600"`, "Alpha: not on the map"},
+		{"loose warp", `points="0,0 20,10 48,28"`, `points="0,0 24,0"`, "doesn't end on a star"},
+		{"short data", "0,0,0,0\n</data>", "0,0,0\n</data>", "want 12 tiles, got 11"},
+		{"compression", `encoding="csv"`, `encoding="base64" compression="zstd"`, "zstd compression"},
+	} {
+		_, err := Decode([]byte(strings.Replace(testTMX, tc.old, tc.new, 1)), nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
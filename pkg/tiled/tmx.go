@@ -0,0 +1,286 @@
+/*
+ * wars of warp - an implementation of warpwar
+ *
+ * Copyright (c) 2022 Michael D Henderson
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TMX files are XML. Layers can be nested in groups, so the children of
+// the map and of groups are collected in document order and sorted out
+// by element name.
+
+type xmlMap struct {
+	Orientation   string       `xml:"orientation,attr"`
+	StaggerAxis   string       `xml:"staggeraxis,attr"`
+	StaggerIndex  string       `xml:"staggerindex,attr"`
+	Width         int          `xml:"width,attr"`
+	Height        int          `xml:"height,attr"`
+	TileWidth     int          `xml:"tilewidth,attr"`
+	TileHeight    int          `xml:"tileheight,attr"`
+	HexSideLength int          `xml:"hexsidelength,attr"`
+	Infinite      int          `xml:"infinite,attr"`
+	Tilesets      []xmlTileset `xml:"tileset"`
+	Layers        []xmlLayer   `xml:",any"`
+}
+
+type xmlTileset struct {
+	FirstGID int    `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+	Tiles    []struct {
+		ID         int           `xml:"id,attr"`
+		Properties []xmlProperty `xml:"properties>property"`
+	} `xml:"tile"`
+}
+
+type xmlLayer struct {
+	XMLName xml.Name
+	Name    string `xml:"name,attr"`
+	Data    *struct {
+		Encoding    string `xml:"encoding,attr"`
+		Compression string `xml:"compression,attr"`
+		Tiles       []struct {
+			GID uint32 `xml:"gid,attr"`
+		} `xml:"tile"`
+		Text string `xml:",chardata"`
+	} `xml:"data"`
+	Objects []xmlObject `xml:"object"`
+	Layers  []xmlLayer  `xml:",any"`
+}
+
+type xmlObject struct {
+	Name     string    `xml:"name,attr"`
+	Type     string    `xml:"type,attr"`
+	Class    string    `xml:"class,attr"`
+	X        float64   `xml:"x,attr"`
+	Y        float64   `xml:"y,attr"`
+	Width    float64   `xml:"width,attr"`
+	Height   float64   `xml:"height,attr"`
+	GID      uint32    `xml:"gid,attr"`
+	Point    *struct{} `xml:"point"`
+	Polyline *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polyline"`
+	Properties []xmlProperty `xml:"properties>property"`
+}
+
+// xmlProperty is a custom property. Multi-line strings are kept in the
+// text of the element instead of the value attribute.
+type xmlProperty struct {
+	Name  string  `xml:"name,attr"`
+	Value *string `xml:"value,attr"`
+	Text  string  `xml:",chardata"`
+}
+
+func xmlProperties(list []xmlProperty) map[string]string {
+	props := make(map[string]string)
+	for _, p := range list {
+		if p.Value != nil {
+			props[strings.ToLower(p.Name)] = *p.Value
+		} else {
+			props[strings.ToLower(p.Name)] = p.Text
+		}
+	}
+	return props
+}
+
+func decodeTMX(data []byte) (*tmap, error) {
+	var x xmlMap
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, fmt.Errorf("tiled: %w", err)
+	}
+	m := &tmap{
+		orientation:   x.Orientation,
+		staggerAxis:   x.StaggerAxis,
+		staggerIndex:  x.StaggerIndex,
+		width:         x.Width,
+		height:        x.Height,
+		tileWidth:     x.TileWidth,
+		tileHeight:    x.TileHeight,
+		hexSideLength: x.HexSideLength,
+		infinite:      x.Infinite != 0,
+	}
+	for _, ts := range x.Tilesets {
+		m.tilesets = append(m.tilesets, ts.tileset())
+	}
+	var err error
+	if m.layers, err = xmlLayers(x.Layers); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (x xmlTileset) tileset() tileset {
+	ts := tileset{firstGID: x.FirstGID, source: x.Source, tiles: make(map[int]map[string]string)}
+	for _, t := range x.Tiles {
+		ts.tiles[t.ID] = xmlProperties(t.Properties)
+	}
+	return ts
+}
+
+func xmlLayers(list []xmlLayer) ([]layer, error) {
+	var layers []layer
+	for _, x := range list {
+		l := layer{name: x.Name}
+		switch x.XMLName.Local {
+		case "layer":
+			l.kind = "tilelayer"
+			if x.Data == nil {
+				return nil, fmt.Errorf("tiled: layer %q has no data", x.Name)
+			} else if x.Data.Encoding == "" {
+				for _, t := range x.Data.Tiles {
+					l.data = append(l.data, t.GID)
+				}
+			} else {
+				var err error
+				if l.data, err = decodeData(x.Data.Encoding, x.Data.Compression, x.Data.Text); err != nil {
+					return nil, fmt.Errorf("tiled: layer %q: %w", x.Name, err)
+				}
+			}
+		case "objectgroup":
+			l.kind = "objectgroup"
+			for _, xo := range x.Objects {
+				o := object{
+					name:       xo.Name,
+					class:      xo.Class,
+					x:          xo.X,
+					y:          xo.Y,
+					width:      xo.Width,
+					height:     xo.Height,
+					gid:        xo.GID,
+					point:      xo.Point != nil,
+					properties: xmlProperties(xo.Properties),
+				}
+				if o.class == "" {
+					o.class = xo.Type
+				}
+				if xo.Polyline != nil {
+					for _, pair := range strings.Fields(xo.Polyline.Points) {
+						var p [2]float64
+						xy := strings.Split(pair, ",")
+						if len(xy) != 2 {
+							return nil, fmt.Errorf("tiled: layer %q: invalid polyline point %q", x.Name, pair)
+						}
+						for i := range xy {
+							v, err := strconv.ParseFloat(xy[i], 64)
+							if err != nil {
+								return nil, fmt.Errorf("tiled: layer %q: invalid polyline point %q", x.Name, pair)
+							}
+							p[i] = v
+						}
+						o.polyline = append(o.polyline, p)
+					}
+				}
+				l.objects = append(l.objects, o)
+			}
+		case "group":
+			l.kind = "group"
+			var err error
+			if l.layers, err = xmlLayers(x.Layers); err != nil {
+				return nil, err
+			}
+		default:
+			// image layers, properties and editor settings
+			continue
+		}
+		layers = append(layers, l)
+	}
+	return layers, nil
+}
+
+// decodeTileset decodes an external tileset, from a TSX or JSON file.
+func decodeTileset(data []byte) (tileset, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		var x xmlTileset
+		if err := xml.Unmarshal(data, &x); err != nil {
+			return tileset{}, err
+		}
+		return x.tileset(), nil
+	}
+	var j jsonTileset
+	if err := json.Unmarshal(data, &j); err != nil {
+		return tileset{}, err
+	}
+	return j.tileset(), nil
+}
+
+// decodeData decodes the tiles of a tile layer.
+func decodeData(encoding, compression, text string) ([]uint32, error) {
+	switch encoding {
+	case "csv":
+		var gids []uint32
+		for _, field := range strings.Split(text, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tile %q", field)
+			}
+			gids = append(gids, uint32(gid))
+		}
+		return gids, nil
+	case "base64":
+		if compression != "" && compression != "zlib" && compression != "gzip" {
+			return nil, fmt.Errorf("%s compression is not supported", compression)
+		}
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		var r io.Reader = bytes.NewReader(raw)
+		switch compression {
+		case "zlib":
+			if r, err = zlib.NewReader(r); err != nil {
+				return nil, err
+			}
+		case "gzip":
+			if r, err = gzip.NewReader(r); err != nil {
+				return nil, err
+			}
+		}
+		if raw, err = io.ReadAll(r); err != nil {
+			return nil, err
+		} else if len(raw)%4 != 0 {
+			return nil, fmt.Errorf("tile data is not a whole number of tiles")
+		}
+		gids := make([]uint32, len(raw)/4)
+		for i := range gids {
+			gids[i] = binary.LittleEndian.Uint32(raw[4*i:])
+		}
+		return gids, nil
+	}
+	return nil, fmt.Errorf("%q encoding is not supported", encoding)
+}